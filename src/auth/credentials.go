@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Scopes granted to callers of the session management endpoints
+const (
+	ScopeSessionsRead        = "sessions:read"
+	ScopeSessionsWrite       = "sessions:write"
+	ScopeSessionsCredentials = "sessions:credentials"
+)
+
+var knownScopes = map[string]bool{
+	ScopeSessionsRead:        true,
+	ScopeSessionsWrite:       true,
+	ScopeSessionsCredentials: true,
+}
+
+// minSecretLength is the shortest API key or HMAC secret that is accepted
+const minSecretLength = 16
+
+// Principal is an authenticated caller
+type Principal struct {
+	Name   string
+	Scopes []string
+}
+
+// HasScope reports whether the caller was granted scope
+func (p *Principal) HasScope(scope string) bool {
+	for _, granted := range p.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// credential is an entry of the credentials file. A caller may have an API key,
+// an HMAC secret for signed requests, or both
+type credential struct {
+	Name       string   `json:"name"`
+	APIKey     string   `json:"api_key"`
+	HMACSecret string   `json:"hmac_secret"`
+	Scopes     []string `json:"scopes"`
+}
+
+// Credentials holds the configured callers
+type Credentials struct {
+	apiKeys map[[sha256.Size]byte]*Principal // By digest of the API key
+	signers map[string]*signer               // By caller name, sent as the key ID of signed requests
+	callers int
+}
+
+type signer struct {
+	principal *Principal
+	secret    []byte
+}
+
+// LoadCredentials reads the JSON credentials file
+func LoadCredentials(path string) (*Credentials, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read auth credentials: %w", err)
+	}
+
+	var entries []credential
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("auth credentials file %s is not valid JSON: %w", path, err)
+	}
+
+	creds := &Credentials{
+		apiKeys: make(map[[sha256.Size]byte]*Principal),
+		signers: make(map[string]*signer),
+	}
+	names := make(map[string]bool)
+
+	for i, entry := range entries {
+		if entry.Name == "" {
+			return nil, fmt.Errorf("auth credential #%d has no name", i+1)
+		}
+		if names[entry.Name] {
+			return nil, fmt.Errorf("auth credential %q is defined more than once", entry.Name)
+		}
+		names[entry.Name] = true
+
+		if entry.APIKey == "" && entry.HMACSecret == "" {
+			return nil, fmt.Errorf("auth credential %q needs an api_key or an hmac_secret", entry.Name)
+		}
+		if len(entry.Scopes) == 0 {
+			return nil, fmt.Errorf("auth credential %q has no scopes", entry.Name)
+		}
+		for _, scope := range entry.Scopes {
+			if !knownScopes[scope] {
+				return nil, fmt.Errorf("auth credential %q has unknown scope %q", entry.Name, scope)
+			}
+		}
+
+		principal := &Principal{Name: entry.Name, Scopes: entry.Scopes}
+
+		if entry.APIKey != "" {
+			if len(entry.APIKey) < minSecretLength {
+				return nil, fmt.Errorf("api_key of auth credential %q must be at least %d characters", entry.Name, minSecretLength)
+			}
+			digest := sha256.Sum256([]byte(entry.APIKey))
+			if _, ok := creds.apiKeys[digest]; ok {
+				return nil, fmt.Errorf("api_key of auth credential %q is already used by another caller", entry.Name)
+			}
+			creds.apiKeys[digest] = principal
+		}
+
+		if entry.HMACSecret != "" {
+			if len(entry.HMACSecret) < minSecretLength {
+				return nil, fmt.Errorf("hmac_secret of auth credential %q must be at least %d characters", entry.Name, minSecretLength)
+			}
+			creds.signers[entry.Name] = &signer{principal: principal, secret: []byte(entry.HMACSecret)}
+		}
+	}
+
+	if len(names) == 0 {
+		return nil, fmt.Errorf("auth credentials file %s defines no callers", path)
+	}
+	creds.callers = len(names)
+	return creds, nil
+}
+
+// Len returns the number of callers
+func (c *Credentials) Len() int {
+	return c.callers
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeCredentials writes a credentials file and returns its path
+func writeCredentials(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "auth.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadCredentials(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+		wantLen int
+	}{
+		{
+			name: "api key and signer",
+			content: `[{"name": "dispatcher", "api_key": "0123456789abcdef", "hmac_secret": "fedcba9876543210", "scopes": ["sessions:write"]},
+				{"name": "operator", "hmac_secret": "fedcba9876543210", "scopes": ["sessions:read"]}]`,
+			wantLen: 2,
+		},
+		{name: "invalid JSON", content: `{`, wantErr: "not valid JSON"},
+		{name: "no callers", content: `[]`, wantErr: "defines no callers"},
+		{name: "missing name", content: `[{"api_key": "0123456789abcdef", "scopes": ["sessions:read"]}]`, wantErr: "has no name"},
+		{
+			name: "duplicate name",
+			content: `[{"name": "a", "api_key": "0123456789abcdef", "scopes": ["sessions:read"]},
+				{"name": "a", "api_key": "fedcba9876543210", "scopes": ["sessions:read"]}]`,
+			wantErr: "defined more than once",
+		},
+		{name: "no secret", content: `[{"name": "a", "scopes": ["sessions:read"]}]`, wantErr: "needs an api_key or an hmac_secret"},
+		{name: "no scopes", content: `[{"name": "a", "api_key": "0123456789abcdef"}]`, wantErr: "has no scopes"},
+		{name: "unknown scope", content: `[{"name": "a", "api_key": "0123456789abcdef", "scopes": ["sessions:*"]}]`, wantErr: "unknown scope"},
+		{name: "short api key", content: `[{"name": "a", "api_key": "short", "scopes": ["sessions:read"]}]`, wantErr: "at least 16 characters"},
+		{name: "short hmac secret", content: `[{"name": "a", "hmac_secret": "short", "scopes": ["sessions:read"]}]`, wantErr: "at least 16 characters"},
+		{
+			name: "api key shared by two callers",
+			content: `[{"name": "a", "api_key": "0123456789abcdef", "scopes": ["sessions:read"]},
+				{"name": "b", "api_key": "0123456789abcdef", "scopes": ["sessions:write"]}]`,
+			wantErr: "already used by another caller",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creds, err := LoadCredentials(writeCredentials(t, tt.content))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadCredentials() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadCredentials() error = %v", err)
+			}
+			if creds.Len() != tt.wantLen {
+				t.Errorf("Len() = %d, want %d", creds.Len(), tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestLoadCredentialsMissingFile(t *testing.T) {
+	if _, err := LoadCredentials(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Fatal("LoadCredentials() of a missing file succeeded")
+	}
+}
@@ -0,0 +1,210 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"connection-service/src/config"
+	"connection-service/src/requestid"
+	"connection-service/src/schemas"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Headers of signed requests. The signature is the hex HMAC-SHA256, keyed with the
+// caller's secret, of: METHOD "\n" REQUEST_URI "\n" TIMESTAMP "\n" hex(SHA256(body))
+const (
+	HeaderAPIKey    = "X-API-Key"
+	HeaderKeyID     = "X-Auth-Key-Id"
+	HeaderTimestamp = "X-Auth-Timestamp"
+	HeaderSignature = "X-Auth-Signature"
+)
+
+// maxSignedBodySize bounds the body read to verify a signed request
+const maxSignedBodySize = 1 << 20
+
+// principalKey is the gin context key of the authenticated caller
+const principalKey = "auth.principal"
+
+var (
+	errMissingCredentials = errors.New("missing credentials: send an API key or a signed request")
+	errInvalidAPIKey      = errors.New("invalid API key")
+	errInvalidSignature   = errors.New("invalid request signature")
+)
+
+// Authenticator authenticates service-to-service calls with static API keys or
+// HMAC-signed requests and enforces the scopes required by each route
+type Authenticator struct {
+	enabled     bool
+	credentials *Credentials
+	maxSkew     time.Duration
+}
+
+// NewAuthenticator loads the configured credentials. With authentication disabled
+// every request is let through, as before authentication existed
+func NewAuthenticator(cfg *config.AuthConfig) (*Authenticator, error) {
+	if !cfg.GetEnabled() {
+		slog.Warn("Service-to-service authentication is disabled; session management endpoints are open to anyone")
+		return &Authenticator{}, nil
+	}
+
+	credentials, err := LoadCredentials(cfg.GetCredentialsFile())
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("Service-to-service authentication enabled", "callers", credentials.Len())
+
+	return &Authenticator{
+		enabled:     true,
+		credentials: credentials,
+		maxSkew:     cfg.GetSignatureMaxSkew(),
+	}, nil
+}
+
+// Require returns a handler that rejects callers that cannot be authenticated with
+// 401 and callers lacking any of scopes with 403
+func (a *Authenticator) Require(scopes ...string) gin.HandlerFunc {
+	if !a.enabled {
+		return func(ctx *gin.Context) {
+			ctx.Next()
+		}
+	}
+
+	return func(ctx *gin.Context) {
+		principal, err := a.authenticate(ctx.Request)
+		if err != nil {
+			slog.WarnContext(ctx.Request.Context(), "Rejected unauthenticated request",
+				"method", ctx.Request.Method,
+				"path", ctx.Request.URL.Path,
+				"client_ip", ctx.ClientIP(),
+				"error", err)
+			ctx.Header("WWW-Authenticate", `Bearer realm="connection-service"`)
+			abortWithError(ctx, schemas.NewUnauthorizedError(err.Error(), ctx.Request.URL.Path))
+			return
+		}
+
+		for _, scope := range scopes {
+			if !principal.HasScope(scope) {
+				slog.WarnContext(ctx.Request.Context(), "Rejected request lacking scope",
+					"caller", principal.Name,
+					"method", ctx.Request.Method,
+					"path", ctx.Request.URL.Path,
+					"scope", scope)
+				abortWithError(ctx, schemas.NewForbiddenError(
+					fmt.Sprintf("caller %s lacks the %s scope", principal.Name, scope),
+					ctx.Request.URL.Path,
+				))
+				return
+			}
+		}
+
+		ctx.Set(principalKey, principal)
+		ctx.Next()
+	}
+}
+
+// abortWithError stops the handler chain with apiError, tagged with the request ID
+func abortWithError(ctx *gin.Context, apiError *schemas.ErrorResponse) {
+	apiError.RequestID = requestid.FromContext(ctx.Request.Context())
+	ctx.AbortWithStatusJSON(apiError.Status, apiError)
+}
+
+// PrincipalFrom returns the caller authenticated for a request, or nil if
+// authentication is disabled or the route does not require it
+func PrincipalFrom(ctx *gin.Context) *Principal {
+	value, ok := ctx.Get(principalKey)
+	if !ok {
+		return nil
+	}
+	principal, _ := value.(*Principal)
+	return principal
+}
+
+// authenticate identifies the caller of a request from its signature or API key
+func (a *Authenticator) authenticate(req *http.Request) (*Principal, error) {
+	if keyID := req.Header.Get(HeaderKeyID); keyID != "" {
+		return a.verifySignature(req, keyID)
+	}
+
+	apiKey := req.Header.Get(HeaderAPIKey)
+	if apiKey == "" {
+		if bearer, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer "); ok {
+			apiKey = strings.TrimSpace(bearer)
+		}
+	}
+	if apiKey == "" {
+		return nil, errMissingCredentials
+	}
+
+	// Keys are looked up by digest, so the comparison does not leak key prefixes through timing
+	principal, ok := a.credentials.apiKeys[sha256.Sum256([]byte(apiKey))]
+	if !ok {
+		return nil, errInvalidAPIKey
+	}
+	return principal, nil
+}
+
+// verifySignature checks the HMAC signature and timestamp of a signed request
+// The body is read to hash it and put back for the handler
+// Signatures carry no nonce: a captured request can be replayed unchanged, to any
+// replica, until its timestamp is AUTH_SIGNATURE_MAX_SKEW old. That window is accepted,
+// as a replay can only repeat the signed call on the same session
+func (a *Authenticator) verifySignature(req *http.Request, keyID string) (*Principal, error) {
+	signer, ok := a.credentials.signers[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key ID %q", errInvalidSignature, keyID)
+	}
+
+	timestamp := req.Header.Get(HeaderTimestamp)
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s must be a Unix timestamp", errInvalidSignature, HeaderTimestamp)
+	}
+	if skew := time.Since(time.Unix(seconds, 0)).Abs(); skew > a.maxSkew {
+		return nil, fmt.Errorf("%w: timestamp is %s away from server time", errInvalidSignature, skew.Round(time.Second))
+	}
+
+	signature, err := hex.DecodeString(req.Header.Get(HeaderSignature))
+	if err != nil || len(signature) == 0 {
+		return nil, fmt.Errorf("%w: %s must be a hex encoded HMAC-SHA256", errInvalidSignature, HeaderSignature)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		body, err = io.ReadAll(io.LimitReader(req.Body, maxSignedBodySize+1))
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to read body: %v", errInvalidSignature, err)
+		}
+		if len(body) > maxSignedBodySize {
+			return nil, fmt.Errorf("%w: body exceeds %d bytes", errInvalidSignature, maxSignedBodySize)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	expected := Sign(signer.secret, req.Method, req.URL.RequestURI(), timestamp, body)
+	if !hmac.Equal(signature, expected) {
+		return nil, errInvalidSignature
+	}
+	return signer.principal, nil
+}
+
+// Sign computes the signature of a request as expected in HeaderSignature (hex encoded)
+func Sign(secret []byte, method, requestURI, timestamp string, body []byte) []byte {
+	bodyDigest := sha256.Sum256(body)
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(method + "\n" + requestURI + "\n" + timestamp + "\n" + hex.EncodeToString(bodyDigest[:])))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,197 @@
+package auth
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	testAPIKey     = "reader-api-key-0123456789"
+	testHMACSecret = "signer-secret-0123456789"
+	testMaxSkew    = 5 * time.Minute
+)
+
+// newTestAuthenticator returns an authenticator knowing a reader, identified by
+// API key, and a signer, identified by signature, both with the sessions:read scope only
+func newTestAuthenticator(t *testing.T) *Authenticator {
+	t.Helper()
+	creds, err := LoadCredentials(writeCredentials(t, `[
+		{"name": "reader", "api_key": "`+testAPIKey+`", "scopes": ["sessions:read"]},
+		{"name": "signer", "hmac_secret": "`+testHMACSecret+`", "scopes": ["sessions:read"]}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Authenticator{enabled: true, credentials: creds, maxSkew: testMaxSkew}
+}
+
+// newTestRouter serves POST /sessions/:session_id behind Require(scope), echoing the
+// authenticated caller and the body the handler received
+func newTestRouter(a *Authenticator, scope string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/sessions/:session_id", a.Require(scope), func(ctx *gin.Context) {
+		body, _ := io.ReadAll(ctx.Request.Body)
+		name := ""
+		if principal := PrincipalFrom(ctx); principal != nil {
+			name = principal.Name
+		}
+		ctx.JSON(http.StatusOK, gin.H{"caller": name, "body": string(body)})
+	})
+	return r
+}
+
+// signedRequest builds a request signed by the test signer at timestamp; tamper
+// changes the request after it was signed
+func signedRequest(timestamp time.Time, secret string, tamper func(*http.Request)) *http.Request {
+	body := `{"reason": "done"}`
+	req := httptest.NewRequest(http.MethodPost, "/sessions/s-1?verbose=1", strings.NewReader(body))
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	req.Header.Set(HeaderKeyID, "signer")
+	req.Header.Set(HeaderTimestamp, ts)
+	req.Header.Set(HeaderSignature, hex.EncodeToString(Sign([]byte(secret), req.Method, req.URL.RequestURI(), ts, []byte(body))))
+	if tamper != nil {
+		tamper(req)
+	}
+	return req
+}
+
+func apiKeyRequest(header, value string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/sessions/s-1", strings.NewReader(`{}`))
+	if header != "" {
+		req.Header.Set(header, value)
+	}
+	return req
+}
+
+func TestRequire(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		scope      string
+		req        *http.Request
+		wantStatus int
+		wantCaller string
+	}{
+		{"no credentials", "sessions:read", apiKeyRequest("", ""), http.StatusUnauthorized, ""},
+		{"unknown API key", "sessions:read", apiKeyRequest(HeaderAPIKey, "unknown-api-key-0123456789"), http.StatusUnauthorized, ""},
+		{"API key", "sessions:read", apiKeyRequest(HeaderAPIKey, testAPIKey), http.StatusOK, "reader"},
+		{"API key as bearer token", "sessions:read", apiKeyRequest("Authorization", "Bearer "+testAPIKey), http.StatusOK, "reader"},
+		{"API key with another authorization scheme", "sessions:read", apiKeyRequest("Authorization", "Basic "+testAPIKey), http.StatusUnauthorized, ""},
+		{"API key lacking the scope", "sessions:write", apiKeyRequest(HeaderAPIKey, testAPIKey), http.StatusForbidden, ""},
+		{"signed request", "sessions:read", signedRequest(now, testHMACSecret, nil), http.StatusOK, "signer"},
+		{"signed request within the skew", "sessions:read", signedRequest(now.Add(-testMaxSkew+time.Minute), testHMACSecret, nil), http.StatusOK, "signer"},
+		{"signed request lacking the scope", "sessions:write", signedRequest(now, testHMACSecret, nil), http.StatusForbidden, ""},
+		{"unknown key ID", "sessions:read", signedRequest(now, testHMACSecret, func(r *http.Request) {
+			r.Header.Set(HeaderKeyID, "reader")
+		}), http.StatusUnauthorized, ""},
+		{"signed with another secret", "sessions:read", signedRequest(now, "another-secret-0123456789", nil), http.StatusUnauthorized, ""},
+		{"stale timestamp", "sessions:read", signedRequest(now.Add(-testMaxSkew-time.Minute), testHMACSecret, nil), http.StatusUnauthorized, ""},
+		{"timestamp in the future", "sessions:read", signedRequest(now.Add(testMaxSkew+time.Minute), testHMACSecret, nil), http.StatusUnauthorized, ""},
+		{"timestamp not a number", "sessions:read", signedRequest(now, testHMACSecret, func(r *http.Request) {
+			r.Header.Set(HeaderTimestamp, "yesterday")
+		}), http.StatusUnauthorized, ""},
+		{"signature not hex", "sessions:read", signedRequest(now, testHMACSecret, func(r *http.Request) {
+			r.Header.Set(HeaderSignature, "not-hex")
+		}), http.StatusUnauthorized, ""},
+		{"tampered body", "sessions:read", signedRequest(now, testHMACSecret, func(r *http.Request) {
+			r.Body = io.NopCloser(strings.NewReader(`{"reason": "failed"}`))
+		}), http.StatusUnauthorized, ""},
+		{"tampered query", "sessions:read", signedRequest(now, testHMACSecret, func(r *http.Request) {
+			r.URL.RawQuery = "verbose=0"
+			r.RequestURI = r.URL.RequestURI()
+		}), http.StatusUnauthorized, ""},
+	}
+
+	a := newTestAuthenticator(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			newTestRouter(a, tt.scope).ServeHTTP(rec, tt.req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				Caller string `json:"caller"`
+				Body   string `json:"body"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			if response.Caller != tt.wantCaller {
+				t.Errorf("caller = %q, want %q", response.Caller, tt.wantCaller)
+			}
+			if response.Body == "" {
+				t.Error("handler received an empty body")
+			}
+		})
+	}
+}
+
+func TestRequireErrorBody(t *testing.T) {
+	tests := []struct {
+		name          string
+		scope         string
+		req           *http.Request
+		wantStatus    int
+		wantTitle     string
+		wantChallenge bool
+	}{
+		{"unauthenticated", "sessions:read", apiKeyRequest("", ""), http.StatusUnauthorized, "Unauthorized", true},
+		{"forbidden", "sessions:write", apiKeyRequest(HeaderAPIKey, testAPIKey), http.StatusForbidden, "Forbidden", false},
+	}
+
+	a := newTestAuthenticator(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			newTestRouter(a, tt.scope).ServeHTTP(rec, tt.req)
+
+			var problem map[string]any
+			if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+				t.Fatalf("body %q is not JSON: %v", rec.Body.String(), err)
+			}
+			want := map[string]any{
+				"type":     "https://connection-service.com/errors/" + strconv.Itoa(tt.wantStatus),
+				"title":    tt.wantTitle,
+				"status":   float64(tt.wantStatus),
+				"instance": "/sessions/s-1",
+			}
+			for field, value := range want {
+				if problem[field] != value {
+					t.Errorf("%s = %v, want %v", field, problem[field], value)
+				}
+			}
+			if detail, _ := problem["detail"].(string); detail == "" {
+				t.Error("detail is empty")
+			}
+			if got := rec.Header().Get("WWW-Authenticate") != ""; got != tt.wantChallenge {
+				t.Errorf("WWW-Authenticate sent = %v, want %v", got, tt.wantChallenge)
+			}
+		})
+	}
+}
+
+func TestRequireDisabled(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestRouter(&Authenticator{}, "sessions:write").ServeHTTP(rec, apiKeyRequest("", ""))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
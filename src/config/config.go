@@ -1,16 +1,22 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"strconv"
+	"time"
 )
 
 const (
 	CONNECTION_EXCHANGE             = "new_connections_exchange"
 	DISPATCHER_TO_CLIENT_QUEUE      = "%s_dispatcher_queue"
 	CLIENT_TO_CALIBRATION_QUEUE     = "%s_outputs_cal_queue"
+	CLIENT_OUTPUTS_EXCHANGE         = "%s_outputs_exchange"
 	DISPATCHER_TO_CALIBRATION_QUEUE = "%s_inputs_cal_queue"
+	DISPATCHER_STATUS_EXCHANGE      = "dispatcher_status_exchange"
+	DISPATCHER_STATUS_QUEUE         = "connection_service_dispatcher_status_queue"
+	USER_EVENTS_EXCHANGE            = "user_events_exchange"
 )
 
 // Interface defines the configuration contract
@@ -21,6 +27,15 @@ type Interface interface {
 	GetPort() string
 	GetMiddlewareConfig() *MiddlewareConfig
 	GetDatabaseConfig() *DatabaseConfig
+	GetReaperConfig() *ReaperConfig
+	GetOutboxConfig() *OutboxConfig
+	GetSagaConfig() *SagaConfig
+	GetUsersServiceConfig() *UsersServiceConfig
+	GetTokenConfig() *TokenConfig
+	GetAuthConfig() *AuthConfig
+	GetHealthConfig() *HealthConfig
+	GetTracingConfig() *TracingConfig
+	GetQueueConfig() *QueueConfig
 }
 
 // GlobalConfig holds all service configuration
@@ -29,28 +44,130 @@ type GlobalConfig struct {
 	podName          string
 	host             string
 	port             string
-	usersServiceURL  string
+	idempotencyTTL   time.Duration
+	idempotencyLease time.Duration
 	middlewareConfig *MiddlewareConfig
 	databaseConfig   *DatabaseConfig
+	reaperConfig     *ReaperConfig
+	outboxConfig     *OutboxConfig
+	sagaConfig       *SagaConfig
+	usersConfig      *UsersServiceConfig
+	tokenConfig      *TokenConfig
+	authConfig       *AuthConfig
+	healthConfig     *HealthConfig
+	tracingConfig    *TracingConfig
+	queueConfig      *QueueConfig
 }
 
 // DatabaseConfig holds PostgreSQL connection configuration
 type DatabaseConfig struct {
-	host     string
-	port     int32
-	user     string
-	password string
-	dbname   string
+	host        string
+	port        int32
+	user        string
+	password    string
+	dbname      string
+	autoMigrate bool
 }
 
 // MiddlewareConfig holds RabbitMQ connection configuration
 type MiddlewareConfig struct {
-	host       string
-	port       int32
-	username   string
-	password   string
-	maxRetries int
-	publicIp   string
+	host            string
+	port            int32
+	username        string
+	password        string
+	maxRetries      int
+	publicIp        string
+	heartbeat       time.Duration
+	readyTimeout    time.Duration
+	publishChannels int
+	publishTimeout  time.Duration
+	dispatcherUser  string
+}
+
+// ReaperConfig holds the configuration of the background session timeout reaper
+type ReaperConfig struct {
+	interval    time.Duration
+	maxAge      time.Duration
+	idleTimeout time.Duration
+	batchSize   int
+}
+
+// OutboxConfig holds the configuration of the transactional outbox relay
+type OutboxConfig struct {
+	relayInterval time.Duration
+	batchSize     int
+	leaseDuration time.Duration
+}
+
+// UsersServiceConfig holds the configuration of the users-service client
+type UsersServiceConfig struct {
+	url              string
+	healthPath       string
+	timeout          time.Duration
+	maxAttempts      int
+	retryBackoff     time.Duration
+	breakerThreshold int
+	breakerCooldown  time.Duration
+	cacheTTL         time.Duration
+	cacheSize        int
+}
+
+// TokenConfig holds the configuration of connection token validation
+type TokenConfig struct {
+	mode          string
+	publicKeyFile string
+	jwksFile      string
+	issuer        string
+	audience      string
+	leeway        time.Duration
+}
+
+// AuthConfig holds the configuration of service-to-service authentication
+type AuthConfig struct {
+	enabled          bool
+	credentialsFile  string
+	signatureMaxSkew time.Duration
+}
+
+// HealthConfig holds the configuration of the readiness checks and shutdown draining
+type HealthConfig struct {
+	checkTimeout      time.Duration
+	checkUsersService bool
+	drainDelay        time.Duration
+}
+
+// TracingConfig holds the configuration of OpenTelemetry tracing
+type TracingConfig struct {
+	exporter    string
+	serviceName string
+	sampleRatio float64
+}
+
+// QueueConfig holds the policies the per-client queues are declared with and the
+// shared dead-letter exchange their expired and overflowing messages are routed to
+type QueueConfig struct {
+	deadLetterExchange  string
+	deadLetterQueue     string
+	deadLetterMaxLength int
+	defaultPolicy       *QueuePolicy
+	modelTypePolicies   map[string]*QueuePolicy
+}
+
+// QueuePolicy bounds the queues of a client so a client that stops consuming
+// cannot fill the broker's disk
+type QueuePolicy struct {
+	messageTTL time.Duration
+	maxLength  int
+	overflow   string
+	queueType  string
+}
+
+// SagaConfig holds the configuration of the session start saga recovery
+type SagaConfig struct {
+	recoveryInterval time.Duration
+	staleAfter       time.Duration
+	maxAttempts      int
+	batchSize        int
 }
 
 // Getters for GlobalConfig
@@ -78,8 +195,56 @@ func (c *GlobalConfig) GetDatabaseConfig() *DatabaseConfig {
 	return c.databaseConfig
 }
 
+func (c *GlobalConfig) GetReaperConfig() *ReaperConfig {
+	return c.reaperConfig
+}
+
+func (c *GlobalConfig) GetOutboxConfig() *OutboxConfig {
+	return c.outboxConfig
+}
+
+func (c *GlobalConfig) GetSagaConfig() *SagaConfig {
+	return c.sagaConfig
+}
+
+func (c *GlobalConfig) GetUsersServiceConfig() *UsersServiceConfig {
+	return c.usersConfig
+}
+
+func (c *GlobalConfig) GetTokenConfig() *TokenConfig {
+	return c.tokenConfig
+}
+
+func (c *GlobalConfig) GetAuthConfig() *AuthConfig {
+	return c.authConfig
+}
+
+func (c *GlobalConfig) GetHealthConfig() *HealthConfig {
+	return c.healthConfig
+}
+
+func (c *GlobalConfig) GetTracingConfig() *TracingConfig {
+	return c.tracingConfig
+}
+
+// GetUsersServiceURL returns the users-service base URL from config
+func (c *GlobalConfig) GetQueueConfig() *QueueConfig {
+	return c.queueConfig
+}
+
 func (c *GlobalConfig) GetUsersServiceURL() string {
-	return c.usersServiceURL
+	return c.usersConfig.GetURL()
+}
+
+// GetIdempotencyKeyTTL returns how long the outcome of an idempotent request is replayed
+func (c *GlobalConfig) GetIdempotencyKeyTTL() time.Duration {
+	return c.idempotencyTTL
+}
+
+// GetIdempotencyKeyLease returns how long a request holds its Idempotency-Key before a
+// retry may take it over, in case the replica processing it died
+func (c *GlobalConfig) GetIdempotencyKeyLease() time.Duration {
+	return c.idempotencyLease
 }
 
 // Getters for DatabaseConfig
@@ -103,6 +268,11 @@ func (d *DatabaseConfig) GetDBName() string {
 	return d.dbname
 }
 
+// GetAutoMigrate reports whether pending migrations are applied on startup
+func (d *DatabaseConfig) GetAutoMigrate() bool {
+	return d.autoMigrate
+}
+
 // Getters for MiddlewareConfig
 func (m *MiddlewareConfig) GetHost() string {
 	return m.host
@@ -128,6 +298,249 @@ func (m *MiddlewareConfig) GetPublicIp() string {
 	return m.publicIp
 }
 
+func (m *MiddlewareConfig) GetHeartbeat() time.Duration {
+	return m.heartbeat
+}
+
+// GetReadyTimeout returns how long a caller waits for a lost RabbitMQ connection to
+// be re-established before failing
+func (m *MiddlewareConfig) GetReadyTimeout() time.Duration {
+	return m.readyTimeout
+}
+
+// GetPublishChannels returns the number of channels publishes are spread over
+func (m *MiddlewareConfig) GetPublishChannels() int {
+	return m.publishChannels
+}
+
+// GetPublishTimeout returns how long a publish may wait for the broker confirmation,
+// unless the caller's context expires first
+func (m *MiddlewareConfig) GetPublishTimeout() time.Duration {
+	return m.publishTimeout
+}
+
+// GetDispatcherUser returns the broker user the dispatcher publishes status reports as
+func (m *MiddlewareConfig) GetDispatcherUser() string {
+	return m.dispatcherUser
+}
+
+// Getters for ReaperConfig
+func (r *ReaperConfig) GetInterval() time.Duration {
+	return r.interval
+}
+
+// GetMaxAge returns the maximum lifetime of an IN_PROGRESS session (0 disables the check)
+func (r *ReaperConfig) GetMaxAge() time.Duration {
+	return r.maxAge
+}
+
+// GetIdleTimeout returns the maximum time without session activity (0 disables the check)
+func (r *ReaperConfig) GetIdleTimeout() time.Duration {
+	return r.idleTimeout
+}
+
+func (r *ReaperConfig) GetBatchSize() int {
+	return r.batchSize
+}
+
+// Getters for OutboxConfig
+func (o *OutboxConfig) GetRelayInterval() time.Duration {
+	return o.relayInterval
+}
+
+func (o *OutboxConfig) GetBatchSize() int {
+	return o.batchSize
+}
+
+// GetLeaseDuration returns how long a claimed message is left to its publisher before
+// another relay may publish it
+func (o *OutboxConfig) GetLeaseDuration() time.Duration {
+	return o.leaseDuration
+}
+
+// Getters for UsersServiceConfig
+func (u *UsersServiceConfig) GetURL() string {
+	return u.url
+}
+
+// GetHealthPath returns the users-service path probed by the readiness check
+func (u *UsersServiceConfig) GetHealthPath() string {
+	return u.healthPath
+}
+
+// GetTimeout returns the timeout of a single users-service request
+func (u *UsersServiceConfig) GetTimeout() time.Duration {
+	return u.timeout
+}
+
+// GetMaxAttempts returns how many times an idempotent call is tried before giving up
+func (u *UsersServiceConfig) GetMaxAttempts() int {
+	return u.maxAttempts
+}
+
+// GetRetryBackoff returns the base delay between retries, doubled on every attempt and jittered
+func (u *UsersServiceConfig) GetRetryBackoff() time.Duration {
+	return u.retryBackoff
+}
+
+// GetBreakerThreshold returns how many consecutive failures open the circuit breaker
+func (u *UsersServiceConfig) GetBreakerThreshold() int {
+	return u.breakerThreshold
+}
+
+// GetBreakerCooldown returns how long the open circuit breaker fails fast before a trial call
+func (u *UsersServiceConfig) GetBreakerCooldown() time.Duration {
+	return u.breakerCooldown
+}
+
+// GetCacheTTL returns how long user profiles are cached (0 disables the cache)
+func (u *UsersServiceConfig) GetCacheTTL() time.Duration {
+	return u.cacheTTL
+}
+
+// GetCacheSize returns the maximum number of cached user profiles
+func (u *UsersServiceConfig) GetCacheSize() int {
+	return u.cacheSize
+}
+
+// Getters for TokenConfig
+
+// GetMode returns the token validation mode: remote, jwt or jwt_with_fallback
+func (t *TokenConfig) GetMode() string {
+	return t.mode
+}
+
+func (t *TokenConfig) GetPublicKeyFile() string {
+	return t.publicKeyFile
+}
+
+func (t *TokenConfig) GetJWKSFile() string {
+	return t.jwksFile
+}
+
+// GetIssuer returns the required iss claim (empty to skip the check)
+func (t *TokenConfig) GetIssuer() string {
+	return t.issuer
+}
+
+// GetAudience returns the required aud claim (empty to skip the check)
+func (t *TokenConfig) GetAudience() string {
+	return t.audience
+}
+
+// GetLeeway returns the clock skew tolerated when checking exp and nbf
+func (t *TokenConfig) GetLeeway() time.Duration {
+	return t.leeway
+}
+
+// Getters for AuthConfig
+
+// GetEnabled reports whether the session management endpoints require credentials
+func (a *AuthConfig) GetEnabled() bool {
+	return a.enabled
+}
+
+// GetCredentialsFile returns the JSON file holding the API keys and HMAC secrets of the callers
+func (a *AuthConfig) GetCredentialsFile() string {
+	return a.credentialsFile
+}
+
+// GetSignatureMaxSkew returns how far the timestamp of a signed request may be from the local clock
+func (a *AuthConfig) GetSignatureMaxSkew() time.Duration {
+	return a.signatureMaxSkew
+}
+
+// Getters for HealthConfig
+
+// GetCheckTimeout returns how long a single readiness dependency check may take
+func (h *HealthConfig) GetCheckTimeout() time.Duration {
+	return h.checkTimeout
+}
+
+// GetCheckUsersService reports whether readiness also probes users-service (as an optional dependency)
+func (h *HealthConfig) GetCheckUsersService() bool {
+	return h.checkUsersService
+}
+
+// GetDrainDelay returns how long readiness fails before the HTTP server stops on shutdown
+func (h *HealthConfig) GetDrainDelay() time.Duration {
+	return h.drainDelay
+}
+
+// Getters for TracingConfig
+
+// GetExporter returns where spans are sent: none, stdout or otlp
+func (t *TracingConfig) GetExporter() string {
+	return t.exporter
+}
+
+func (t *TracingConfig) GetServiceName() string {
+	return t.serviceName
+}
+
+// GetSampleRatio returns the fraction of new traces that are recorded; child spans follow their parent
+func (t *TracingConfig) GetSampleRatio() float64 {
+	return t.sampleRatio
+}
+
+// Getters for QueueConfig
+func (q *QueueConfig) GetDeadLetterExchange() string {
+	return q.deadLetterExchange
+}
+
+func (q *QueueConfig) GetDeadLetterQueue() string {
+	return q.deadLetterQueue
+}
+
+func (q *QueueConfig) GetDeadLetterMaxLength() int {
+	return q.deadLetterMaxLength
+}
+
+// GetPolicy returns the queue policy of a model type, or the default policy when
+// the model type has no override
+func (q *QueueConfig) GetPolicy(modelType string) *QueuePolicy {
+	if policy, ok := q.modelTypePolicies[modelType]; ok {
+		return policy
+	}
+	return q.defaultPolicy
+}
+
+// GetMessageTTL returns how long a message may wait in a queue; 0 means forever
+func (p *QueuePolicy) GetMessageTTL() time.Duration {
+	return p.messageTTL
+}
+
+func (p *QueuePolicy) GetMaxLength() int {
+	return p.maxLength
+}
+
+func (p *QueuePolicy) GetOverflow() string {
+	return p.overflow
+}
+
+func (p *QueuePolicy) GetQueueType() string {
+	return p.queueType
+}
+
+// Getters for SagaConfig
+func (s *SagaConfig) GetRecoveryInterval() time.Duration {
+	return s.recoveryInterval
+}
+
+// GetStaleAfter returns how long an unfinished saga may go without progress before recovery takes it over
+func (s *SagaConfig) GetStaleAfter() time.Duration {
+	return s.staleAfter
+}
+
+// GetMaxAttempts returns how many failed recovery attempts a saga gets before it is compensated
+func (s *SagaConfig) GetMaxAttempts() int {
+	return s.maxAttempts
+}
+
+func (s *SagaConfig) GetBatchSize() int {
+	return s.batchSize
+}
+
 func (c *GlobalConfig) GetRabbitPublicIp() string {
 	return c.middlewareConfig.GetPublicIp()
 }
@@ -173,6 +586,36 @@ func NewConfig() (*GlobalConfig, error) {
 		return nil, fmt.Errorf("RABBITMQ_PASSWORD environment variable is required")
 	}
 
+	rabbitHeartbeat, err := getDurationEnv("RABBITMQ_HEARTBEAT", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	rabbitReadyTimeout, err := getDurationEnv("RABBITMQ_READY_TIMEOUT", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	rabbitPublishChannels, err := getIntEnv("RABBITMQ_PUBLISH_CHANNELS", 4)
+	if err != nil {
+		return nil, err
+	}
+
+	rabbitPublishTimeout, err := getDurationEnv("RABBITMQ_PUBLISH_TIMEOUT", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if rabbitPublishTimeout <= 0 {
+		return nil, fmt.Errorf("RABBITMQ_PUBLISH_TIMEOUT must be greater than zero")
+	}
+
+	// Status reports are only accepted from this broker user, which RabbitMQ vouches for
+	// through the user_id property
+	dispatcherUser := os.Getenv("DISPATCHER_RABBITMQ_USER")
+	if dispatcherUser == "" {
+		dispatcherUser = "dispatcher"
+	}
+
 	// Set log level from environment
 	logLevel := os.Getenv("LOG_LEVEL")
 	if logLevel == "" {
@@ -195,13 +638,521 @@ func NewConfig() (*GlobalConfig, error) {
 		return nil, fmt.Errorf("PORT environment variable is required")
 	}
 
-	// Get Users Service URL from environment
+	// Get Users Service URL and client settings from environment
+	usersConfig, err := newUsersServiceConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Get connection token validation settings from environment (optional)
+	tokenConfig, err := newTokenConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Get service-to-service authentication settings from environment
+	authConfig, err := newAuthConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Get readiness check and draining settings from environment (optional)
+	healthConfig, err := newHealthConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Get tracing settings from environment (optional)
+	tracingConfig, err := newTracingConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Get per-client queue policies from environment (optional)
+	queueConfig, err := newQueueConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Get Idempotency-Key retention from environment (optional)
+	idempotencyTTL, err := getDurationEnv("IDEMPOTENCY_KEY_TTL", 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	if idempotencyTTL <= 0 {
+		return nil, fmt.Errorf("IDEMPOTENCY_KEY_TTL must be greater than zero")
+	}
+
+	idempotencyLease, err := getDurationEnv("IDEMPOTENCY_KEY_LEASE", time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	if idempotencyLease <= 0 || idempotencyLease >= idempotencyTTL {
+		return nil, fmt.Errorf("IDEMPOTENCY_KEY_LEASE must be greater than zero and less than IDEMPOTENCY_KEY_TTL")
+	}
+
+	// Get PostgreSQL connection details from environment
+	databaseConfig, err := NewDatabaseConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Get session reaper settings from environment (optional)
+	reaperInterval, err := getDurationEnv("SESSION_REAPER_INTERVAL", time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	if reaperInterval <= 0 {
+		return nil, fmt.Errorf("SESSION_REAPER_INTERVAL must be greater than zero")
+	}
+
+	// Disabled by default: a long-running session stays IN_PROGRESS however old it is
+	sessionMaxAge, err := getDurationEnv("SESSION_MAX_AGE", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// Enabled by default, so sessions whose client and dispatcher went away do not stay IN_PROGRESS forever
+	sessionIdleTimeout, err := getDurationEnv("SESSION_IDLE_TIMEOUT", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	reaperBatchSize, err := getIntEnv("SESSION_REAPER_BATCH_SIZE", 100)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get outbox relay settings from environment (optional)
+	outboxRelayInterval, err := getDurationEnv("OUTBOX_RELAY_INTERVAL", 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if outboxRelayInterval <= 0 {
+		return nil, fmt.Errorf("OUTBOX_RELAY_INTERVAL must be greater than zero")
+	}
+
+	outboxBatchSize, err := getIntEnv("OUTBOX_RELAY_BATCH_SIZE", 50)
+	if err != nil {
+		return nil, err
+	}
+
+	outboxLeaseDuration, err := getDurationEnv("OUTBOX_LEASE_DURATION", 2*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	if outboxLeaseDuration <= rabbitPublishTimeout {
+		return nil, fmt.Errorf("OUTBOX_LEASE_DURATION must be greater than RABBITMQ_PUBLISH_TIMEOUT")
+	}
+
+	// Get session start saga recovery settings from environment (optional)
+	sagaRecoveryInterval, err := getDurationEnv("SAGA_RECOVERY_INTERVAL", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if sagaRecoveryInterval <= 0 {
+		return nil, fmt.Errorf("SAGA_RECOVERY_INTERVAL must be greater than zero")
+	}
+
+	sagaStaleAfter, err := getDurationEnv("SAGA_STALE_AFTER", 2*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	if sagaStaleAfter <= 0 {
+		return nil, fmt.Errorf("SAGA_STALE_AFTER must be greater than zero")
+	}
+
+	sagaMaxAttempts, err := getIntEnv("SAGA_MAX_ATTEMPTS", 5)
+	if err != nil {
+		return nil, err
+	}
+
+	sagaBatchSize, err := getIntEnv("SAGA_RECOVERY_BATCH_SIZE", 20)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create middleware config
+	middlewareConfig := &MiddlewareConfig{
+		host:            rabbitHost,
+		port:            int32(rabbitPort),
+		username:        rabbitUser,
+		password:        rabbitPass,
+		maxRetries:      5, // default max retries
+		publicIp:        rabbitPublicIp,
+		heartbeat:       rabbitHeartbeat,
+		readyTimeout:    rabbitReadyTimeout,
+		publishChannels: rabbitPublishChannels,
+		publishTimeout:  rabbitPublishTimeout,
+		dispatcherUser:  dispatcherUser,
+	}
+
+	// Create reaper config
+	reaperConfig := &ReaperConfig{
+		interval:    reaperInterval,
+		maxAge:      sessionMaxAge,
+		idleTimeout: sessionIdleTimeout,
+		batchSize:   reaperBatchSize,
+	}
+
+	// Create outbox config
+	outboxConfig := &OutboxConfig{
+		relayInterval: outboxRelayInterval,
+		batchSize:     outboxBatchSize,
+		leaseDuration: outboxLeaseDuration,
+	}
+
+	// Create saga config
+	sagaConfig := &SagaConfig{
+		recoveryInterval: sagaRecoveryInterval,
+		staleAfter:       sagaStaleAfter,
+		maxAttempts:      sagaMaxAttempts,
+		batchSize:        sagaBatchSize,
+	}
+
+	return &GlobalConfig{
+		logLevel:         logLevel,
+		podName:          podName,
+		host:             host,
+		port:             port,
+		idempotencyTTL:   idempotencyTTL,
+		idempotencyLease: idempotencyLease,
+		middlewareConfig: middlewareConfig,
+		databaseConfig:   databaseConfig,
+		reaperConfig:     reaperConfig,
+		outboxConfig:     outboxConfig,
+		sagaConfig:       sagaConfig,
+		usersConfig:      usersConfig,
+		tokenConfig:      tokenConfig,
+		authConfig:       authConfig,
+		healthConfig:     healthConfig,
+		tracingConfig:    tracingConfig,
+		queueConfig:      queueConfig,
+	}, nil
+}
+
+// newUsersServiceConfig loads the users-service client configuration from the environment
+func newUsersServiceConfig() (*UsersServiceConfig, error) {
 	usersServiceURL := os.Getenv("USERS_SERVICE_URL")
 	if usersServiceURL == "" {
 		return nil, fmt.Errorf("USERS_SERVICE_URL environment variable is required")
 	}
 
-	// Get PostgreSQL connection details from environment
+	healthPath := os.Getenv("USERS_SERVICE_HEALTH_PATH")
+	if healthPath == "" {
+		healthPath = "/health"
+	}
+
+	timeout, err := getDurationEnv("USERS_SERVICE_TIMEOUT", 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if timeout <= 0 {
+		return nil, fmt.Errorf("USERS_SERVICE_TIMEOUT must be greater than zero")
+	}
+
+	maxAttempts, err := getIntEnv("USERS_SERVICE_MAX_ATTEMPTS", 3)
+	if err != nil {
+		return nil, err
+	}
+
+	retryBackoff, err := getDurationEnv("USERS_SERVICE_RETRY_BACKOFF", 100*time.Millisecond)
+	if err != nil {
+		return nil, err
+	}
+
+	breakerThreshold, err := getIntEnv("USERS_SERVICE_BREAKER_THRESHOLD", 5)
+	if err != nil {
+		return nil, err
+	}
+
+	breakerCooldown, err := getDurationEnv("USERS_SERVICE_BREAKER_COOLDOWN", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheTTL, err := getDurationEnv("USER_CACHE_TTL", 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheSize, err := getIntEnv("USER_CACHE_SIZE", 10000)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UsersServiceConfig{
+		url:              usersServiceURL,
+		healthPath:       healthPath,
+		timeout:          timeout,
+		maxAttempts:      maxAttempts,
+		retryBackoff:     retryBackoff,
+		breakerThreshold: breakerThreshold,
+		breakerCooldown:  breakerCooldown,
+		cacheTTL:         cacheTTL,
+		cacheSize:        cacheSize,
+	}, nil
+}
+
+// newTokenConfig loads the connection token validation configuration from the environment
+func newTokenConfig() (*TokenConfig, error) {
+	mode := os.Getenv("TOKEN_VALIDATION_MODE")
+	if mode == "" {
+		mode = "remote"
+	}
+	if mode != "remote" && mode != "jwt" && mode != "jwt_with_fallback" {
+		return nil, fmt.Errorf("TOKEN_VALIDATION_MODE must be remote, jwt or jwt_with_fallback")
+	}
+
+	publicKeyFile := os.Getenv("TOKEN_JWT_PUBLIC_KEY_FILE")
+	jwksFile := os.Getenv("TOKEN_JWKS_FILE")
+	if mode != "remote" && publicKeyFile == "" && jwksFile == "" {
+		return nil, fmt.Errorf("TOKEN_JWT_PUBLIC_KEY_FILE or TOKEN_JWKS_FILE is required when TOKEN_VALIDATION_MODE is %s", mode)
+	}
+
+	leeway, err := getDurationEnv("TOKEN_JWT_LEEWAY", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenConfig{
+		mode:          mode,
+		publicKeyFile: publicKeyFile,
+		jwksFile:      jwksFile,
+		issuer:        os.Getenv("TOKEN_JWT_ISSUER"),
+		audience:      os.Getenv("TOKEN_JWT_AUDIENCE"),
+		leeway:        leeway,
+	}, nil
+}
+
+// newAuthConfig loads the service-to-service authentication configuration from the environment
+func newAuthConfig() (*AuthConfig, error) {
+	enabled, err := getBoolEnv("AUTH_ENABLED", true)
+	if err != nil {
+		return nil, err
+	}
+
+	credentialsFile := os.Getenv("AUTH_CREDENTIALS_FILE")
+	if enabled && credentialsFile == "" {
+		return nil, fmt.Errorf("AUTH_CREDENTIALS_FILE is required when AUTH_ENABLED is true")
+	}
+
+	maxSkew, err := getDurationEnv("AUTH_SIGNATURE_MAX_SKEW", 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	if maxSkew <= 0 {
+		return nil, fmt.Errorf("AUTH_SIGNATURE_MAX_SKEW must be greater than zero")
+	}
+
+	return &AuthConfig{
+		enabled:          enabled,
+		credentialsFile:  credentialsFile,
+		signatureMaxSkew: maxSkew,
+	}, nil
+}
+
+// newHealthConfig loads the readiness check configuration from the environment
+func newHealthConfig() (*HealthConfig, error) {
+	checkTimeout, err := getDurationEnv("READINESS_CHECK_TIMEOUT", 2*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if checkTimeout <= 0 {
+		return nil, fmt.Errorf("READINESS_CHECK_TIMEOUT must be greater than zero")
+	}
+
+	checkUsersService, err := getBoolEnv("READINESS_CHECK_USERS_SERVICE", false)
+	if err != nil {
+		return nil, err
+	}
+
+	drainDelay, err := getDurationEnv("SHUTDOWN_DRAIN_DELAY", 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	return &HealthConfig{
+		checkTimeout:      checkTimeout,
+		checkUsersService: checkUsersService,
+		drainDelay:        drainDelay,
+	}, nil
+}
+
+// newTracingConfig loads the tracing configuration from the environment
+func newTracingConfig() (*TracingConfig, error) {
+	exporter := os.Getenv("TRACING_EXPORTER")
+	if exporter == "" {
+		exporter = "none"
+	}
+	if exporter != "none" && exporter != "stdout" && exporter != "otlp" {
+		return nil, fmt.Errorf("TRACING_EXPORTER must be none, stdout or otlp")
+	}
+
+	serviceName := os.Getenv("OTEL_SERVICE_NAME")
+	if serviceName == "" {
+		serviceName = "connection-service"
+	}
+
+	sampleRatio := 1.0
+	if value := os.Getenv("TRACING_SAMPLE_RATIO"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("TRACING_SAMPLE_RATIO must be a valid number: %w", err)
+		}
+		if parsed < 0 || parsed > 1 {
+			return nil, fmt.Errorf("TRACING_SAMPLE_RATIO must be between 0 and 1")
+		}
+		sampleRatio = parsed
+	}
+
+	return &TracingConfig{
+		exporter:    exporter,
+		serviceName: serviceName,
+		sampleRatio: sampleRatio,
+	}, nil
+}
+
+// newQueueConfig loads the per-client queue policies from the environment, with the
+// per-model-type overrides of QUEUE_POLICIES_FILE when it is set
+func newQueueConfig() (*QueueConfig, error) {
+	messageTTL, err := getDurationEnv("CLIENT_QUEUE_MESSAGE_TTL", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	maxLength, err := getIntEnv("CLIENT_QUEUE_MAX_LENGTH", 100000)
+	if err != nil {
+		return nil, err
+	}
+
+	overflow := os.Getenv("CLIENT_QUEUE_OVERFLOW")
+	if overflow == "" {
+		overflow = "drop-head"
+	}
+
+	queueType := os.Getenv("CLIENT_QUEUE_TYPE")
+	if queueType == "" {
+		queueType = "classic"
+	}
+
+	defaultPolicy := &QueuePolicy{
+		messageTTL: messageTTL,
+		maxLength:  maxLength,
+		overflow:   overflow,
+		queueType:  queueType,
+	}
+	if err := defaultPolicy.validate(); err != nil {
+		return nil, fmt.Errorf("invalid default client queue policy: %w", err)
+	}
+
+	deadLetterExchange := os.Getenv("DEAD_LETTER_EXCHANGE")
+	if deadLetterExchange == "" {
+		deadLetterExchange = "dead_letter_exchange"
+	}
+
+	deadLetterQueue := os.Getenv("DEAD_LETTER_QUEUE")
+	if deadLetterQueue == "" {
+		deadLetterQueue = "dead_letter_queue"
+	}
+
+	deadLetterMaxLength, err := getIntEnv("DEAD_LETTER_QUEUE_MAX_LENGTH", 100000)
+	if err != nil {
+		return nil, err
+	}
+
+	modelTypePolicies := map[string]*QueuePolicy{}
+	if path := os.Getenv("QUEUE_POLICIES_FILE"); path != "" {
+		modelTypePolicies, err = loadQueuePolicies(path, defaultPolicy)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &QueueConfig{
+		deadLetterExchange:  deadLetterExchange,
+		deadLetterQueue:     deadLetterQueue,
+		deadLetterMaxLength: deadLetterMaxLength,
+		defaultPolicy:       defaultPolicy,
+		modelTypePolicies:   modelTypePolicies,
+	}, nil
+}
+
+// queuePolicyOverride is a per-model-type entry of the queue policies file
+// Fields left out keep the value of the default policy
+type queuePolicyOverride struct {
+	MessageTTL *string `json:"message_ttl"`
+	MaxLength  *int    `json:"max_length"`
+	Overflow   *string `json:"overflow"`
+	QueueType  *string `json:"queue_type"`
+}
+
+// loadQueuePolicies reads the queue policies file, a JSON document such as
+// {"model_types": {"llm": {"message_ttl": "10m", "max_length": 5000, "queue_type": "quorum"}}}
+func loadQueuePolicies(path string, defaultPolicy *QueuePolicy) (map[string]*QueuePolicy, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open QUEUE_POLICIES_FILE: %w", err)
+	}
+	defer file.Close()
+
+	var document struct {
+		ModelTypes map[string]queuePolicyOverride `json:"model_types"`
+	}
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&document); err != nil {
+		return nil, fmt.Errorf("failed to parse QUEUE_POLICIES_FILE: %w", err)
+	}
+
+	policies := make(map[string]*QueuePolicy, len(document.ModelTypes))
+	for modelType, override := range document.ModelTypes {
+		policy := *defaultPolicy
+		if override.MessageTTL != nil {
+			ttl, err := time.ParseDuration(*override.MessageTTL)
+			if err != nil || ttl < 0 {
+				return nil, fmt.Errorf("invalid queue policy for model type %s: message_ttl must be a non-negative duration", modelType)
+			}
+			policy.messageTTL = ttl
+		}
+		if override.MaxLength != nil {
+			policy.maxLength = *override.MaxLength
+		}
+		if override.Overflow != nil {
+			policy.overflow = *override.Overflow
+		}
+		if override.QueueType != nil {
+			policy.queueType = *override.QueueType
+		}
+		if err := policy.validate(); err != nil {
+			return nil, fmt.Errorf("invalid queue policy for model type %s: %w", modelType, err)
+		}
+		policies[modelType] = &policy
+	}
+	return policies, nil
+}
+
+// validate rejects unbounded queues and the combinations RabbitMQ would refuse when
+// declaring the queue
+func (p *QueuePolicy) validate() error {
+	if p.maxLength <= 0 {
+		return fmt.Errorf("max length must be greater than zero")
+	}
+	if p.overflow != "drop-head" && p.overflow != "reject-publish" && p.overflow != "reject-publish-dlx" {
+		return fmt.Errorf("overflow must be drop-head, reject-publish or reject-publish-dlx")
+	}
+	if p.queueType != "classic" && p.queueType != "quorum" {
+		return fmt.Errorf("queue type must be classic or quorum")
+	}
+	if p.queueType == "quorum" && p.overflow == "reject-publish-dlx" {
+		return fmt.Errorf("quorum queues do not support the reject-publish-dlx overflow")
+	}
+	return nil
+}
+
+// NewDatabaseConfig loads the PostgreSQL configuration from the environment
+// It is used on its own by the migrate subcommand, which needs no other settings
+func NewDatabaseConfig() (*DatabaseConfig, error) {
 	postgresHost := os.Getenv("POSTGRES_HOST")
 	if postgresHost == "" {
 		return nil, fmt.Errorf("POSTGRES_HOST environment variable is required")
@@ -231,32 +1182,63 @@ func NewConfig() (*GlobalConfig, error) {
 		return nil, fmt.Errorf("POSTGRES_DB environment variable is required")
 	}
 
-	// Create middleware config
-	middlewareConfig := &MiddlewareConfig{
-		host:       rabbitHost,
-		port:       int32(rabbitPort),
-		username:   rabbitUser,
-		password:   rabbitPass,
-		maxRetries: 5, // default max retries
-		publicIp:   rabbitPublicIp,
+	// Apply pending migrations on startup unless disabled (e.g. when run as a separate job)
+	autoMigrate, err := getBoolEnv("DB_AUTO_MIGRATE", true)
+	if err != nil {
+		return nil, err
 	}
 
-	// Create database config
-	databaseConfig := &DatabaseConfig{
-		host:     postgresHost,
-		port:     int32(postgresPort),
-		user:     postgresUser,
-		password: postgresPass,
-		dbname:   postgresDB,
+	return &DatabaseConfig{
+		host:        postgresHost,
+		port:        int32(postgresPort),
+		user:        postgresUser,
+		password:    postgresPass,
+		dbname:      postgresDB,
+		autoMigrate: autoMigrate,
+	}, nil
+}
+
+// getBoolEnv parses an optional boolean environment variable
+func getBoolEnv(key string, fallback bool) (bool, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
 	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s must be a valid boolean: %w", key, err)
+	}
+	return parsed, nil
+}
 
-	return &GlobalConfig{
-		logLevel:         logLevel,
-		podName:          podName,
-		host:             host,
-		port:             port,
-		usersServiceURL:  usersServiceURL,
-		middlewareConfig: middlewareConfig,
-		databaseConfig:   databaseConfig,
-	}, nil
+// getDurationEnv parses an optional duration environment variable (e.g. "30s", "5m")
+func getDurationEnv(key string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a valid duration: %w", key, err)
+	}
+	if duration < 0 {
+		return 0, fmt.Errorf("%s must not be negative", key)
+	}
+	return duration, nil
+}
+
+// getIntEnv parses an optional positive integer environment variable
+func getIntEnv(key string, fallback int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a valid integer: %w", key, err)
+	}
+	if parsed <= 0 {
+		return 0, fmt.Errorf("%s must be greater than zero", key)
+	}
+	return parsed, nil
 }
@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"net/http"
+
+	"connection-service/src/health"
+	"connection-service/src/schemas"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthController struct {
+	Checker *health.Checker
+}
+
+func NewHealthController(checker *health.Checker) *HealthController {
+	return &HealthController{
+		Checker: checker,
+	}
+}
+
+// Liveness reports that the process is up; it never checks dependencies, so an
+// outage of Postgres or RabbitMQ does not get the pod restarted
+func (hc *HealthController) Liveness(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, schemas.LivenessResponse{Status: "ok"})
+}
+
+// Readiness reports whether the service can take traffic, with the state of every
+// dependency. It answers 503 if a required dependency is down or the server is draining
+func (hc *HealthController) Readiness(ctx *gin.Context) {
+	report := hc.Checker.Check(ctx.Request.Context())
+	if !report.Ready() {
+		ctx.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+	ctx.JSON(http.StatusOK, report)
+}
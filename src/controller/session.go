@@ -2,10 +2,14 @@ package controller
 
 import (
 	"errors"
+	"io"
 	"log/slog"
 	"net/http"
 
+	"connection-service/src/auth"
 	"connection-service/src/config"
+	"connection-service/src/models"
+	"connection-service/src/requestid"
 	"connection-service/src/schemas"
 	"connection-service/src/service"
 
@@ -30,8 +34,8 @@ func NewSessionController(service *service.SessionService, connectionService *se
 func (sc *SessionController) Start(ctx *gin.Context) {
 	var reqBody schemas.ConnectRequest
 	if err := ctx.ShouldBindJSON(&reqBody); err != nil {
-		slog.Error("Invalid JSON format", "error", err)
-		ctx.JSON(http.StatusBadRequest, schemas.NewBadRequestError(
+		slog.ErrorContext(ctx.Request.Context(), "Invalid JSON format", "error", err)
+		writeError(ctx, schemas.NewBadRequestError(
 			"Invalid JSON format: "+err.Error(),
 			"/sessions/start",
 		))
@@ -39,26 +43,77 @@ func (sc *SessionController) Start(ctx *gin.Context) {
 	}
 
 	// Delegate all business logic to the service layer
-	response, err := sc.ConnectionService.HandleClientConnection(ctx.Request.Context(), reqBody.UserID, reqBody.Token)
+	// Retries carrying the same Idempotency-Key get the first outcome replayed
+	idempotencyKey := ctx.GetHeader("Idempotency-Key")
+	response, err := sc.ConnectionService.HandleClientConnection(ctx.Request.Context(), reqBody.UserID, reqBody.Token, idempotencyKey)
 	if err != nil {
 		// Check if the error is an ErrorResponse (from schemas)
 		var apiError *schemas.ErrorResponse
 		if errors.As(err, &apiError) {
-			slog.Error("Connection failed", "error", apiError, "user_id", reqBody.UserID, "status", apiError.Status)
-			ctx.JSON(apiError.Status, apiError)
+			slog.ErrorContext(ctx.Request.Context(), "Connection failed", "error", apiError, "user_id", reqBody.UserID, "status", apiError.Status)
+			writeError(ctx, apiError)
 			return
 		}
 
 		// Unknown error - return 500 Internal Server Error
-		slog.Error("Internal error during connection", "error", err, "user_id", reqBody.UserID)
-		ctx.JSON(http.StatusInternalServerError, schemas.NewInternalError(
+		slog.ErrorContext(ctx.Request.Context(), "Internal error during connection", "error", err, "user_id", reqBody.UserID)
+		writeError(ctx, schemas.NewInternalError(
 			err.Error(),
 			"/sessions/start",
 		))
 		return
 	}
 
-	slog.Info("Client connected successfully", "user_id", reqBody.UserID)
+	slog.InfoContext(ctx.Request.Context(), "Client connected successfully", "user_id", reqBody.UserID)
+	ctx.JSON(http.StatusOK, response)
+}
+
+// GetSession returns a single session by ID
+func (sc *SessionController) GetSession(ctx *gin.Context) {
+	sessionID := ctx.Param("session_id")
+
+	session, err := sc.Service.GetSession(ctx.Request.Context(), sessionID)
+	if err != nil {
+		var apiError *schemas.ErrorResponse
+		if errors.As(err, &apiError) {
+			writeError(ctx, apiError)
+			return
+		}
+		writeError(ctx, schemas.NewInternalError(
+			err.Error(),
+			"/sessions/"+sessionID,
+		))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, session)
+}
+
+// ListSessions returns a filtered, cursor-paginated list of sessions
+func (sc *SessionController) ListSessions(ctx *gin.Context) {
+	var query schemas.ListSessionsQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		writeError(ctx, schemas.NewBadRequestError(
+			"Invalid query parameters: "+err.Error(),
+			"/sessions",
+		))
+		return
+	}
+
+	response, err := sc.Service.ListSessions(ctx.Request.Context(), query)
+	if err != nil {
+		var apiError *schemas.ErrorResponse
+		if errors.As(err, &apiError) {
+			writeError(ctx, apiError)
+			return
+		}
+		writeError(ctx, schemas.NewInternalError(
+			err.Error(),
+			"/sessions",
+		))
+		return
+	}
+
 	ctx.JSON(http.StatusOK, response)
 }
 
@@ -66,14 +121,19 @@ func (sc *SessionController) Start(ctx *gin.Context) {
 func (sc *SessionController) SetSessionStatusToCompleted(ctx *gin.Context) {
 	sessionID := ctx.Param("session_id")
 
-	err := sc.Service.SetSessionStatusToCompleted(ctx.Request.Context(), sessionID)
+	change, ok := bindStatusChange(ctx, "/sessions/"+sessionID+"/status/completed")
+	if !ok {
+		return
+	}
+
+	err := sc.Service.SetSessionStatusToCompleted(ctx.Request.Context(), sessionID, change)
 	if err != nil {
 		var apiError *schemas.ErrorResponse
 		if errors.As(err, &apiError) {
-			ctx.JSON(apiError.Status, apiError)
+			writeError(ctx, apiError)
 			return
 		}
-		ctx.JSON(http.StatusInternalServerError, schemas.NewInternalError(
+		writeError(ctx, schemas.NewInternalError(
 			err.Error(),
 			"/sessions/"+sessionID+"/status/completed",
 		))
@@ -91,17 +151,22 @@ func (sc *SessionController) SetSessionStatusToCompleted(ctx *gin.Context) {
 func (sc *SessionController) SetSessionStatusToTimeout(ctx *gin.Context) {
 	sessionID := ctx.Param("session_id")
 
-	err := sc.Service.SetSessionStatusToTimeout(ctx.Request.Context(), sessionID)
+	change, ok := bindStatusChange(ctx, "/sessions/"+sessionID+"/status/timeout")
+	if !ok {
+		return
+	}
+
+	err := sc.Service.SetSessionStatusToTimeout(ctx.Request.Context(), sessionID, change)
 	if err != nil {
 		// Check if the error is an ErrorResponse (from schemas)
 		var apiError *schemas.ErrorResponse
 		if errors.As(err, &apiError) {
-			ctx.JSON(apiError.Status, apiError)
+			writeError(ctx, apiError)
 			return
 		}
 
 		// Unknown error - return 500 Internal Server Error
-		ctx.JSON(http.StatusInternalServerError, schemas.NewInternalError(
+		writeError(ctx, schemas.NewInternalError(
 			err.Error(),
 			"/sessions/"+sessionID+"/status/timeout",
 		))
@@ -115,4 +180,158 @@ func (sc *SessionController) SetSessionStatusToTimeout(ctx *gin.Context) {
 	})
 }
 
+// Disconnect lets a client end its own session, setting the session status to CANCELLED
+func (sc *SessionController) Disconnect(ctx *gin.Context) {
+	sessionID := ctx.Param("session_id")
+
+	var reqBody schemas.DisconnectRequest
+	if err := ctx.ShouldBindJSON(&reqBody); err != nil {
+		writeError(ctx, schemas.NewBadRequestError(
+			"Invalid JSON format: "+err.Error(),
+			"/sessions/"+sessionID+"/disconnect",
+		))
+		return
+	}
+
+	err := sc.Service.DisconnectClient(ctx.Request.Context(), sessionID, reqBody.UserID, reqBody.Token, reqBody.Reason)
+	if err != nil {
+		var apiError *schemas.ErrorResponse
+		if errors.As(err, &apiError) {
+			slog.ErrorContext(ctx.Request.Context(), "Client disconnect failed", "error", apiError, "session_id", sessionID, "status", apiError.Status)
+			writeError(ctx, apiError)
+			return
+		}
+		writeError(ctx, schemas.NewInternalError(
+			err.Error(),
+			"/sessions/"+sessionID+"/disconnect",
+		))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, schemas.UpdateSessionStatusResponse{
+		Message:   "Session disconnected by client",
+		SessionID: sessionID,
+		Status:    "CANCELLED",
+	})
+}
+
+// RotateCredentials issues a new RabbitMQ password for an active session
+func (sc *SessionController) RotateCredentials(ctx *gin.Context) {
+	sessionID := ctx.Param("session_id")
+
+	credentials, err := sc.Service.RotateCredentials(ctx.Request.Context(), sessionID)
+	if err != nil {
+		var apiError *schemas.ErrorResponse
+		if errors.As(err, &apiError) {
+			slog.ErrorContext(ctx.Request.Context(), "Credential rotation failed", "error", apiError, "session_id", sessionID, "status", apiError.Status)
+			writeError(ctx, apiError)
+			return
+		}
+		writeError(ctx, schemas.NewInternalError(
+			err.Error(),
+			"/sessions/"+sessionID+"/credentials/rotate",
+		))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, schemas.RotateCredentialsResponse{
+		Message:     "Session credentials rotated",
+		SessionID:   sessionID,
+		Credentials: credentials,
+	})
+}
+
+// UpdateDispatcherStatus records the status reported by the dispatcher for a session
+func (sc *SessionController) UpdateDispatcherStatus(ctx *gin.Context) {
+	sessionID := ctx.Param("session_id")
 
+	var reqBody schemas.UpdateDispatcherStatusRequest
+	if err := ctx.ShouldBindJSON(&reqBody); err != nil {
+		writeError(ctx, schemas.NewBadRequestError(
+			"Invalid JSON format: "+err.Error(),
+			"/sessions/"+sessionID+"/dispatcher-status",
+		))
+		return
+	}
+
+	change := models.StatusChange{
+		Actor:  callerActor(ctx),
+		Reason: reqBody.Reason,
+	}
+
+	err := sc.Service.UpdateDispatcherStatus(ctx.Request.Context(), sessionID, models.DispatcherStatus(reqBody.Status), change)
+	if err != nil {
+		var apiError *schemas.ErrorResponse
+		if errors.As(err, &apiError) {
+			writeError(ctx, apiError)
+			return
+		}
+		writeError(ctx, schemas.NewInternalError(
+			err.Error(),
+			"/sessions/"+sessionID+"/dispatcher-status",
+		))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, schemas.UpdateDispatcherStatusResponse{
+		Message:          "Dispatcher status updated to " + reqBody.Status,
+		SessionID:        sessionID,
+		DispatcherStatus: reqBody.Status,
+	})
+}
+
+// GetSessionEvents returns the status change history of a session
+func (sc *SessionController) GetSessionEvents(ctx *gin.Context) {
+	sessionID := ctx.Param("session_id")
+
+	response, err := sc.Service.GetSessionEvents(ctx.Request.Context(), sessionID)
+	if err != nil {
+		var apiError *schemas.ErrorResponse
+		if errors.As(err, &apiError) {
+			writeError(ctx, apiError)
+			return
+		}
+		writeError(ctx, schemas.NewInternalError(
+			err.Error(),
+			"/sessions/"+sessionID+"/events",
+		))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// bindStatusChange reads the optional {"reason": "..."} body of the status endpoints
+// and attributes the change to the HTTP caller. It writes a 400 response and returns
+// false if a body is present but malformed
+func bindStatusChange(ctx *gin.Context, instance string) (models.StatusChange, bool) {
+	var reqBody schemas.StatusChangeRequest
+	if err := ctx.ShouldBindJSON(&reqBody); err != nil && !errors.Is(err, io.EOF) {
+		writeError(ctx, schemas.NewBadRequestError(
+			"Invalid JSON format: "+err.Error(),
+			instance,
+		))
+		return models.StatusChange{}, false
+	}
+
+	return models.StatusChange{
+		Actor:  callerActor(ctx),
+		Reason: reqBody.Reason,
+	}, true
+}
+
+// callerActor names the HTTP caller on session events: the authenticated service if
+// there is one, its IP address otherwise
+func callerActor(ctx *gin.Context) string {
+	if principal := auth.PrincipalFrom(ctx); principal != nil {
+		return models.HTTPActor(principal.Name)
+	}
+	return models.HTTPActor(ctx.ClientIP())
+}
+
+// writeError sends apiError with the ID of the request, so an error reported by a
+// client can be matched to the logs of its request
+func writeError(ctx *gin.Context, apiError *schemas.ErrorResponse) {
+	apiError.RequestID = requestid.FromContext(ctx.Request.Context())
+	ctx.JSON(apiError.Status, apiError)
+}
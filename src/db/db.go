@@ -5,7 +5,6 @@ import (
 	"database/sql"
 	"fmt"
 	"log/slog"
-	"os"
 	"time"
 
 	"connection-service/src/config"
@@ -18,10 +17,40 @@ type DB struct {
 	conn *sql.DB
 }
 
-// NewDB creates a new database connection
+// NewDB creates a new database connection and applies pending migrations
+// unless DB_AUTO_MIGRATE is disabled
 func NewDB(cfg *config.GlobalConfig) (*DB, error) {
-	dbConfig := cfg.GetDatabaseConfig()
+	database, err := Connect(cfg.GetDatabaseConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	if !cfg.GetDatabaseConfig().GetAutoMigrate() {
+		slog.Info("Automatic migrations disabled, skipping")
+		return database, nil
+	}
+
+	migrator, err := NewMigrator(database)
+	if err != nil {
+		database.Close()
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		database.Close()
+		return nil, fmt.Errorf("failed to apply migrations: %w", err)
+	}
 
+	slog.Info("Database schema is up to date", "applied_migrations", applied)
+	return database, nil
+}
+
+// Connect opens and verifies a PostgreSQL connection pool without touching the schema
+func Connect(dbConfig *config.DatabaseConfig) (*DB, error) {
 	connStr := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
 		dbConfig.GetHost(),
 		dbConfig.GetPort(),
@@ -54,12 +83,6 @@ func NewDB(cfg *config.GlobalConfig) (*DB, error) {
 		"port", dbConfig.GetPort(),
 		"database", dbConfig.GetDBName())
 
-	// Execute init.sql to create tables and indexes
-	if err := executeInitSQL(conn); err != nil {
-		conn.Close()
-		return nil, fmt.Errorf("failed to execute init.sql: %w", err)
-	}
-
 	return &DB{conn: conn}, nil
 }
 
@@ -68,6 +91,11 @@ func (db *DB) GetConnection() *sql.DB {
 	return db.conn
 }
 
+// Ping verifies that the database is reachable
+func (db *DB) Ping(ctx context.Context) error {
+	return db.conn.PingContext(ctx)
+}
+
 // Close closes the database connection
 func (db *DB) Close() error {
 	if db.conn != nil {
@@ -76,28 +104,54 @@ func (db *DB) Close() error {
 	return nil
 }
 
-// executeInitSQL reads and executes the init.sql file to create tables and indexes
-func executeInitSQL(conn *sql.DB) error {
-	// Read init.sql file
-	sqlScript, err := os.ReadFile("init.sql")
+// WithTransaction runs fn inside a database transaction
+// The transaction is committed if fn returns nil and rolled back otherwise
+func (db *DB) WithTransaction(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
 	if err != nil {
-		// Try alternative path for Docker container
-		sqlScript, err = os.ReadFile("/app/init.sql")
-		if err != nil {
-			slog.Warn("init.sql file not found, skipping table creation", "error", err)
-			return nil // Don't fail if init.sql doesn't exist
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			slog.Error("Failed to roll back transaction", "error", rbErr)
 		}
+		return err
 	}
 
-	// Execute the SQL script
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
 
-	_, err = conn.ExecContext(ctx, string(sqlScript))
+// TryWithAdvisoryLock runs fn only if the PostgreSQL session-level advisory lock
+// identified by name can be acquired without waiting. It reports whether fn ran.
+// This lets several replicas run the same periodic job without overlapping.
+func (db *DB) TryWithAdvisoryLock(ctx context.Context, name string, fn func(ctx context.Context) error) (bool, error) {
+	// Advisory locks belong to a database session, so pin a single connection
+	conn, err := db.conn.Conn(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to execute SQL script: %w", err)
+		return false, fmt.Errorf("failed to acquire database connection: %w", err)
 	}
+	defer conn.Close()
 
-	slog.Info("Successfully executed init.sql - tables and indexes created/verified")
-	return nil
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock(hashtext($1))", name).Scan(&acquired); err != nil {
+		return false, fmt.Errorf("failed to acquire advisory lock %s: %w", name, err)
+	}
+	if !acquired {
+		return false, nil
+	}
+
+	defer func() {
+		// Use a fresh context so the lock is released even if ctx was cancelled
+		unlockCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if _, err := conn.ExecContext(unlockCtx, "SELECT pg_advisory_unlock(hashtext($1))", name); err != nil {
+			slog.Error("Failed to release advisory lock", "lock", name, "error", err)
+		}
+	}()
+
+	return true, fn(ctx)
 }
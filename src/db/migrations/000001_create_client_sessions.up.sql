@@ -1,7 +1,6 @@
--- Connection Service Database Schema
 -- Table: client_sessions
+-- Statements are idempotent so databases created by the former init.sql adopt this baseline
 
--- Create the client_sessions table if it doesn't exist
 CREATE TABLE IF NOT EXISTS client_sessions (
     session_id VARCHAR(255) PRIMARY KEY,
     user_id VARCHAR(255) NOT NULL,
@@ -24,7 +23,6 @@ CREATE INDEX IF NOT EXISTS idx_client_sessions_client_status ON client_sessions(
 -- Create index on created_at for time-based queries
 CREATE INDEX IF NOT EXISTS idx_client_sessions_created_at ON client_sessions(created_at DESC);
 
--- Comments for documentation
 COMMENT ON TABLE client_sessions IS 'Stores client session information for tracking connection state and progress';
 COMMENT ON COLUMN client_sessions.session_id IS 'Unique identifier for the session (UUID)';
 COMMENT ON COLUMN client_sessions.user_id IS 'Identifier for the client associated with this session';
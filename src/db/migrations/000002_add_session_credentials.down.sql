@@ -0,0 +1,2 @@
+ALTER TABLE client_sessions DROP COLUMN IF EXISTS credentials_rotated_at;
+ALTER TABLE client_sessions DROP COLUMN IF EXISTS credentials_hash;
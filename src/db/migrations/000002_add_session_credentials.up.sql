@@ -0,0 +1,7 @@
+-- Per-session RabbitMQ passwords are stored as a digest, never in plaintext
+
+ALTER TABLE client_sessions ADD COLUMN IF NOT EXISTS credentials_hash VARCHAR(64);
+ALTER TABLE client_sessions ADD COLUMN IF NOT EXISTS credentials_rotated_at TIMESTAMP;
+
+COMMENT ON COLUMN client_sessions.credentials_hash IS 'SHA-256 hex digest of the RabbitMQ password issued for this session';
+COMMENT ON COLUMN client_sessions.credentials_rotated_at IS 'Timestamp when the RabbitMQ password was last issued or rotated';
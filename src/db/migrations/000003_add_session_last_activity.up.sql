@@ -0,0 +1,5 @@
+-- Activity tracking used by the session timeout reaper
+
+ALTER TABLE client_sessions ADD COLUMN IF NOT EXISTS last_activity_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+COMMENT ON COLUMN client_sessions.last_activity_at IS 'Timestamp of the last client or dispatcher activity, used to detect idle sessions';
@@ -0,0 +1,8 @@
+-- Fails if sessions with FAILED or CANCELLED status still exist
+
+ALTER TABLE client_sessions DROP CONSTRAINT IF EXISTS client_sessions_session_status_check;
+ALTER TABLE client_sessions ADD CONSTRAINT client_sessions_session_status_check
+    CHECK (session_status IN ('IN_PROGRESS', 'COMPLETED', 'TIMEOUT'));
+
+COMMENT ON COLUMN client_sessions.session_status IS 'Current status of the session: IN_PROGRESS, COMPLETED, or TIMEOUT';
+COMMENT ON COLUMN client_sessions.completed_at IS 'Timestamp when the session was completed';
@@ -0,0 +1,8 @@
+-- Allow the FAILED and CANCELLED terminal statuses
+
+ALTER TABLE client_sessions DROP CONSTRAINT IF EXISTS client_sessions_session_status_check;
+ALTER TABLE client_sessions ADD CONSTRAINT client_sessions_session_status_check
+    CHECK (session_status IN ('IN_PROGRESS', 'COMPLETED', 'TIMEOUT', 'FAILED', 'CANCELLED'));
+
+COMMENT ON COLUMN client_sessions.session_status IS 'Current status of the session: IN_PROGRESS, COMPLETED, TIMEOUT, FAILED, or CANCELLED';
+COMMENT ON COLUMN client_sessions.completed_at IS 'Timestamp when the session reached a terminal status';
@@ -0,0 +1,23 @@
+-- Table: session_events
+-- Audit trail of every session and dispatcher status change
+
+CREATE TABLE IF NOT EXISTS session_events (
+    event_id BIGSERIAL PRIMARY KEY,
+    session_id VARCHAR(255) NOT NULL REFERENCES client_sessions(session_id) ON DELETE CASCADE,
+    event_type VARCHAR(50) NOT NULL CHECK (event_type IN ('SESSION_STATUS', 'DISPATCHER_STATUS')),
+    old_status VARCHAR(50),
+    new_status VARCHAR(50) NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    reason TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create index for listing the events of a session in order
+CREATE INDEX IF NOT EXISTS idx_session_events_session_id ON session_events(session_id, created_at);
+
+COMMENT ON TABLE session_events IS 'Audit trail of session and dispatcher status changes';
+COMMENT ON COLUMN session_events.event_type IS 'Which status changed: SESSION_STATUS or DISPATCHER_STATUS';
+COMMENT ON COLUMN session_events.old_status IS 'Status before the change (NULL when the session was created)';
+COMMENT ON COLUMN session_events.new_status IS 'Status after the change';
+COMMENT ON COLUMN session_events.actor IS 'Who requested the change: client, reaper, dispatcher or http:<caller>';
+COMMENT ON COLUMN session_events.reason IS 'Optional free-form reason for the change';
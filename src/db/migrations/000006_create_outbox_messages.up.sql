@@ -0,0 +1,29 @@
+-- Table: outbox_messages
+-- Messages written in the same transaction as the state change they announce,
+-- published to RabbitMQ afterwards by the outbox relay
+
+CREATE TABLE IF NOT EXISTS outbox_messages (
+    message_id BIGSERIAL PRIMARY KEY,
+    session_id VARCHAR(255) REFERENCES client_sessions(session_id) ON DELETE CASCADE,
+    exchange VARCHAR(255) NOT NULL,
+    routing_key VARCHAR(255) NOT NULL DEFAULT '',
+    payload JSONB NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    available_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    leased_until TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMP,
+    failed_at TIMESTAMP
+);
+
+-- Create partial index so the relay only scans unsent messages
+CREATE INDEX IF NOT EXISTS idx_outbox_messages_pending ON outbox_messages(available_at) WHERE sent_at IS NULL;
+
+COMMENT ON TABLE outbox_messages IS 'Transactional outbox of RabbitMQ messages awaiting publication';
+COMMENT ON COLUMN outbox_messages.session_id IS 'Session the message belongs to; deleting the session discards it';
+COMMENT ON COLUMN outbox_messages.attempts IS 'Number of failed publish attempts';
+COMMENT ON COLUMN outbox_messages.available_at IS 'Earliest time the relay may publish the message';
+COMMENT ON COLUMN outbox_messages.leased_until IS 'Until when the relay that claimed the message may publish it before another one takes over';
+COMMENT ON COLUMN outbox_messages.sent_at IS 'Timestamp when the broker confirmed the message (NULL while pending)';
+COMMENT ON COLUMN outbox_messages.failed_at IS 'When the broker returned the message as unroutable; failed messages are never published again';
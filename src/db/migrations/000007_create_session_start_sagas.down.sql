@@ -0,0 +1,7 @@
+UPDATE outbox_messages SET available_at = created_at WHERE available_at IS NULL;
+ALTER TABLE outbox_messages ALTER COLUMN available_at SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE outbox_messages ALTER COLUMN available_at SET NOT NULL;
+
+COMMENT ON COLUMN outbox_messages.available_at IS 'Earliest time the relay may publish the message';
+
+DROP TABLE IF EXISTS session_start_sagas;
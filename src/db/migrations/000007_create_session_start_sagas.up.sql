@@ -0,0 +1,30 @@
+-- Table: session_start_sagas
+-- Persisted progress of the multi-step session start flow, so a restarted pod
+-- can resume or compensate a half-finished start
+
+CREATE TABLE IF NOT EXISTS session_start_sagas (
+    session_id VARCHAR(255) PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    state VARCHAR(50) NOT NULL CHECK (state IN ('RUNNING', 'COMPLETED', 'COMPENSATING', 'COMPENSATED')),
+    last_step VARCHAR(50) NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create partial index so recovery only scans unfinished sagas
+CREATE INDEX IF NOT EXISTS idx_session_start_sagas_unfinished ON session_start_sagas(updated_at)
+    WHERE state IN ('RUNNING', 'COMPENSATING');
+
+COMMENT ON TABLE session_start_sagas IS 'Progress of the session start saga (session row, broker user, queues, permissions, notification)';
+COMMENT ON COLUMN session_start_sagas.state IS 'RUNNING, COMPLETED, COMPENSATING or COMPENSATED';
+COMMENT ON COLUMN session_start_sagas.last_step IS 'Last step that completed successfully';
+COMMENT ON COLUMN session_start_sagas.attempts IS 'Number of failed recovery attempts';
+COMMENT ON COLUMN session_start_sagas.updated_at IS 'Last progress or lease renewal; stale unfinished sagas are picked up by recovery';
+
+-- Outbox messages may now be held (NULL available_at) until their saga releases them
+ALTER TABLE outbox_messages ALTER COLUMN available_at DROP NOT NULL;
+ALTER TABLE outbox_messages ALTER COLUMN available_at DROP DEFAULT;
+
+COMMENT ON COLUMN outbox_messages.available_at IS 'Earliest time the relay may publish the message (NULL while held by its saga)';
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS uq_client_sessions_active_user;
+DROP TABLE IF EXISTS idempotency_keys;
@@ -0,0 +1,46 @@
+-- Table: idempotency_keys
+-- Outcome of POST /sessions/start requests made with an Idempotency-Key header,
+-- replayed to retries of the same request until the key expires
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    user_id VARCHAR(255) NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_hash VARCHAR(64) NOT NULL,
+    status_code INTEGER,
+    response BYTEA,
+    session_id VARCHAR(255),
+    locked_until TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, idempotency_key)
+);
+
+-- Create index for purging expired keys
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
+
+COMMENT ON TABLE idempotency_keys IS 'Stored outcomes of idempotent session start requests';
+COMMENT ON COLUMN idempotency_keys.request_hash IS 'SHA-256 fingerprint of the request, so a key cannot be reused for a different request';
+COMMENT ON COLUMN idempotency_keys.status_code IS 'HTTP status of the first response (NULL while it is being processed)';
+COMMENT ON COLUMN idempotency_keys.response IS 'JSON body of the first response, AES-GCM encrypted with a key derived from the connection token';
+COMMENT ON COLUMN idempotency_keys.locked_until IS 'Lease of the request processing the key (NULL once done); a retry takes over an expired lease';
+
+-- At most one IN_PROGRESS session per user. Older duplicates left behind by
+-- concurrent starts are failed first so the index can be built
+WITH duplicates AS (
+    UPDATE client_sessions s
+    SET session_status = 'FAILED', completed_at = CURRENT_TIMESTAMP
+    WHERE s.session_status = 'IN_PROGRESS'
+      AND EXISTS (
+          SELECT 1 FROM client_sessions n
+          WHERE n.user_id = s.user_id
+            AND n.session_status = 'IN_PROGRESS'
+            AND (n.created_at, n.session_id) > (s.created_at, s.session_id)
+      )
+    RETURNING s.session_id
+)
+INSERT INTO session_events (session_id, event_type, old_status, new_status, actor, reason, created_at)
+SELECT session_id, 'SESSION_STATUS', 'IN_PROGRESS', 'FAILED', 'migration', 'duplicate active session for user', CURRENT_TIMESTAMP
+FROM duplicates;
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_client_sessions_active_user ON client_sessions(user_id)
+    WHERE session_status = 'IN_PROGRESS';
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_client_sessions_token_id;
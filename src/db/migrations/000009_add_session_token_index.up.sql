@@ -0,0 +1,2 @@
+-- Create index for counting the sessions started with a token (max_uses checks)
+CREATE INDEX IF NOT EXISTS idx_client_sessions_token_id ON client_sessions(token_id);
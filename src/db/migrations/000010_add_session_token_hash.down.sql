@@ -0,0 +1 @@
+ALTER TABLE client_sessions DROP COLUMN IF EXISTS token_hash;
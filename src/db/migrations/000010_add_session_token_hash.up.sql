@@ -0,0 +1,6 @@
+-- Digest of the connection token a session was started with, so the client can end
+-- its own session by presenting the token again (tokens are never stored in plaintext)
+
+ALTER TABLE client_sessions ADD COLUMN IF NOT EXISTS token_hash VARCHAR(64);
+
+COMMENT ON COLUMN client_sessions.token_hash IS 'SHA-256 hex digest of the connection token the session was started with';
@@ -0,0 +1 @@
+ALTER TABLE session_start_sagas DROP COLUMN IF EXISTS model_type;
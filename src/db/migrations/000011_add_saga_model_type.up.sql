@@ -0,0 +1,6 @@
+-- Model type of the client a session start saga is for, so a resumed saga declares
+-- the client queues with the same queue policy as the original request
+
+ALTER TABLE session_start_sagas ADD COLUMN IF NOT EXISTS model_type VARCHAR(255) NOT NULL DEFAULT '';
+
+COMMENT ON COLUMN session_start_sagas.model_type IS 'Model type of the client, selecting the policy (TTL, max length, queue type) of its queues';
@@ -0,0 +1,141 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Overall readiness statuses
+const (
+	StatusReady    = "ready"
+	StatusDegraded = "degraded" // An optional dependency is down; still serving
+	StatusNotReady = "not_ready"
+	StatusDraining = "draining"
+)
+
+// Dependency statuses
+const (
+	DependencyUp   = "up"
+	DependencyDown = "down"
+)
+
+// CheckFunc reports whether a dependency is usable; it must honour ctx
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name     string
+	required bool
+	fn       CheckFunc
+}
+
+// DependencyReport is the outcome of a single dependency check
+type DependencyReport struct {
+	Status    string `json:"status"`
+	Required  bool   `json:"required"`
+	LatencyMS int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Report is the outcome of a readiness check
+type Report struct {
+	Status       string                      `json:"status"`
+	Dependencies map[string]DependencyReport `json:"dependencies"`
+}
+
+// Ready reports whether the instance should receive traffic
+func (r *Report) Ready() bool {
+	return r.Status == StatusReady || r.Status == StatusDegraded
+}
+
+// Checker runs the readiness checks of the service's dependencies
+// Once draining starts it reports not ready regardless of the dependencies, so
+// the load balancer stops routing new requests before the server shuts down
+type Checker struct {
+	mu       sync.RWMutex
+	checks   []check
+	timeout  time.Duration
+	draining atomic.Bool
+}
+
+// NewChecker creates a checker; every check gets at most timeout to complete
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Register adds a dependency check. A failing required dependency makes the
+// service not ready; a failing optional one only degrades it
+func (c *Checker) Register(name string, required bool, fn CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, check{name: name, required: required, fn: fn})
+}
+
+// StartDraining makes every following readiness check fail
+func (c *Checker) StartDraining() {
+	c.draining.Store(true)
+}
+
+// Draining reports whether the service is shutting down
+func (c *Checker) Draining() bool {
+	return c.draining.Load()
+}
+
+// Check runs all dependency checks concurrently and aggregates them
+func (c *Checker) Check(ctx context.Context) *Report {
+	c.mu.RLock()
+	checks := c.checks
+	c.mu.RUnlock()
+
+	report := &Report{
+		Status:       StatusReady,
+		Dependencies: make(map[string]DependencyReport, len(checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, chk := range checks {
+		wg.Add(1)
+		go func(chk check) {
+			defer wg.Done()
+			dependency := c.run(ctx, chk)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Dependencies[chk.name] = dependency
+			if dependency.Status == DependencyUp {
+				return
+			}
+			if chk.required {
+				report.Status = StatusNotReady
+			} else if report.Status == StatusReady {
+				report.Status = StatusDegraded
+			}
+		}(chk)
+	}
+	wg.Wait()
+
+	if c.Draining() {
+		report.Status = StatusDraining
+	}
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, chk check) DependencyReport {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := chk.fn(ctx)
+	dependency := DependencyReport{
+		Status:    DependencyUp,
+		Required:  chk.required,
+		LatencyMS: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		dependency.Status = DependencyDown
+		dependency.Error = err.Error()
+	}
+	return dependency
+}
@@ -2,10 +2,16 @@ package main
 
 import (
 	"connection-service/src/config"
+	"connection-service/src/db"
+	"connection-service/src/requestid"
 	"connection-service/src/server"
+	"context"
+	"fmt"
 	"log"
 	"log/slog"
 	"os"
+	"strconv"
+	"time"
 )
 
 // @title Connection Service API
@@ -24,9 +30,9 @@ func loadConfig() *config.GlobalConfig {
 	return config
 }
 
-func setupLogging(config *config.GlobalConfig) {
+func setupLogging(level string) {
 	logLevel := slog.LevelInfo
-	switch config.GetLogLevel() {
+	switch level {
 	case "debug":
 		logLevel = slog.LevelDebug
 	case "warn":
@@ -35,15 +41,95 @@ func setupLogging(config *config.GlobalConfig) {
 		logLevel = slog.LevelError
 	}
 
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+	// Logs written with a request context carry its request ID
+	logger := slog.New(requestid.NewLogHandler(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 		Level: logLevel,
-	}))
+	})))
 	slog.SetDefault(logger)
 }
 
+// runMigrate implements `connection-service migrate [up | down [steps] | status]`
+// It only needs the POSTGRES_* environment variables
+func runMigrate(args []string) error {
+	setupLogging(os.Getenv("LOG_LEVEL"))
+
+	dbConfig, err := config.NewDatabaseConfig()
+	if err != nil {
+		return err
+	}
+
+	database, err := db.Connect(dbConfig)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	migrator, err := db.NewMigrator(database)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied %d migration(s)\n", applied)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				return fmt.Errorf("down steps must be a positive integer")
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Reverted %d migration(s)\n", reverted)
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.AppliedAt != nil {
+				state = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%06d_%s\t%s\n", status.Version, status.Name, state)
+		}
+
+	default:
+		return fmt.Errorf("unknown migrate command %q (expected up, down or status)", command)
+	}
+
+	return nil
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			slog.Error("Migration failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	config := loadConfig()
-	setupLogging(config)
+	setupLogging(config.GetLogLevel())
 
 	srv, err := server.NewServer(config)
 	if err != nil {
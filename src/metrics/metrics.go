@@ -0,0 +1,220 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes every metric of the service
+const namespace = "connection_service"
+
+// collectTimeout bounds the database query run on scrape for the active sessions gauge
+const collectTimeout = 2 * time.Second
+
+// registry holds the service metrics together with the Go runtime and process collectors
+var registry = prometheus.NewRegistry()
+
+var factory = promauto.With(registry)
+
+var (
+	httpRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	sessionTransitions = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "session_transitions_total",
+		Help:      "Sessions entering each status, IN_PROGRESS being a session start.",
+	}, []string{"status"})
+
+	topologyDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "topology_operation_duration_seconds",
+		Help:      "Latency of RabbitMQ topology operations.",
+		Buckets:   []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"operation"})
+
+	topologyFailures = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "topology_operation_failures_total",
+		Help:      "Failed RabbitMQ topology operations.",
+	}, []string{"operation"})
+
+	publishAttempts = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "amqp_publish_attempts_total",
+		Help:      "AMQP publish attempts by exchange, retries included.",
+	}, []string{"exchange"})
+
+	publishErrors = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "amqp_publish_errors_total",
+		Help:      "AMQP publish attempts rejected by the channel before reaching the broker.",
+	}, []string{"exchange"})
+
+	publishNacks = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "amqp_publish_nacks_total",
+		Help:      "AMQP publishes negatively acknowledged by the broker.",
+	}, []string{"exchange"})
+
+	publishReturns = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "amqp_publish_returns_total",
+		Help:      "AMQP publishes returned by the broker because no queue was bound to receive them.",
+	}, []string{"exchange"})
+
+	publishFailures = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "amqp_publish_failures_total",
+		Help:      "AMQP publishes that failed after every retry.",
+	}, []string{"exchange"})
+
+	usersServiceDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "users_service_request_duration_seconds",
+		Help:      "Latency of users-service calls by operation and outcome (success, 4xx, 5xx, error or circuit_open).",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "outcome"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
+}
+
+// HTTPMiddleware records the count and latency of every request by route template,
+// so session IDs in paths do not explode the label cardinality
+func HTTPMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := ctx.Request.Method
+
+		httpRequests.WithLabelValues(method, route, strconv.Itoa(ctx.Writer.Status())).Inc()
+		httpDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// RecordSessionTransition counts a session entering status
+func RecordSessionTransition(status string) {
+	sessionTransitions.WithLabelValues(status).Inc()
+}
+
+// ObserveTopologyOperation records the latency of a topology operation and whether it failed
+func ObserveTopologyOperation(operation string, start time.Time, err error) {
+	topologyDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		topologyFailures.WithLabelValues(operation).Inc()
+	}
+}
+
+// RecordPublishAttempt counts a single publish attempt to exchange
+func RecordPublishAttempt(exchange string) {
+	publishAttempts.WithLabelValues(exchange).Inc()
+}
+
+// RecordPublishError counts a publish attempt the channel refused
+func RecordPublishError(exchange string) {
+	publishErrors.WithLabelValues(exchange).Inc()
+}
+
+// RecordPublishNack counts a publish the broker did not acknowledge
+func RecordPublishNack(exchange string) {
+	publishNacks.WithLabelValues(exchange).Inc()
+}
+
+// RecordPublishReturn counts a publish the broker returned as unroutable
+func RecordPublishReturn(exchange string) {
+	publishReturns.WithLabelValues(exchange).Inc()
+}
+
+// RecordPublishFailure counts a publish given up after its last retry
+func RecordPublishFailure(exchange string) {
+	publishFailures.WithLabelValues(exchange).Inc()
+}
+
+// ObserveUsersServiceCall records the latency and outcome of a users-service call
+func ObserveUsersServiceCall(operation, outcome string, start time.Time) {
+	usersServiceDuration.WithLabelValues(operation, outcome).Observe(time.Since(start).Seconds())
+}
+
+// RegisterDBStats exports the connection pool statistics of db
+func RegisterDBStats(db *sql.DB, dbName string) {
+	registry.MustRegister(collectors.NewDBStatsCollector(db, dbName))
+}
+
+// RegisterGaugeFunc exports a gauge whose value is read from fn on every scrape
+func RegisterGaugeFunc(name, help string, fn func() float64) {
+	factory.NewGaugeFunc(prometheus.GaugeOpts{Namespace: namespace, Name: name, Help: help}, fn)
+}
+
+// RegisterCounterFunc exports a counter whose value is read from fn on every scrape
+func RegisterCounterFunc(name, help string, fn func() float64) {
+	factory.NewCounterFunc(prometheus.CounterOpts{Namespace: namespace, Name: name, Help: help}, fn)
+}
+
+// RegisterActiveSessions exports the number of IN_PROGRESS sessions, counted on
+// every scrape. The gauge is left out of a scrape whose count fails
+func RegisterActiveSessions(count func(ctx context.Context) (int, error)) {
+	registry.MustRegister(&activeSessionsCollector{
+		count: count,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "active_sessions"),
+			"Sessions currently IN_PROGRESS across all replicas.",
+			nil, nil,
+		),
+	})
+}
+
+type activeSessionsCollector struct {
+	count func(ctx context.Context) (int, error)
+	desc  *prometheus.Desc
+}
+
+func (c *activeSessionsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *activeSessionsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+
+	active, err := c.count(ctx)
+	if err != nil {
+		slog.Warn("Failed to count active sessions for metrics", "error", err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(active))
+}
@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"connection-service/src/config"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// clientRole is a party exchanging messages through the queues of a client
+type clientRole string
+
+const (
+	// roleClient is the client itself, connecting with its per-session broker user
+	roleClient clientRole = "client"
+	// roleDispatcher streams the inputs of the client's model
+	roleDispatcher clientRole = "dispatcher"
+	// roleCalibration consumes the inputs and outputs of the client's model
+	roleCalibration clientRole = "calibration"
+)
+
+// clientQueue describes a queue every client gets
+type clientQueue struct {
+	nameFormat string     // Queue name, with the user ID as only argument
+	kind       string     // Short description used in logs and errors
+	producer   clientRole // Publishes to the queue
+	consumer   clientRole // Consumes from the queue
+	exchange   string     // Exchange producers publish through, with the user ID as only argument; "" is the default exchange, which needs no binding
+}
+
+// defaultExchange is the name permissions give the default exchange
+const defaultExchange = "amq.default"
+
+// clientTopology is the topology of every client. Setup, teardown, permissions and
+// verification are all derived from it, so a new queue only needs an entry here
+var clientTopology = []clientQueue{
+	{
+		nameFormat: config.DISPATCHER_TO_CLIENT_QUEUE,
+		kind:       "dispatcher",
+		producer:   roleDispatcher,
+		consumer:   roleClient,
+	},
+	{
+		nameFormat: config.CLIENT_TO_CALIBRATION_QUEUE,
+		kind:       "outputs calibration",
+		producer:   roleClient,
+		consumer:   roleCalibration,
+		// Clients may not publish through the default exchange, which routes to any queue
+		exchange: config.CLIENT_OUTPUTS_EXCHANGE,
+	},
+	{
+		nameFormat: config.DISPATCHER_TO_CALIBRATION_QUEUE,
+		kind:       "inputs calibration",
+		producer:   roleDispatcher,
+		consumer:   roleCalibration,
+	},
+}
+
+// name returns the name of the queue of a client
+func (q clientQueue) name(UserID string) string {
+	return fmt.Sprintf(q.nameFormat, UserID)
+}
+
+// exchangeName returns the name of the exchange of a client producers publish
+// through, or "" for the default exchange
+func (q clientQueue) exchangeName(UserID string) string {
+	if q.exchange == "" {
+		return ""
+	}
+	return fmt.Sprintf(q.exchange, UserID)
+}
+
+// publishExchange returns the exchange producers publish through, as named in permissions
+func (q clientQueue) publishExchange(UserID string) string {
+	if q.exchange == "" {
+		return defaultExchange
+	}
+	return q.exchangeName(UserID)
+}
+
+// clientPermissions are the configure, write and read patterns of a role on the
+// resources of a client
+type clientPermissions struct {
+	configure string
+	write     string
+	read      string
+}
+
+// permissionsFor derives the permissions of role from the topology: it reads the
+// queues it consumes and writes to the exchanges it publishes through. RabbitMQ checks
+// the write permission of a publish on the exchange only, so write access to the
+// default exchange lets a role publish to any queue by name. No role may configure
+// (declare or delete) anything
+func permissionsFor(role clientRole, UserID string) clientPermissions {
+	var read, write []string
+	exchanges := make(map[string]bool)
+	for _, q := range clientTopology {
+		if q.consumer == role {
+			read = append(read, q.name(UserID))
+		}
+		if q.producer == role {
+			if exchange := q.publishExchange(UserID); !exchanges[exchange] {
+				exchanges[exchange] = true
+				write = append(write, exchange)
+			}
+		}
+	}
+	return clientPermissions{
+		configure: "",
+		write:     matchExactly(write),
+		read:      matchExactly(read),
+	}
+}
+
+// matchExactly returns a pattern matching exactly the given names, or the empty
+// pattern, which RabbitMQ treats as matching nothing
+func matchExactly(names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = regexp.QuoteMeta(name)
+	}
+	return fmt.Sprintf("^(%s)$", strings.Join(quoted, "|"))
+}
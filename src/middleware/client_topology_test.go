@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestPermissionsFor(t *testing.T) {
+	tests := []struct {
+		name      string
+		role      clientRole
+		write     bool // Checks the write pattern rather than the read one
+		resource  string
+		wantMatch bool
+	}{
+		{"client reads its dispatcher queue", roleClient, false, "alice.v2_dispatcher_queue", true},
+		{"client reads no calibration queue", roleClient, false, "alice.v2_outputs_cal_queue", false},
+		{"client reads no lookalike queue", roleClient, false, "alicexv2_dispatcher_queue", false},
+		{"client reads no queue of another client", roleClient, false, "bob_dispatcher_queue", false},
+		{"client writes its outputs exchange", roleClient, true, "alice.v2_outputs_exchange", true},
+		{"client writes no outputs exchange of another client", roleClient, true, "bob_outputs_exchange", false},
+		{"client writes no default exchange", roleClient, true, "amq.default", false},
+		{"client writes no queue", roleClient, true, "alice.v2_outputs_cal_queue", false},
+		{"client writes no shared exchange", roleClient, true, "dispatcher_status_exchange", false},
+		{"dispatcher writes the default exchange", roleDispatcher, true, "amq.default", true},
+		{"dispatcher writes no client exchange", roleDispatcher, true, "alice.v2_outputs_exchange", false},
+		{"dispatcher reads nothing", roleDispatcher, false, "alice.v2_dispatcher_queue", false},
+		{"calibration reads the outputs calibration queue", roleCalibration, false, "alice.v2_outputs_cal_queue", true},
+		{"calibration reads the inputs calibration queue", roleCalibration, false, "alice.v2_inputs_cal_queue", true},
+		{"calibration writes nothing", roleCalibration, true, "amq.default", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			permissions := permissionsFor(tt.role, "alice.v2")
+			if permissions.configure != "" {
+				t.Fatalf("configure = %q, want no configure permission", permissions.configure)
+			}
+			pattern := permissions.read
+			if tt.write {
+				pattern = permissions.write
+			}
+			if got := pattern != "" && regexp.MustCompile(pattern).MatchString(tt.resource); got != tt.wantMatch {
+				t.Errorf("%q matches %q = %v, want %v", pattern, tt.resource, got, tt.wantMatch)
+			}
+		})
+	}
+}
+
+func TestMatchExactly(t *testing.T) {
+	tests := []struct {
+		names []string
+		want  string
+	}{
+		{nil, ""},
+		{[]string{"queue"}, "^(queue)$"},
+		{[]string{"a.b", "amq.default"}, `^(a\.b|amq\.default)$`},
+	}
+
+	for _, tt := range tests {
+		if got := matchExactly(tt.names); got != tt.want {
+			t.Errorf("matchExactly(%q) = %q, want %q", tt.names, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// ErrNotConnected is returned when RabbitMQ stays unreachable for longer than the
+// configured ready timeout
+var ErrNotConnected = errors.New("not connected to RabbitMQ")
+
+// errShuttingDown is returned to callers waiting for a connection during shutdown
+var errShuttingDown = errors.New("RabbitMQ middleware is shutting down")
+
+const (
+	initialReconnectDelay = 1 * time.Second
+	maxReconnectDelay     = 60 * time.Second
+)
+
+// connection is a RabbitMQ connection with its channels: one for declarations and
+// a pool of confirm channels for publishing. The supervisor replaces it as a whole
+// after a reconnection, so callers take a snapshot with await
+type connection struct {
+	conn    *amqp.Connection
+	channel *amqp.Channel
+
+	publishers      []atomic.Pointer[confirmChannel]
+	publisherClosed chan struct{} // Signalled when a publishing channel closes
+	next            atomic.Uint64
+}
+
+// publisher picks a publishing channel round-robin, skipping closed ones
+func (c *connection) publisher() (*confirmChannel, error) {
+	count := uint64(len(c.publishers))
+	start := c.next.Add(1)
+	for i := uint64(0); i < count; i++ {
+		publisher := c.publishers[(start+i)%count].Load()
+		if !publisher.isClosed() {
+			return publisher, nil
+		}
+	}
+	return nil, amqp.ErrClosed
+}
+
+// exchangeDeclaration is an exchange declared through the middleware, re-declared
+// on every new declaration channel
+type exchangeDeclaration struct {
+	kind    string
+	durable bool
+}
+
+// connect dials RabbitMQ and opens its channels, retrying with exponential
+// backoff until it succeeds or the middleware shuts down, then signals readiness
+func (m *Middleware) connect() error {
+	cfg := m.config.GetMiddlewareConfig()
+	delay := initialReconnectDelay
+
+	for {
+		conn, err := amqp.DialConfig(
+			fmt.Sprintf("amqp://%s:%s@%s:%d/",
+				cfg.GetUsername(), cfg.GetPassword(), cfg.GetHost(), cfg.GetPort()),
+			amqp.Config{
+				// Heartbeats are what detects a broker gone without closing the socket
+				Heartbeat: cfg.GetHeartbeat(),
+			},
+		)
+		if err == nil {
+			var c *connection
+			c, err = m.openChannels(conn)
+			if err == nil {
+				m.setConnection(c)
+				slog.Info("Connected to RabbitMQ",
+					"host", cfg.GetHost(),
+					"port", cfg.GetPort(),
+					"user", cfg.GetUsername())
+				return nil
+			}
+			conn.Close()
+		}
+
+		slog.Warn("Failed to connect to RabbitMQ", "error", err, "retry_in", delay)
+		select {
+		case <-m.ctx.Done():
+			return errShuttingDown
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxReconnectDelay)
+	}
+}
+
+// openChannels opens the declaration channel and the publishing channels of conn
+func (m *Middleware) openChannels(conn *amqp.Connection) (*connection, error) {
+	ch, err := m.openChannel(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	count := m.config.GetMiddlewareConfig().GetPublishChannels()
+	c := &connection{
+		conn:            conn,
+		channel:         ch,
+		publishers:      make([]atomic.Pointer[confirmChannel], count),
+		publisherClosed: make(chan struct{}, 1),
+	}
+	for i := range c.publishers {
+		publisher, err := newConfirmChannel(conn, c.publisherClosed)
+		if err != nil {
+			return nil, err
+		}
+		c.publishers[i].Store(publisher)
+	}
+	return c, nil
+}
+
+// openChannel opens the declaration channel of conn and re-declares the exchanges
+// declared so far, which a restarted broker may have lost
+func (m *Middleware) openChannel(conn *amqp.Connection) (*amqp.Channel, error) {
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open channel: %w", err)
+	}
+
+	if err := ch.Qos(1, 0, false); err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("failed to set QoS: %w", err)
+	}
+
+	m.mu.RLock()
+	exchanges := make(map[string]exchangeDeclaration, len(m.exchanges))
+	for name, declaration := range m.exchanges {
+		exchanges[name] = declaration
+	}
+	m.mu.RUnlock()
+
+	for name, declaration := range exchanges {
+		if err := declareExchange(ch, name, declaration); err != nil {
+			ch.Close()
+			return nil, fmt.Errorf("failed to re-declare exchange %s: %w", name, err)
+		}
+	}
+
+	return ch, nil
+}
+
+// supervise re-creates a channel when it closes, and the whole connection when that
+// closes, until the middleware shuts down
+func (m *Middleware) supervise() {
+	for {
+		m.mu.RLock()
+		current := m.current
+		m.mu.RUnlock()
+
+		if !m.watch(current) {
+			return
+		}
+		if err := m.connect(); err != nil {
+			return
+		}
+	}
+}
+
+// watch re-opens the channels of a connection as they close, until the connection is
+// lost. The connection listener is registered once, and a channel listener once per
+// channel, so nothing piles up on a long-lived connection. Returns false once the
+// middleware shuts down
+func (m *Middleware) watch(current *connection) bool {
+	connClosed := current.conn.NotifyClose(make(chan *amqp.Error, 1))
+	channelClosed := current.channel.NotifyClose(make(chan *amqp.Error, 1))
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return false
+
+		case amqpErr := <-connClosed:
+			m.setDisconnected()
+			slog.Warn("RabbitMQ connection closed, reconnecting", "error", amqpErr)
+			return true
+
+		case amqpErr := <-channelClosed:
+			m.setDisconnected()
+			slog.Warn("RabbitMQ channel closed, re-opening", "error", amqpErr)
+
+			if !current.conn.IsClosed() {
+				ch, err := m.openChannel(current.conn)
+				if err == nil {
+					current = &connection{
+						conn:            current.conn,
+						channel:         ch,
+						publishers:      current.publishers,
+						publisherClosed: current.publisherClosed,
+					}
+					m.setConnection(current)
+					channelClosed = ch.NotifyClose(make(chan *amqp.Error, 1))
+					slog.Info("Re-opened RabbitMQ channel")
+					continue
+				}
+				slog.Warn("Failed to re-open RabbitMQ channel, reconnecting", "error", err)
+				current.conn.Close()
+			}
+			return true
+
+		case <-current.publisherClosed:
+			// The other publishing channels keep serving while the closed ones are replaced
+			if !current.conn.IsClosed() {
+				err := m.replaceClosedPublishers(current)
+				if err == nil {
+					continue
+				}
+				slog.Warn("Failed to re-open RabbitMQ publishing channel, reconnecting", "error", err)
+				current.conn.Close()
+			}
+			// Otherwise the connection is gone and is re-established by the caller
+			m.setDisconnected()
+			return true
+		}
+	}
+}
+
+// replaceClosedPublishers re-opens the publishing channels of c that have closed
+func (m *Middleware) replaceClosedPublishers(c *connection) error {
+	for i := range c.publishers {
+		if !c.publishers[i].Load().isClosed() {
+			continue
+		}
+		publisher, err := newConfirmChannel(c.conn, c.publisherClosed)
+		if err != nil {
+			return err
+		}
+		c.publishers[i].Store(publisher)
+		slog.Info("Re-opened RabbitMQ publishing channel")
+	}
+	return nil
+}
+
+// setConnection makes c the current connection and wakes up the callers waiting for one
+func (m *Middleware) setConnection(c *connection) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.current = c
+	select {
+	case <-m.ready:
+	default:
+		close(m.ready)
+	}
+}
+
+// setDisconnected makes callers wait for the next connection
+func (m *Middleware) setDisconnected() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	select {
+	case <-m.ready:
+		m.ready = make(chan struct{})
+	default:
+	}
+}
+
+// Ready returns a channel closed while the connection and its channels are usable
+// After a disconnection a new, open channel is returned until reconnected
+func (m *Middleware) Ready() <-chan struct{} {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.ready
+}
+
+// await returns the current connection, waiting up to the configured ready timeout
+// for it to be re-established if it is down
+func (m *Middleware) await(ctx context.Context) (*connection, error) {
+	m.mu.RLock()
+	ready, current := m.ready, m.current
+	m.mu.RUnlock()
+
+	select {
+	case <-ready:
+		return current, nil
+	default:
+	}
+
+	timer := time.NewTimer(m.config.GetMiddlewareConfig().GetReadyTimeout())
+	defer timer.Stop()
+
+	select {
+	case <-ready:
+		m.mu.RLock()
+		defer m.mu.RUnlock()
+		return m.current, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-m.ctx.Done():
+		return nil, errShuttingDown
+	case <-timer.C:
+		return nil, ErrNotConnected
+	}
+}
+
+func declareExchange(ch *amqp.Channel, name string, declaration exchangeDeclaration) error {
+	return ch.ExchangeDeclare(
+		name,
+		declaration.kind,
+		declaration.durable, // durable
+		false,               // autoDelete
+		false,               // internal
+		false,               // noWait
+		nil,                 // arguments
+	)
+}
@@ -3,26 +3,42 @@ package middleware
 import (
 	"bytes"
 	"connection-service/src/config"
+	"connection-service/src/metrics"
+	"connection-service/src/tracing"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"log/slog"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/streadway/amqp"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Middleware struct {
-	conn          *amqp.Connection
-	channel       *amqp.Channel
-	confirms_chan chan amqp.Confirmation
-	config        *config.GlobalConfig
-	ctx           context.Context
-	cancel        context.CancelFunc
+	config *config.GlobalConfig
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// Guarded by mu; the supervisor replaces current after every reconnection and
+	// closes ready once it is usable
+	mu        sync.RWMutex
+	current   *connection
+	ready     chan struct{}
+	exchanges map[string]exchangeDeclaration
+
+	consumers   map[string]*amqp.Channel
+	consumersMu sync.Mutex
+	adminClient *http.Client
 }
 
 const MAX_RETRIES = 5
@@ -32,116 +48,203 @@ type Publisher interface {
 	Publish(exchange string, body []byte) error
 }
 
+// NewMiddleware connects to RabbitMQ, retrying until the broker is reachable, and
+// supervises the connection from then on: whenever the connection or the publishing
+// channel closes it is re-created, and callers wait for it up to the ready timeout
 func NewMiddleware(config *config.GlobalConfig) (*Middleware, error) {
-	middlewareConfig := config.GetMiddlewareConfig()
 	ctx, cancel := context.WithCancel(context.Background())
 	m := &Middleware{
-		config: config,
-		ctx:    ctx,
-		cancel: cancel,
+		config:    config,
+		ctx:       ctx,
+		cancel:    cancel,
+		ready:     make(chan struct{}),
+		exchanges: make(map[string]exchangeDeclaration),
+		consumers: make(map[string]*amqp.Channel),
+		// Management API calls are traced as client spans of the operation that made them
+		adminClient: &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)},
 	}
 
-	err := m.Connect(middlewareConfig)
-	if err != nil {
+	if err := m.connect(); err != nil {
 		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
 	}
 
-	if err := m.channel.Confirm(false); err != nil {
-		return nil, err
-	}
+	go m.supervise()
 
-	confirms_chan := m.channel.NotifyPublish(make(chan amqp.Confirmation, 1))
+	return m, nil
+}
 
-	if err := m.channel.Qos(1, 0, false); err != nil {
-		return nil, err
+func (m *Middleware) DeclareQueue(queueName string, durable bool) error {
+	c, err := m.await(m.ctx)
+	if err != nil {
+		return fmt.Errorf("failed to ensure connection: %w", err)
 	}
+	_, err = c.channel.QueueDeclare(
+		queueName, // name
+		durable,   // durable
+		false,     // delete when unused
+		false,     // exclusive
+		false,     // no-wait
+		nil,       // arguments
+	)
+	return err
+}
 
-	slog.Info("Connected to RabbitMQ",
-		"host", middlewareConfig.GetHost(),
-		"port", middlewareConfig.GetPort(),
-		"user", middlewareConfig.GetUsername())
+// DeclareTemporaryQueue declares a server-named, exclusive queue that the broker deletes
+// with the connection, for per-replica subscriptions such as cache invalidation
+// Returns the name the broker gave the queue
+func (m *Middleware) DeclareTemporaryQueue() (string, error) {
+	c, err := m.await(m.ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to ensure connection: %w", err)
+	}
+	queue, err := c.channel.QueueDeclare(
+		"",    // name, chosen by the broker
+		false, // durable
+		true,  // delete when unused
+		true,  // exclusive
+		false, // no-wait
+		nil,   // arguments
+	)
+	if err != nil {
+		return "", err
+	}
+	return queue.Name, nil
+}
 
-	m.confirms_chan = confirms_chan
+// ErrQueueArgumentsMismatch is returned when a queue already exists with other
+// arguments than the ones it is declared with
+var ErrQueueArgumentsMismatch = errors.New("queue already exists with different arguments")
 
-	return m, nil
-}
+// DeclareQueueWithArguments declares a durable queue with x-arguments such as limits,
+// a dead-letter exchange or its type. It uses a channel of its own, because the broker
+// closes the channel of a declaration whose arguments differ from the existing queue
+func (m *Middleware) DeclareQueueWithArguments(queueName string, args amqp.Table) error {
+	c, err := m.await(m.ctx)
+	if err != nil {
+		return fmt.Errorf("failed to ensure connection: %w", err)
+	}
 
-func (m *Middleware) Connect(cfg *config.MiddlewareConfig) error {
-	delay := 5 * time.Second
-	maxDelay := 60 * time.Second
+	ch, err := c.conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open channel: %w", err)
+	}
+	defer ch.Close()
 
-	for {
-		select {
-		case <-m.ctx.Done():
-			return m.ctx.Err() // Context cancelled due to shutdown signal
-		default:
-			conn, err := amqp.DialConfig(
-				fmt.Sprintf("amqp://%s:%s@%s:%d/",
-					cfg.GetUsername(), cfg.GetPassword(), cfg.GetHost(), cfg.GetPort()),
-				amqp.Config{
-					Heartbeat: 5000 * time.Second,
-				},
-			)
-			if err == nil {
-				slog.Info("Connected to RabbitMQ",
-					"host", cfg.GetHost(),
-					"port", cfg.GetPort(),
-					"user", cfg.GetUsername())
-				m.channel, err = conn.Channel()
-				if err != nil {
-					slog.Info("Failed to create channel", "err", err)
-					continue
-				}
-				m.conn = conn
-				return nil
-			}
-			slog.Info("Failed to connect to RabbitMQ", "err", err, "retry_in", delay)
-			time.Sleep(delay)
-			if delay < maxDelay {
-				delay *= 2
-				if delay > maxDelay {
-					delay = maxDelay
-				}
-			}
-		}
+	_, err = ch.QueueDeclare(
+		queueName, // name
+		true,      // durable
+		false,     // delete when unused
+		false,     // exclusive
+		false,     // no-wait
+		args,      // arguments
+	)
+	var amqpErr *amqp.Error
+	if errors.As(err, &amqpErr) && amqpErr.Code == amqp.PreconditionFailed {
+		return fmt.Errorf("%w: %s", ErrQueueArgumentsMismatch, amqpErr.Reason)
 	}
+	return err
 }
 
-func (m *Middleware) DeclareQueue(queueName string, durable bool) error {
-	if err := m.ensureConnection(); err != nil {
-		return fmt.Errorf("failed to ensure connection: %w", err)
+// QueueExists reports whether a queue exists. Like DeclareQueueWithArguments it uses
+// a channel of its own, which the broker closes when the queue is missing
+func (m *Middleware) QueueExists(queueName string) (bool, error) {
+	c, err := m.await(m.ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to ensure connection: %w", err)
 	}
-	_, err := m.channel.QueueDeclare(
+
+	ch, err := c.conn.Channel()
+	if err != nil {
+		return false, fmt.Errorf("failed to open channel: %w", err)
+	}
+	defer ch.Close()
+
+	_, err = ch.QueueDeclarePassive(
 		queueName, // name
-		durable,   // durable
+		false,     // durable
 		false,     // delete when unused
 		false,     // exclusive
 		false,     // no-wait
 		nil,       // arguments
 	)
-	return err
+	var amqpErr *amqp.Error
+	if errors.As(err, &amqpErr) && amqpErr.Code == amqp.NotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
+// DeclareExchange declares an exchange, which is declared again on every reconnection
 func (m *Middleware) DeclareExchange(exchangeName string, exchangeType string, durable bool) error {
-	if err := m.ensureConnection(); err != nil {
+	c, err := m.await(m.ctx)
+	if err != nil {
 		return fmt.Errorf("failed to ensure connection: %w", err)
 	}
-	return m.channel.ExchangeDeclare(
-		exchangeName,
-		exchangeType,
-		durable, // durable
-		false,   // autoDelete
-		false,   // internal
-		false,   // noWait
-		nil,     // arguments
+
+	declaration := exchangeDeclaration{kind: exchangeType, durable: durable}
+	if err := declareExchange(c.channel, exchangeName, declaration); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.exchanges[exchangeName] = declaration
+	m.mu.Unlock()
+	return nil
+}
+
+// DeclareClientExchange declares a durable exchange owned by a client. Unlike
+// DeclareExchange it is not re-declared on reconnection: it lives as long as the
+// client's queues and is deleted with them
+func (m *Middleware) DeclareClientExchange(exchangeName string, exchangeType string) error {
+	c, err := m.await(m.ctx)
+	if err != nil {
+		return fmt.Errorf("failed to ensure connection: %w", err)
+	}
+	return declareExchange(c.channel, exchangeName, exchangeDeclaration{kind: exchangeType, durable: true})
+}
+
+// ExchangeExists reports whether an exchange exists. Like QueueExists it uses a
+// channel of its own, which the broker closes when the exchange is missing
+func (m *Middleware) ExchangeExists(exchangeName string) (bool, error) {
+	c, err := m.await(m.ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to ensure connection: %w", err)
+	}
+
+	ch, err := c.conn.Channel()
+	if err != nil {
+		return false, fmt.Errorf("failed to open channel: %w", err)
+	}
+	defer ch.Close()
+
+	err = ch.ExchangeDeclarePassive(
+		exchangeName, // name
+		"",           // kind, ignored by a passive declaration
+		false,        // durable
+		false,        // autoDelete
+		false,        // internal
+		false,        // noWait
+		nil,          // arguments
 	)
+	var amqpErr *amqp.Error
+	if errors.As(err, &amqpErr) && amqpErr.Code == amqp.NotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
 func (m *Middleware) BindQueue(queueName, exchangeName, routingKey string) error {
-	if err := m.ensureConnection(); err != nil {
+	c, err := m.await(m.ctx)
+	if err != nil {
 		return fmt.Errorf("failed to ensure connection: %w", err)
 	}
-	return m.channel.QueueBind(
+	return c.channel.QueueBind(
 		queueName,
 		routingKey,
 		exchangeName,
@@ -152,63 +255,288 @@ func (m *Middleware) BindQueue(queueName, exchangeName, routingKey string) error
 
 // Publish method compatible with Publisher interface
 func (m *Middleware) Publish(exchange string, body []byte) error {
-	if err := m.ensureConnection(); err != nil {
-		return fmt.Errorf("failed to ensure connection: %w", err)
-	}
-	return m.PublishWithRouting("", body, exchange)
+	return m.PublishWithRouting(context.Background(), "", body, exchange)
 }
 
-// PublishWithRouting publishes with specific routing key
-func (m *Middleware) PublishWithRouting(routingKey string, message []byte, exchangeName string) error {
+// Message is a single message of PublishBatch
+type Message struct {
+	Exchange   string
+	RoutingKey string
+	Body       []byte
+}
+
+// PublishWithRouting publishes with specific routing key and waits for the broker to confirm it
+// Messages are mandatory: one the broker cannot route to any queue fails with ErrUnroutable
+// and is not retried, as it would not reach anybody
+// Publishes are spread over a pool of confirm channels and are safe for concurrent use
+// A publish interrupted by a lost connection is retried once the supervisor reconnects
+// It gives up when ctx is done or after the configured publish timeout, whichever comes first
+// The trace context of ctx is injected in the message headers so consumers can continue the trace
+func (m *Middleware) PublishWithRouting(ctx context.Context, routingKey string, message []byte, exchangeName string) (err error) {
+	ctx, span := tracing.StartSpan(ctx, "publish "+exchangeName, trace.WithSpanKind(trace.SpanKindProducer), trace.WithAttributes(
+		attribute.String("messaging.system", "rabbitmq"),
+		attribute.String("messaging.destination.name", exchangeName),
+		attribute.String("messaging.rabbitmq.destination.routing_key", routingKey),
+	))
+	defer tracing.End(span, &err)
+
+	ctx, cancel := context.WithTimeout(ctx, m.config.GetMiddlewareConfig().GetPublishTimeout())
+	defer cancel()
+
+	publishing := newPublishing(ctx, message)
+
 	for attempt := 1; attempt <= MAX_RETRIES; attempt++ {
-		err := m.channel.Publish(
-			exchangeName,
-			routingKey,
-			false, // mandatory
-			false, // immediate
-			amqp.Publishing{
-				DeliveryMode: amqp.Persistent,
-				ContentType:  "application/json",
-				Body:         message,
-			},
-		)
+		span.SetAttributes(attribute.Int("messaging.publish.attempts", attempt))
+		c, err := m.await(ctx)
+		if err != nil {
+			metrics.RecordPublishFailure(exchangeName)
+			return fmt.Errorf("failed to publish message to exchange %s: %w", exchangeName, err)
+		}
 
+		metrics.RecordPublishAttempt(exchangeName)
+		result, err := publishOn(c, exchangeName, routingKey, publishing)
 		if err != nil {
-			slog.Error("Failed to publish message to exchange", "routing_key", routingKey, "exchange", exchangeName, "attempt", attempt)
-			time.Sleep(time.Second * time.Duration(attempt))
+			metrics.RecordPublishError(exchangeName)
+			slog.ErrorContext(ctx, "Failed to publish message to exchange", "routing_key", routingKey, "exchange", exchangeName, "attempt", attempt, "error", err)
+			if !sleep(ctx, time.Second*time.Duration(attempt)) {
+				break
+			}
 			continue
 		}
 
-		confirmed := <-m.confirms_chan
+		var outcome error
+		var confirmed bool
+		select {
+		case outcome, confirmed = <-result:
+		case <-ctx.Done():
+			metrics.RecordPublishFailure(exchangeName)
+			return fmt.Errorf("failed to publish message to exchange %s: no confirmation: %w", exchangeName, ctx.Err())
+		}
 
-		if !confirmed.Ack {
-			slog.Error("Failed to publish message to exchange - not acknowledged", "routing_key", routingKey, "exchange", exchangeName, "attempt", attempt)
-			time.Sleep(time.Second * time.Duration(attempt))
+		if !confirmed {
+			// The channel closed before confirming; the message may or may not have been routed
+			metrics.RecordPublishError(exchangeName)
+			slog.ErrorContext(ctx, "Failed to publish message to exchange - channel closed before confirmation", "routing_key", routingKey, "exchange", exchangeName, "attempt", attempt)
 			continue
 		}
 
-		slog.Debug("Published message to exchange", "routing_key", routingKey, "exchange", exchangeName)
+		if errors.Is(outcome, ErrUnroutable) {
+			metrics.RecordPublishReturn(exchangeName)
+			metrics.RecordPublishFailure(exchangeName)
+			slog.ErrorContext(ctx, "Failed to publish message to exchange - returned as unroutable", "routing_key", routingKey, "exchange", exchangeName, "error", outcome)
+			return fmt.Errorf("failed to publish message to exchange %s: %w", exchangeName, outcome)
+		}
+
+		if outcome != nil {
+			metrics.RecordPublishNack(exchangeName)
+			slog.ErrorContext(ctx, "Failed to publish message to exchange - not acknowledged", "routing_key", routingKey, "exchange", exchangeName, "attempt", attempt)
+			if !sleep(ctx, time.Second*time.Duration(attempt)) {
+				break
+			}
+			continue
+		}
+
+		slog.DebugContext(ctx, "Published message to exchange", "routing_key", routingKey, "exchange", exchangeName)
 
 		return nil
 	}
+	metrics.RecordPublishFailure(exchangeName)
+	if ctx.Err() != nil {
+		return fmt.Errorf("failed to publish message to exchange %s: %w", exchangeName, ctx.Err())
+	}
 	return fmt.Errorf("failed to publish message to exchange %s after %d attempts", exchangeName, MAX_RETRIES)
 }
 
+// PublishBatch publishes messages back to back on one channel, then waits for all
+// their confirmations, so a batch costs one round trip instead of one per message
+// It returns one error per message, nil for the confirmed ones; failed messages
+// are not retried. ctx and the publish timeout bound the whole batch
+func (m *Middleware) PublishBatch(ctx context.Context, messages []Message) []error {
+	ctx, span := tracing.StartSpan(ctx, "publish batch", trace.WithSpanKind(trace.SpanKindProducer), trace.WithAttributes(
+		attribute.String("messaging.system", "rabbitmq"),
+		attribute.Int("messaging.batch.message_count", len(messages)),
+	))
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, m.config.GetMiddlewareConfig().GetPublishTimeout())
+	defer cancel()
+
+	errs := make([]error, len(messages))
+	fail := func(i int, err error) {
+		metrics.RecordPublishFailure(messages[i].Exchange)
+		errs[i] = fmt.Errorf("failed to publish message to exchange %s: %w", messages[i].Exchange, err)
+	}
+
+	c, err := m.await(ctx)
+	if err != nil {
+		for i := range messages {
+			fail(i, err)
+		}
+		return errs
+	}
+	publisher, err := c.publisher()
+
+	publishing := newPublishing(ctx, nil)
+	results := make([]<-chan error, len(messages))
+	for i, message := range messages {
+		if err != nil {
+			fail(i, err)
+			continue
+		}
+		metrics.RecordPublishAttempt(message.Exchange)
+		publishing.Body = message.Body
+		results[i], err = publisher.publish(message.Exchange, message.RoutingKey, publishing)
+		if err != nil {
+			// The channel is unusable, so the rest of the batch fails the same way
+			metrics.RecordPublishError(message.Exchange)
+			fail(i, err)
+		}
+	}
+
+	failed := 0
+	for i, result := range results {
+		if result == nil {
+			failed++
+			continue
+		}
+		select {
+		case outcome, confirmed := <-result:
+			switch {
+			case !confirmed:
+				metrics.RecordPublishError(messages[i].Exchange)
+				fail(i, errors.New("channel closed before confirmation"))
+			case errors.Is(outcome, ErrUnroutable):
+				metrics.RecordPublishReturn(messages[i].Exchange)
+				fail(i, outcome)
+			case outcome != nil:
+				metrics.RecordPublishNack(messages[i].Exchange)
+				fail(i, outcome)
+			}
+		case <-ctx.Done():
+			fail(i, fmt.Errorf("no confirmation: %w", ctx.Err()))
+		}
+		if errs[i] != nil {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		span.SetStatus(codes.Error, fmt.Sprintf("%d of %d messages failed", failed, len(messages)))
+		slog.ErrorContext(ctx, "Failed to publish part of a batch", "failed", failed, "total", len(messages))
+	}
+	return errs
+}
+
+// publishOn publishes on one of the publishing channels of c
+func publishOn(c *connection, exchangeName, routingKey string, publishing amqp.Publishing) (<-chan error, error) {
+	publisher, err := c.publisher()
+	if err != nil {
+		return nil, err
+	}
+	return publisher.publish(exchangeName, routingKey, publishing)
+}
+
+// newPublishing builds a persistent JSON message carrying the trace context of ctx
+func newPublishing(ctx context.Context, body []byte) amqp.Publishing {
+	headers := amqp.Table{}
+	tracing.InjectAMQPHeaders(ctx, headers)
+
+	return amqp.Publishing{
+		Headers:      headers,
+		DeliveryMode: amqp.Persistent,
+		ContentType:  "application/json",
+		Body:         body,
+	}
+}
+
+// sleep waits for d, returning false if ctx is done first
+func sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// Consume starts consuming queueName on a dedicated channel with manual acks
+// The returned channel is closed when the consumer is cancelled or the connection drops
+func (m *Middleware) Consume(queueName, consumerTag string, prefetch int) (<-chan amqp.Delivery, error) {
+	c, err := m.await(m.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to ensure connection: %w", err)
+	}
+
+	// Consumers get their own channel so deliveries never interleave with publisher confirms
+	// It closes with the connection, which ends the delivery channel so the consumer re-subscribes
+	ch, err := c.conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open consumer channel: %w", err)
+	}
+
+	if err := ch.Qos(prefetch, 0, false); err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("failed to set QoS on consumer channel: %w", err)
+	}
+
+	deliveries, err := ch.Consume(
+		queueName,
+		consumerTag,
+		false, // autoAck
+		false, // exclusive
+		false, // noLocal
+		false, // noWait
+		nil,   // arguments
+	)
+	if err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("failed to consume from queue %s: %w", queueName, err)
+	}
+
+	m.consumersMu.Lock()
+	m.consumers[consumerTag] = ch
+	m.consumersMu.Unlock()
+
+	slog.Info("Started consuming queue", "queue", queueName, "consumer_tag", consumerTag)
+	return deliveries, nil
+}
+
+// CancelConsumer stops a consumer started with Consume
+// Unacknowledged deliveries are returned to the queue by the broker
+func (m *Middleware) CancelConsumer(consumerTag string) {
+	m.consumersMu.Lock()
+	ch, ok := m.consumers[consumerTag]
+	delete(m.consumers, consumerTag)
+	m.consumersMu.Unlock()
+
+	if !ok {
+		return
+	}
+	if err := ch.Close(); err != nil && err != amqp.ErrClosed {
+		slog.Error("Failed to close consumer channel", "consumer_tag", consumerTag, "error", err)
+	}
+}
+
 func (m *Middleware) Close() {
-	if err := m.channel.Close(); err != nil {
+	m.mu.RLock()
+	c := m.current
+	m.mu.RUnlock()
+
+	if err := c.channel.Close(); err != nil {
 		log.Printf("action: rabbitmq_channel_close | result: fail | error: %v", err)
 	}
-	if err := m.conn.Close(); err != nil {
+	if err := c.conn.Close(); err != nil {
 		log.Printf("action: rabbitmq_connection_close | result: fail | error: %v", err)
 	}
 }
 
 // DeleteQueue deletes a RabbitMQ queue
 func (m *Middleware) DeleteQueue(queueName string) error {
-	if err := m.ensureConnection(); err != nil {
+	c, err := m.await(m.ctx)
+	if err != nil {
 		return fmt.Errorf("failed to ensure connection: %w", err)
 	}
-	_, err := m.channel.QueueDelete(
+	_, err = c.channel.QueueDelete(
 		queueName, // name
 		false,     // ifUnused
 		false,     // ifEmpty
@@ -223,6 +551,26 @@ func (m *Middleware) DeleteQueue(queueName string) error {
 	return nil
 }
 
+// DeleteExchange deletes a RabbitMQ exchange and its bindings
+func (m *Middleware) DeleteExchange(exchangeName string) error {
+	c, err := m.await(m.ctx)
+	if err != nil {
+		return fmt.Errorf("failed to ensure connection: %w", err)
+	}
+	err = c.channel.ExchangeDelete(
+		exchangeName, // name
+		false,        // ifUnused
+		false,        // noWait
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to delete exchange %s: %w", exchangeName, err)
+	}
+
+	slog.Info("Deleted Exchange", "exchange", exchangeName)
+	return nil
+}
+
 //
 // HTTP Management API Methods
 //
@@ -240,7 +588,7 @@ func (m *Middleware) GetAdminCredentials() (string, string) {
 }
 
 // CreateUser creates a new RabbitMQ user using HTTP Management API
-func (m *Middleware) CreateUser(username, password string) error {
+func (m *Middleware) CreateUser(ctx context.Context, username, password string) error {
 	adminAPIURL := m.GetAdminAPIURL()
 	adminUser, adminPass := m.GetAdminCredentials()
 
@@ -256,7 +604,7 @@ func (m *Middleware) CreateUser(username, password string) error {
 		return fmt.Errorf("failed to marshal user data: %w", err)
 	}
 
-	req, err := http.NewRequest("PUT", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "PUT", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
@@ -264,8 +612,7 @@ func (m *Middleware) CreateUser(username, password string) error {
 	req.SetBasicAuth(adminUser, adminPass)
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := m.adminClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to execute request: %w", err)
 	}
@@ -279,16 +626,16 @@ func (m *Middleware) CreateUser(username, password string) error {
 	}
 
 	if resp.StatusCode == http.StatusCreated {
-		slog.Info("Created new RabbitMQ user", "username", username)
+		slog.InfoContext(ctx, "Created new RabbitMQ user", "username", username)
 	} else {
-		slog.Info("RabbitMQ user already exists, credentials updated", "username", username)
+		slog.InfoContext(ctx, "RabbitMQ user already exists, credentials updated", "username", username)
 	}
 
 	return nil
 }
 
 // SetPermissions sets permissions for a user on a vhost using HTTP Management API
-func (m *Middleware) SetPermissions(vhost, username, configurePattern, writePattern, readPattern string) error {
+func (m *Middleware) SetPermissions(ctx context.Context, vhost, username, configurePattern, writePattern, readPattern string) error {
 	adminAPIURL := m.GetAdminAPIURL()
 	adminUser, adminPass := m.GetAdminCredentials()
 
@@ -306,7 +653,7 @@ func (m *Middleware) SetPermissions(vhost, username, configurePattern, writePatt
 		return fmt.Errorf("failed to marshal permissions: %w", err)
 	}
 
-	req, err := http.NewRequest("PUT", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "PUT", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
@@ -314,8 +661,7 @@ func (m *Middleware) SetPermissions(vhost, username, configurePattern, writePatt
 	req.SetBasicAuth(adminUser, adminPass)
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := m.adminClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to execute request: %w", err)
 	}
@@ -325,26 +671,25 @@ func (m *Middleware) SetPermissions(vhost, username, configurePattern, writePatt
 		return fmt.Errorf("failed to set permissions, status code: %d", resp.StatusCode)
 	}
 
-	slog.Info("Set Permissions", "vhost", vhost, "username", username)
+	slog.InfoContext(ctx, "Set Permissions", "vhost", vhost, "username", username)
 	return nil
 }
 
 // DeleteUser deletes a RabbitMQ user using HTTP Management API
-func (m *Middleware) DeleteUser(username string) error {
+func (m *Middleware) DeleteUser(ctx context.Context, username string) error {
 	adminAPIURL := m.GetAdminAPIURL()
 	adminUser, adminPass := m.GetAdminCredentials()
 
 	url := fmt.Sprintf("%s/users/%s", adminAPIURL, username)
 
-	req, err := http.NewRequest("DELETE", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "DELETE", url, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.SetBasicAuth(adminUser, adminPass)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := m.adminClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to execute request: %w", err)
 	}
@@ -354,20 +699,40 @@ func (m *Middleware) DeleteUser(username string) error {
 		return fmt.Errorf("failed to delete user, status code: %d", resp.StatusCode)
 	}
 
-	slog.Info("Deleted User", "username", username)
+	slog.InfoContext(ctx, "Deleted User", "username", username)
 	return nil
 }
 
-func (m *Middleware) ensureConnection() error {
-	if m.conn != nil && !m.conn.IsClosed() && m.channel != nil {
-		return nil // All good
+// IsConnected reports whether the AMQP connection and its channels are usable
+func (m *Middleware) IsConnected() bool {
+	select {
+	case <-m.Ready():
+		return true
+	default:
+		return false
 	}
+}
 
-	slog.Info("RabbitMQ connection lost, reconnecting...")
+// PingAdminAPI verifies that the RabbitMQ Management API is reachable with the admin credentials
+func (m *Middleware) PingAdminAPI(ctx context.Context) error {
+	adminUser, adminPass := m.GetAdminCredentials()
 
-	m.Connect(m.config.GetMiddlewareConfig())
+	req, err := http.NewRequestWithContext(ctx, "GET", m.GetAdminAPIURL()+"/overview", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.SetBasicAuth(adminUser, adminPass)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
 
-	slog.Info("Reconnected to RabbitMQ successfully")
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("management API returned status code: %d", resp.StatusCode)
+	}
 	return nil
 }
 
@@ -0,0 +1,200 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+)
+
+// confirmBuffer lets the library hand over a burst of confirmations without
+// waiting for them to be dispatched one by one
+const confirmBuffer = 64
+
+// ErrUnroutable is returned for a message the broker could not route to any queue,
+// e.g. a notification on a fanout exchange no consumer queue is bound to
+var ErrUnroutable = errors.New("message could not be routed to any queue")
+
+// errNotAcknowledged is returned for a message the broker negatively acknowledged
+var errNotAcknowledged = errors.New("message not acknowledged by the broker")
+
+// pendingPublish is a publish waiting for its confirmation
+type pendingPublish struct {
+	result    chan error
+	messageID string
+	returned  *amqp.Return
+}
+
+// confirmChannel is a channel in confirm mode shared by concurrent publishers
+// Every publish is registered under the delivery tag the broker will confirm, so
+// each caller gets its own ack or nack and never one of another publish
+// Messages are published as mandatory: the broker returns those it cannot route,
+// matched to their publish by message ID, and they are reported as ErrUnroutable
+type confirmChannel struct {
+	channel *amqp.Channel
+
+	publishMu sync.Mutex // Serializes publishes so delivery tags follow publish order
+	nextTag   uint64     // Guarded by publishMu
+
+	pendingMu   sync.Mutex
+	pending     map[uint64]*pendingPublish // Guarded by pendingMu
+	byMessageID map[string]uint64          // Guarded by pendingMu
+	closed      bool                       // Guarded by pendingMu
+}
+
+// newConfirmChannel opens a confirm channel on conn; closedNotify is signalled
+// once the channel closes, so the supervisor can replace it
+func newConfirmChannel(conn *amqp.Connection, closedNotify chan<- struct{}) (*confirmChannel, error) {
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open publishing channel: %w", err)
+	}
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+
+	c := &confirmChannel{
+		channel:     ch,
+		pending:     make(map[uint64]*pendingPublish),
+		byMessageID: make(map[string]uint64),
+	}
+	confirms := ch.NotifyPublish(make(chan amqp.Confirmation, confirmBuffer))
+	returns := ch.NotifyReturn(make(chan amqp.Return, confirmBuffer))
+	go c.dispatch(confirms, returns, closedNotify)
+	return c, nil
+}
+
+// dispatch hands every confirmation to the publish it belongs to. When the channel
+// closes, the publishes still waiting are failed by closing their result channel
+func (c *confirmChannel) dispatch(confirms <-chan amqp.Confirmation, returns <-chan amqp.Return, closedNotify chan<- struct{}) {
+	c.receive(confirms, returns)
+
+	c.pendingMu.Lock()
+	c.closed = true
+	for tag, publish := range c.pending {
+		close(publish.result)
+		delete(c.pending, tag)
+	}
+	c.byMessageID = make(map[string]uint64)
+	c.pendingMu.Unlock()
+
+	select {
+	case closedNotify <- struct{}{}:
+	default: // A replacement is already pending
+	}
+}
+
+// receive processes returns and confirmations until the channel closes
+func (c *confirmChannel) receive(confirms <-chan amqp.Confirmation, returns <-chan amqp.Return) {
+	for {
+		select {
+		case ret, ok := <-returns:
+			if !ok {
+				returns = nil
+				continue
+			}
+			c.markReturned(ret)
+
+		case confirmation, ok := <-confirms:
+			if !ok {
+				return
+			}
+			// The broker sends the return of a message before its confirmation, and the
+			// library hands them over in that order, so any return is already buffered
+			c.drainReturns(returns)
+			c.resolve(confirmation)
+		}
+	}
+}
+
+func (c *confirmChannel) drainReturns(returns <-chan amqp.Return) {
+	for {
+		select {
+		case ret, ok := <-returns:
+			if !ok {
+				return
+			}
+			c.markReturned(ret)
+		default:
+			return
+		}
+	}
+}
+
+func (c *confirmChannel) markReturned(ret amqp.Return) {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+
+	if tag, ok := c.byMessageID[ret.MessageId]; ok {
+		c.pending[tag].returned = &ret
+	}
+}
+
+func (c *confirmChannel) resolve(confirmation amqp.Confirmation) {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+
+	publish, ok := c.pending[confirmation.DeliveryTag]
+	if !ok {
+		return
+	}
+	delete(c.pending, confirmation.DeliveryTag)
+	delete(c.byMessageID, publish.messageID)
+
+	switch {
+	case !confirmation.Ack:
+		publish.result <- errNotAcknowledged
+	case publish.returned != nil:
+		// An unroutable message is acked once returned, but it reached nobody
+		publish.result <- fmt.Errorf("%w: %s", ErrUnroutable, publish.returned.ReplyText)
+	default:
+		publish.result <- nil
+	}
+}
+
+// publish sends msg as mandatory and returns a channel receiving its outcome: nil
+// once confirmed, ErrUnroutable if it was returned or an error if it was nacked
+// The channel is closed without a value if the AMQP channel closes first
+func (c *confirmChannel) publish(exchange, routingKey string, msg amqp.Publishing) (<-chan error, error) {
+	if msg.MessageId == "" {
+		msg.MessageId = uuid.New().String()
+	}
+
+	c.publishMu.Lock()
+	defer c.publishMu.Unlock()
+
+	// Registered before publishing, as the confirmation may arrive before Publish returns
+	tag := c.nextTag + 1
+	publish := &pendingPublish{result: make(chan error, 1), messageID: msg.MessageId}
+
+	c.pendingMu.Lock()
+	if c.closed {
+		c.pendingMu.Unlock()
+		return nil, amqp.ErrClosed
+	}
+	c.pending[tag] = publish
+	c.byMessageID[msg.MessageId] = tag
+	c.pendingMu.Unlock()
+
+	if err := c.channel.Publish(exchange, routingKey, true, false, msg); err != nil {
+		// A failed publish does not consume a delivery tag
+		c.pendingMu.Lock()
+		delete(c.pending, tag)
+		delete(c.byMessageID, msg.MessageId)
+		c.pendingMu.Unlock()
+		return nil, err
+	}
+
+	c.nextTag = tag
+	return publish.result, nil
+}
+
+// isClosed reports whether the channel can no longer publish
+func (c *confirmChannel) isClosed() bool {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+	return c.closed
+}
@@ -0,0 +1,170 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/streadway/amqp"
+)
+
+// newTestConfirmChannel returns a confirm channel without an AMQP channel, with a
+// pending publish registered under delivery tags 1..n for message IDs m1..mn
+func newTestConfirmChannel(n int) (*confirmChannel, []*pendingPublish) {
+	c := &confirmChannel{
+		pending:     make(map[uint64]*pendingPublish),
+		byMessageID: make(map[string]uint64),
+	}
+	publishes := make([]*pendingPublish, n)
+	for i := range publishes {
+		tag := uint64(i + 1)
+		publishes[i] = &pendingPublish{result: make(chan error, 1), messageID: messageID(tag)}
+		c.pending[tag] = publishes[i]
+		c.byMessageID[publishes[i].messageID] = tag
+	}
+	return c, publishes
+}
+
+func messageID(tag uint64) string {
+	return fmt.Sprintf("m%d", tag)
+}
+
+// outcome reads the result of a publish: "ok", "unroutable", "nacked" or "closed"
+func outcome(t *testing.T, publish *pendingPublish) string {
+	t.Helper()
+	select {
+	case err, ok := <-publish.result:
+		switch {
+		case !ok:
+			return "closed"
+		case err == nil:
+			return "ok"
+		case errors.Is(err, ErrUnroutable):
+			return "unroutable"
+		case errors.Is(err, errNotAcknowledged):
+			return "nacked"
+		default:
+			t.Fatalf("unexpected publish error %v", err)
+		}
+	default:
+		t.Fatalf("publish %s has no outcome", publish.messageID)
+	}
+	return ""
+}
+
+func TestConfirmChannelDispatch(t *testing.T) {
+	tests := []struct {
+		name          string
+		publishes     int
+		returns       []uint64 // Tags of the returned messages, sent before their confirmation
+		confirmations []amqp.Confirmation
+		want          []string
+	}{
+		{
+			name:          "confirmations in order",
+			publishes:     2,
+			confirmations: []amqp.Confirmation{{DeliveryTag: 1, Ack: true}, {DeliveryTag: 2, Ack: true}},
+			want:          []string{"ok", "ok"},
+		},
+		{
+			name:          "confirmations out of order",
+			publishes:     3,
+			confirmations: []amqp.Confirmation{{DeliveryTag: 3, Ack: true}, {DeliveryTag: 1, Ack: false}, {DeliveryTag: 2, Ack: true}},
+			want:          []string{"nacked", "ok", "ok"},
+		},
+		{
+			name:          "returned message",
+			publishes:     2,
+			returns:       []uint64{2},
+			confirmations: []amqp.Confirmation{{DeliveryTag: 1, Ack: true}, {DeliveryTag: 2, Ack: true}},
+			want:          []string{"ok", "unroutable"},
+		},
+		{
+			name:          "channel closes with publishes pending",
+			publishes:     3,
+			confirmations: []amqp.Confirmation{{DeliveryTag: 2, Ack: true}},
+			want:          []string{"closed", "ok", "closed"},
+		},
+		{
+			name:          "confirmation of an unknown tag",
+			publishes:     1,
+			confirmations: []amqp.Confirmation{{DeliveryTag: 7, Ack: true}, {DeliveryTag: 1, Ack: true}},
+			want:          []string{"ok"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, publishes := newTestConfirmChannel(tt.publishes)
+
+			confirms := make(chan amqp.Confirmation, len(tt.confirmations))
+			returns := make(chan amqp.Return, len(tt.returns))
+			for _, tag := range tt.returns {
+				returns <- amqp.Return{MessageId: messageID(tag), ReplyText: "NO_ROUTE"}
+			}
+			for _, confirmation := range tt.confirmations {
+				confirms <- confirmation
+			}
+			close(confirms)
+			close(returns)
+
+			closedNotify := make(chan struct{}, 1)
+			c.dispatch(confirms, returns, closedNotify)
+
+			for i, publish := range publishes {
+				if got := outcome(t, publish); got != tt.want[i] {
+					t.Errorf("publish %d: outcome = %s, want %s", i+1, got, tt.want[i])
+				}
+			}
+			if !c.isClosed() {
+				t.Error("channel is not marked closed")
+			}
+			select {
+			case <-closedNotify:
+			default:
+				t.Error("supervisor was not notified of the closed channel")
+			}
+		})
+	}
+}
+
+func TestConnectionPublisher(t *testing.T) {
+	open := &confirmChannel{}
+	closed := &confirmChannel{closed: true}
+
+	tests := []struct {
+		name     string
+		channels []*confirmChannel
+		wantErr  bool
+	}{
+		{"all open", []*confirmChannel{open, open}, false},
+		{"skips closed channels", []*confirmChannel{closed, open, closed}, false},
+		{"all closed", []*confirmChannel{closed, closed}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &connection{publishers: make([]atomic.Pointer[confirmChannel], len(tt.channels))}
+			for i, channel := range tt.channels {
+				c.publishers[i].Store(channel)
+			}
+
+			for range len(tt.channels) {
+				publisher, err := c.publisher()
+				if tt.wantErr {
+					if !errors.Is(err, amqp.ErrClosed) {
+						t.Fatalf("publisher() error = %v, want amqp.ErrClosed", err)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("publisher() error = %v", err)
+				}
+				if publisher.isClosed() {
+					t.Fatal("publisher() returned a closed channel")
+				}
+			}
+		})
+	}
+}
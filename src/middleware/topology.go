@@ -2,8 +2,18 @@ package middleware
 
 import (
 	"connection-service/src/config"
+	"connection-service/src/metrics"
+	"connection-service/src/tracing"
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // RabbitMQTopologyManager manages RabbitMQ topology for client isolation
@@ -20,73 +30,245 @@ func NewTopologyManager(cfg *config.GlobalConfig, middleware *Middleware) *Rabbi
 	}
 }
 
-// SetUpTopologyFor creates the RabbitMQ topology for a client in the SHARED VHost ('/')
-// This includes: User, Client Queues, Exchange, Bindings, and Permissions
-func (tm *RabbitMQTopologyManager) SetUpTopologyFor(UserID string, password string) error {
-	const vhost = "/"
+// ErrTopologyIncomplete is returned when resources of a client's topology are missing
+var ErrTopologyIncomplete = errors.New("client topology is incomplete")
 
-	slog.Info("Setting up RabbitMQ topology for client",
+// clientVHost is the SHARED VHost every client user lives in
+const clientVHost = "/"
+
+// SetUpTopologyFor creates the RabbitMQ topology for a client in the SHARED VHost ('/')
+// This includes: User, the queues and exchanges of clientTopology with their bindings, and Permissions
+// Each part is also available as a separate step so callers can persist their progress
+// The queues follow the queue policy of the client's model type
+func (tm *RabbitMQTopologyManager) SetUpTopologyFor(ctx context.Context, UserID string, password string, modelType string) (err error) {
+	ctx, done := startOperation(ctx, "setup", UserID)
+	defer done(&err)
+	slog.InfoContext(ctx, "Setting up RabbitMQ topology for client",
 		"user_id", UserID,
-		"vhost", vhost,
+		"vhost", clientVHost,
 		"username", UserID)
 
-	if err := tm.middleware.CreateUser(UserID, password); err != nil {
+	if err := tm.CreateClientUser(ctx, UserID, password); err != nil {
+		return err
+	}
+	if err := tm.DeclareClientQueues(ctx, UserID, modelType); err != nil {
+		return err
+	}
+	if err := tm.SetClientPermissions(ctx, UserID); err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "Successfully set up RabbitMQ topology for client", "user_id", UserID)
+	return nil
+}
+
+// CreateClientUser creates (or overwrites) the broker user of a client
+func (tm *RabbitMQTopologyManager) CreateClientUser(ctx context.Context, UserID string, password string) (err error) {
+	ctx, done := startOperation(ctx, "create_user", UserID)
+	defer done(&err)
+	if err := tm.middleware.CreateUser(ctx, UserID, password); err != nil {
 		return fmt.Errorf("failed to create user: %w", err)
 	}
+	return nil
+}
 
-	dispatcherToClientQueue := fmt.Sprintf(config.DISPATCHER_TO_CLIENT_QUEUE, UserID)
-	clientToCalibrationQueue := fmt.Sprintf(config.CLIENT_TO_CALIBRATION_QUEUE, UserID)
+// DeclareClientQueues declares the durable queues of clientTopology for a client,
+// bounded by the queue policy of its model type, and binds them to their client
+// exchanges; it is idempotent
+// A queue that already exists under another policy keeps it until it is deleted with
+// the session, so a policy change only applies to the sessions started afterwards
+func (tm *RabbitMQTopologyManager) DeclareClientQueues(ctx context.Context, UserID string, modelType string) (err error) {
+	ctx, done := startOperation(ctx, "declare_queues", UserID)
+	defer done(&err)
+	args := tm.clientQueueArguments(modelType)
 
-	slog.Info("Middleware channel", "channel", tm.middleware.channel)
+	for _, q := range clientTopology {
+		queueName := q.name(UserID)
+		if err := tm.declareClientQueue(ctx, queueName, args); err != nil {
+			return fmt.Errorf("failed to create %s queue: %w", q.kind, err)
+		}
+		exchange := q.exchangeName(UserID)
+		if exchange == "" {
+			continue
+		}
+		if err := tm.middleware.DeclareClientExchange(exchange, "direct"); err != nil {
+			return fmt.Errorf("failed to create %s exchange: %w", q.kind, err)
+		}
+		if err := tm.middleware.BindQueue(queueName, exchange, queueName); err != nil {
+			return fmt.Errorf("failed to bind %s queue: %w", q.kind, err)
+		}
+	}
+	return nil
+}
+
+// declareClientQueue declares a client queue, keeping an existing queue declared
+// with other arguments rather than failing the session start
+func (tm *RabbitMQTopologyManager) declareClientQueue(ctx context.Context, queueName string, args amqp.Table) error {
+	err := tm.middleware.DeclareQueueWithArguments(queueName, args)
+	if errors.Is(err, ErrQueueArgumentsMismatch) {
+		slog.WarnContext(ctx, "Client queue exists with another policy, keeping it",
+			"queue", queueName,
+			"error", err)
+		return nil
+	}
+	return err
+}
 
-	if err := tm.middleware.DeclareQueue(dispatcherToClientQueue, true); err != nil {
-		return fmt.Errorf("failed to create dispatcher queue: %w", err)
+// clientQueueArguments translates the queue policy of a model type into the
+// x-arguments of the client queues. Expired and overflowing messages go to the
+// shared dead-letter exchange
+func (tm *RabbitMQTopologyManager) clientQueueArguments(modelType string) amqp.Table {
+	queueConfig := tm.config.GetQueueConfig()
+	policy := queueConfig.GetPolicy(modelType)
+
+	args := amqp.Table{
+		"x-queue-type":           policy.GetQueueType(),
+		"x-max-length":           int64(policy.GetMaxLength()),
+		"x-overflow":             policy.GetOverflow(),
+		"x-dead-letter-exchange": queueConfig.GetDeadLetterExchange(),
+	}
+	if ttl := policy.GetMessageTTL(); ttl > 0 {
+		args["x-message-ttl"] = ttl.Milliseconds()
 	}
-	if err := tm.middleware.DeclareQueue(clientToCalibrationQueue, true); err != nil {
-		return fmt.Errorf("failed to create calibration queue: %w", err)
+	return args
+}
+
+// DeclareDeadLetterExchange declares the dead-letter exchange shared by all client
+// queues, and a bounded queue keeping the dead-lettered messages for inspection
+func (tm *RabbitMQTopologyManager) DeclareDeadLetterExchange() error {
+	queueConfig := tm.config.GetQueueConfig()
+	exchange := queueConfig.GetDeadLetterExchange()
+	queue := queueConfig.GetDeadLetterQueue()
+
+	if err := tm.middleware.DeclareExchange(exchange, "fanout", true); err != nil {
+		return fmt.Errorf("failed to declare dead-letter exchange: %w", err)
 	}
 
-	readPattern := fmt.Sprintf("^%s$", dispatcherToClientQueue)
-	writePattern := fmt.Sprintf("^(%s|amq\\.default)$", clientToCalibrationQueue)
-	configurePattern := ""
+	err := tm.middleware.DeclareQueueWithArguments(queue, amqp.Table{
+		"x-max-length": int64(queueConfig.GetDeadLetterMaxLength()),
+		"x-overflow":   "drop-head",
+	})
+	if errors.Is(err, ErrQueueArgumentsMismatch) {
+		slog.Warn("Dead-letter queue exists with other arguments, keeping it", "queue", queue, "error", err)
+	} else if err != nil {
+		return fmt.Errorf("failed to declare dead-letter queue: %w", err)
+	}
 
-	if err := tm.middleware.SetPermissions(vhost, UserID, configurePattern, writePattern, readPattern); err != nil { //
+	if err := tm.middleware.BindQueue(queue, exchange, ""); err != nil {
+		return fmt.Errorf("failed to bind dead-letter queue: %w", err)
+	}
+	return nil
+}
+
+// SetClientPermissions restricts the client user to what clientTopology gives the
+// client role: reading the queues it consumes and writing to its own exchanges
+func (tm *RabbitMQTopologyManager) SetClientPermissions(ctx context.Context, UserID string) (err error) {
+	ctx, done := startOperation(ctx, "set_permissions", UserID)
+	defer done(&err)
+	permissions := permissionsFor(roleClient, UserID)
+
+	if err := tm.middleware.SetPermissions(ctx, clientVHost, UserID, permissions.configure, permissions.write, permissions.read); err != nil {
 		return fmt.Errorf("failed to set permissions for user %s: %w", UserID, err)
 	}
+	return nil
+}
+
+// RotateCredentialsFor replaces the broker password of an existing client user.
+// Queues and permissions are left untouched, so the client only needs to reconnect.
+func (tm *RabbitMQTopologyManager) RotateCredentialsFor(ctx context.Context, UserID string, password string) (err error) {
+	ctx, done := startOperation(ctx, "rotate_credentials", UserID)
+	defer done(&err)
+	if err := tm.middleware.CreateUser(ctx, UserID, password); err != nil {
+		return fmt.Errorf("failed to rotate credentials for user %s: %w", UserID, err)
+	}
 
-	slog.Info("Successfully set up RabbitMQ topology for client", "user_id", UserID)
+	slog.InfoContext(ctx, "Rotated RabbitMQ credentials for client", "user_id", UserID)
 	return nil
 }
 
 // DeleteTopologyFor removes all RabbitMQ resources for a client (useful for cleanup)
-func (tm *RabbitMQTopologyManager) DeleteTopologyFor(UserID string) error {
-	username := UserID
-	dispatcherQueue := fmt.Sprintf(config.DISPATCHER_TO_CLIENT_QUEUE, UserID)
-	calibrationQueue := fmt.Sprintf(config.CLIENT_TO_CALIBRATION_QUEUE, UserID)
-	dispatcherToCalibrationQueue := fmt.Sprintf(config.DISPATCHER_TO_CALIBRATION_QUEUE, UserID)
+func (tm *RabbitMQTopologyManager) DeleteTopologyFor(ctx context.Context, UserID string) (err error) {
+	ctx, done := startOperation(ctx, "delete", UserID)
+	defer done(&err)
 
-	slog.Info("Deleting RabbitMQ topology for client", "user_id", UserID)
+	username := UserID
 
-	if err := tm.middleware.DeleteQueue(dispatcherQueue); err != nil {
-		slog.Error("Failed to delete dispatcher queue", "queue", dispatcherQueue, "error", err)
-	}
-	if err := tm.middleware.DeleteQueue(calibrationQueue); err != nil {
-		slog.Error("Failed to delete calibration queue", "queue", calibrationQueue, "error", err)
-	}
+	slog.InfoContext(ctx, "Deleting RabbitMQ topology for client", "user_id", UserID)
 
-	if err := tm.middleware.DeleteQueue(dispatcherToCalibrationQueue); err != nil {
-		slog.Error("Failed to delete calibration queue", "queue", calibrationQueue, "error", err)
+	for _, q := range clientTopology {
+		queueName := q.name(UserID)
+		if err := tm.middleware.DeleteQueue(queueName); err != nil {
+			slog.ErrorContext(ctx, "Failed to delete "+q.kind+" queue", "queue", queueName, "error", err)
+		}
+		if exchange := q.exchangeName(UserID); exchange != "" {
+			if err := tm.middleware.DeleteExchange(exchange); err != nil {
+				slog.ErrorContext(ctx, "Failed to delete "+q.kind+" exchange", "exchange", exchange, "error", err)
+			}
+		}
 	}
 
-	if err := tm.middleware.DeleteUser(username); err != nil {
-		slog.Error("Failed to delete user", "username", username, "error", err)
+	if err := tm.middleware.DeleteUser(ctx, username); err != nil {
+		slog.ErrorContext(ctx, "Failed to delete user", "username", username, "error", err)
 		return fmt.Errorf("failed to complete topology deletion for %s", UserID)
 	}
 
-	slog.Info("Successfully deleted RabbitMQ topology for client", "user_id", UserID)
+	slog.InfoContext(ctx, "Successfully deleted RabbitMQ topology for client", "user_id", UserID)
+	return nil
+}
+
+// VerifyTopologyFor checks that every queue and exchange of clientTopology exists for a client
+// Missing resources are reported as ErrTopologyIncomplete
+func (tm *RabbitMQTopologyManager) VerifyTopologyFor(ctx context.Context, UserID string) (err error) {
+	_, done := startOperation(ctx, "verify", UserID)
+	defer done(&err)
+
+	var missing []string
+	for _, q := range clientTopology {
+		queueName := q.name(UserID)
+		exists, err := tm.middleware.QueueExists(queueName)
+		if err != nil {
+			return fmt.Errorf("failed to check %s queue: %w", q.kind, err)
+		}
+		if !exists {
+			missing = append(missing, queueName)
+		}
+
+		exchange := q.exchangeName(UserID)
+		if exchange == "" {
+			continue
+		}
+		exists, err = tm.middleware.ExchangeExists(exchange)
+		if err != nil {
+			return fmt.Errorf("failed to check %s exchange: %w", q.kind, err)
+		}
+		if !exists {
+			missing = append(missing, exchange)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: missing %s", ErrTopologyIncomplete, strings.Join(missing, ", "))
+	}
 	return nil
 }
 
+// startOperation starts the span of a topology operation. The returned function ends
+// the span and records the operation in the metrics; defer it with a pointer to the
+// operation's named error result
+func startOperation(ctx context.Context, operation, UserID string) (context.Context, func(err *error)) {
+	start := time.Now()
+	// The management API calls inherit the span but not the cancellation of the
+	// caller, so a topology change is not abandoned halfway through
+	ctx, span := tracing.StartSpan(context.WithoutCancel(ctx), "topology "+operation, trace.WithAttributes(
+		attribute.String("messaging.system", "rabbitmq"),
+		attribute.String("user_id", UserID),
+	))
+
+	return ctx, func(err *error) {
+		metrics.ObserveTopologyOperation(operation, start, *err)
+		tracing.End(span, err)
+	}
+}
+
 func (tm *RabbitMQTopologyManager) GetMiddleware() *Middleware {
 	return tm.middleware
 }
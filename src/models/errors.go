@@ -20,4 +20,19 @@ var (
 
 	// ErrInvalidSessionStatus indicates that the session status is invalid
 	ErrInvalidSessionStatus = errors.New("invalid session status")
+
+	// ErrInvalidDispatcherStatus indicates that the dispatcher status is unknown
+	ErrInvalidDispatcherStatus = errors.New("invalid dispatcher status")
+
+	// ErrInvalidDispatcherTransition indicates that the dispatcher cannot move to the requested status
+	ErrInvalidDispatcherTransition = errors.New("invalid dispatcher status transition")
+
+	// ErrOutboxMessageInFlight indicates that an outbox message is being published by another relay
+	ErrOutboxMessageInFlight = errors.New("outbox message is being published by another relay")
+
+	// ErrOutboxMessageUndeliverable indicates that the broker could not route an outbox message to any queue
+	ErrOutboxMessageUndeliverable = errors.New("outbox message is undeliverable")
+
+	// ErrSagaStateConflict indicates that a session start saga is not in the state required by the update
+	ErrSagaStateConflict = errors.New("session start saga state conflict")
 )
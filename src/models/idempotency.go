@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// IdempotencyRecord is the stored outcome of a request made with an Idempotency-Key
+type IdempotencyRecord struct {
+	UserID      string
+	Key         string
+	RequestHash string
+	StatusCode  int // 0 while the first request is still being processed
+	Response    []byte
+	SessionID   string
+	LockedUntil time.Time // Until when the first request holds the key; zero once it finished
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+// IsPending reports whether the first request with the key has not finished yet
+func (r *IdempotencyRecord) IsPending() bool {
+	return r.StatusCode == 0
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// OutboxMessage is a RabbitMQ message persisted for at-least-once publication
+// A nil AvailableAt holds the message until it is explicitly released
+type OutboxMessage struct {
+	MessageID   int64
+	SessionID   string
+	Exchange    string
+	RoutingKey  string
+	Payload     []byte
+	Attempts    int
+	LastError   string
+	AvailableAt *time.Time
+	CreatedAt   time.Time
+}
@@ -0,0 +1,74 @@
+package models
+
+import "time"
+
+// SagaState represents the overall state of a session start saga
+type SagaState string
+
+const (
+	SagaStateRunning      SagaState = "RUNNING"
+	SagaStateCompleted    SagaState = "COMPLETED"
+	SagaStateCompensating SagaState = "COMPENSATING"
+	SagaStateCompensated  SagaState = "COMPENSATED"
+)
+
+// SagaStep identifies a step of the session start saga
+type SagaStep string
+
+const (
+	SagaStepSessionCreated    SagaStep = "SESSION_CREATED"
+	SagaStepBrokerUserCreated SagaStep = "BROKER_USER_CREATED"
+	SagaStepQueuesDeclared    SagaStep = "QUEUES_DECLARED"
+	SagaStepPermissionsSet    SagaStep = "PERMISSIONS_SET"
+	SagaStepNotified          SagaStep = "NOTIFIED"
+)
+
+// sessionStartSteps lists the saga steps in execution order
+var sessionStartSteps = []SagaStep{
+	SagaStepSessionCreated,
+	SagaStepBrokerUserCreated,
+	SagaStepQueuesDeclared,
+	SagaStepPermissionsSet,
+	SagaStepNotified,
+}
+
+// RemainingSteps returns the steps that still have to run after s completed
+func (s SagaStep) RemainingSteps() []SagaStep {
+	for i, step := range sessionStartSteps {
+		if step == s {
+			return sessionStartSteps[i+1:]
+		}
+	}
+	return sessionStartSteps
+}
+
+// Reached reports whether step s has completed given that last is the latest completed step
+func (s SagaStep) Reached(last SagaStep) bool {
+	for _, step := range sessionStartSteps {
+		if step == s {
+			return true
+		}
+		if step == last {
+			return false
+		}
+	}
+	return false
+}
+
+// SessionStartSaga is the persisted progress of starting a session
+type SessionStartSaga struct {
+	SessionID string
+	UserID    string
+	ModelType string // Selects the queue policy of the client queues
+	State     SagaState
+	LastStep  SagaStep
+	Attempts  int
+	LastError string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// IsFinished reports whether the saga needs no further work
+func (s *SessionStartSaga) IsFinished() bool {
+	return s.State == SagaStateCompleted || s.State == SagaStateCompensated
+}
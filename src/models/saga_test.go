@@ -0,0 +1,69 @@
+package models
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSagaStepRemainingSteps(t *testing.T) {
+	tests := []struct {
+		last SagaStep
+		want []SagaStep
+	}{
+		{SagaStepSessionCreated, []SagaStep{SagaStepBrokerUserCreated, SagaStepQueuesDeclared, SagaStepPermissionsSet, SagaStepNotified}},
+		{SagaStepQueuesDeclared, []SagaStep{SagaStepPermissionsSet, SagaStepNotified}},
+		{SagaStepNotified, []SagaStep{}},
+		{SagaStep("UNKNOWN"), sessionStartSteps},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.last), func(t *testing.T) {
+			if got := tt.last.RemainingSteps(); !slices.Equal(got, tt.want) {
+				t.Errorf("%s.RemainingSteps() = %v, want %v", tt.last, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSagaStepReached(t *testing.T) {
+	tests := []struct {
+		name string
+		step SagaStep
+		last SagaStep
+		want bool
+	}{
+		{"last step itself", SagaStepQueuesDeclared, SagaStepQueuesDeclared, true},
+		{"earlier step", SagaStepBrokerUserCreated, SagaStepPermissionsSet, true},
+		{"later step", SagaStepNotified, SagaStepPermissionsSet, false},
+		{"next step", SagaStepBrokerUserCreated, SagaStepSessionCreated, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.step.Reached(tt.last); got != tt.want {
+				t.Errorf("%s.Reached(%s) = %v, want %v", tt.step, tt.last, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionStartSagaIsFinished(t *testing.T) {
+	tests := []struct {
+		state SagaState
+		want  bool
+	}{
+		{SagaStateRunning, false},
+		{SagaStateCompensating, false},
+		{SagaStateCompleted, true},
+		{SagaStateCompensated, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.state), func(t *testing.T) {
+			saga := &SessionStartSaga{State: tt.state}
+			if got := saga.IsFinished(); got != tt.want {
+				t.Errorf("IsFinished() in state %s = %v, want %v", tt.state, got, tt.want)
+			}
+		})
+	}
+}
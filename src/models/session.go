@@ -9,15 +9,65 @@ const (
 	StatusInProgress SessionStatus = "IN_PROGRESS"
 	StatusCompleted  SessionStatus = "COMPLETED"
 	StatusTimeout    SessionStatus = "TIMEOUT"
+	StatusFailed     SessionStatus = "FAILED"
+	StatusCancelled  SessionStatus = "CANCELLED"
 )
 
+// DispatcherStatus represents the progress reported by the dispatcher for a session
+type DispatcherStatus string
+
+const (
+	DispatcherStatusPending   DispatcherStatus = "PENDING"
+	DispatcherStatusReady     DispatcherStatus = "READY"
+	DispatcherStatusStreaming DispatcherStatus = "STREAMING"
+	DispatcherStatusDone      DispatcherStatus = "DONE"
+	DispatcherStatusFailed    DispatcherStatus = "FAILED"
+)
+
+// dispatcherProgress orders the statuses a dispatcher goes through
+// FAILED is not part of the progression: it can be reported from any non-terminal status
+var dispatcherProgress = map[DispatcherStatus]int{
+	DispatcherStatusPending:   0,
+	DispatcherStatusReady:     1,
+	DispatcherStatusStreaming: 2,
+	DispatcherStatusDone:      3,
+}
+
+// IsValid reports whether s is one of the known dispatcher statuses
+func (s DispatcherStatus) IsValid() bool {
+	switch s {
+	case DispatcherStatusPending, DispatcherStatusReady, DispatcherStatusStreaming,
+		DispatcherStatusDone, DispatcherStatusFailed:
+		return true
+	}
+	return false
+}
+
+// IsTerminal reports whether no further dispatcher status can follow s
+func (s DispatcherStatus) IsTerminal() bool {
+	return s == DispatcherStatusDone || s == DispatcherStatusFailed
+}
+
+// CanTransitionTo reports whether the dispatcher may move from s to next
+// Any later status of the progression is accepted, so a report delivered out of
+// order (e.g. DONE before STREAMING) skips the statuses it overtook
+func (s DispatcherStatus) CanTransitionTo(next DispatcherStatus) bool {
+	if s.IsTerminal() || s == next {
+		return false
+	}
+	if next == DispatcherStatusFailed {
+		return true
+	}
+	return dispatcherProgress[next] > dispatcherProgress[s]
+}
+
 // Session represents a client session in the database
 type Session struct {
-	SessionID        string        `json:"session_id"`
-	UserID           string        `json:"user_id"`
-	TokenID          string        `json:"token_id"`
-	SessionStatus    SessionStatus `json:"session_status"`
-	DispatcherStatus string        `json:"dispatcher_status"`
-	CreatedAt        time.Time     `json:"created_at"`
-	CompletedAt      *time.Time    `json:"completed_at,omitempty"`
+	SessionID        string           `json:"session_id"`
+	UserID           string           `json:"user_id"`
+	TokenID          string           `json:"token_id"`
+	SessionStatus    SessionStatus    `json:"session_status"`
+	DispatcherStatus DispatcherStatus `json:"dispatcher_status"`
+	CreatedAt        time.Time        `json:"created_at"`
+	CompletedAt      *time.Time       `json:"completed_at,omitempty"`
 }
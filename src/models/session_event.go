@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// SessionEventType identifies which status a session event refers to
+type SessionEventType string
+
+const (
+	EventTypeSessionStatus    SessionEventType = "SESSION_STATUS"
+	EventTypeDispatcherStatus SessionEventType = "DISPATCHER_STATUS"
+)
+
+// Well-known actors recorded on session events
+// HTTP callers are recorded as "http:<caller>"
+const (
+	ActorClient     = "client"
+	ActorReaper     = "reaper"
+	ActorDispatcher = "dispatcher"
+	ActorSaga       = "session-start-saga"
+)
+
+// StatusChange describes who requested a status change and why
+type StatusChange struct {
+	Actor  string
+	Reason string
+}
+
+// HTTPActor builds the actor recorded for a status change requested over HTTP
+func HTTPActor(caller string) string {
+	return "http:" + caller
+}
+
+// SessionEvent is an audit record of a single status change of a session
+type SessionEvent struct {
+	EventID   int64            `json:"event_id"`
+	SessionID string           `json:"session_id"`
+	EventType SessionEventType `json:"event_type"`
+	OldStatus string           `json:"old_status,omitempty"`
+	NewStatus string           `json:"new_status"`
+	Actor     string           `json:"actor"`
+	Reason    string           `json:"reason,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// sessionTransitions is the session state machine: the statuses reachable from each status
+// Every status other than IN_PROGRESS is terminal
+var sessionTransitions = map[SessionStatus][]SessionStatus{
+	StatusInProgress: {StatusCompleted, StatusTimeout, StatusFailed, StatusCancelled},
+}
+
+// IsValid reports whether s is one of the known session statuses
+func (s SessionStatus) IsValid() bool {
+	switch s {
+	case StatusInProgress, StatusCompleted, StatusTimeout, StatusFailed, StatusCancelled:
+		return true
+	}
+	return false
+}
+
+// IsTerminal reports whether no further transitions are allowed from s
+func (s SessionStatus) IsTerminal() bool {
+	return s.IsValid() && len(sessionTransitions[s]) == 0
+}
+
+// CanTransitionTo reports whether a session may move from s to next
+func (s SessionStatus) CanTransitionTo(next SessionStatus) bool {
+	for _, allowed := range sessionTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateSessionTransition checks a transition against the state machine and
+// returns a domain error describing why it is not allowed
+func ValidateSessionTransition(from, to SessionStatus) error {
+	if !from.IsValid() || !to.IsValid() {
+		return fmt.Errorf("transition %s -> %s: %w", from, to, ErrInvalidSessionStatus)
+	}
+	if from.CanTransitionTo(to) {
+		return nil
+	}
+	if from == StatusCompleted {
+		return fmt.Errorf("transition %s -> %s: %w", from, to, ErrSessionAlreadyCompleted)
+	}
+	if from != StatusInProgress {
+		return fmt.Errorf("transition %s -> %s: %w", from, to, ErrSessionNotInProgress)
+	}
+	return fmt.Errorf("transition %s -> %s: %w", from, to, ErrInvalidSessionStatus)
+}
+
+// IsInvalidTransition reports whether err was produced by a rejected state machine transition
+func IsInvalidTransition(err error) bool {
+	return errors.Is(err, ErrSessionNotInProgress) ||
+		errors.Is(err, ErrSessionAlreadyCompleted) ||
+		errors.Is(err, ErrInvalidSessionStatus)
+}
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDispatcherStatusCanTransitionTo(t *testing.T) {
+	tests := []struct {
+		name string
+		from DispatcherStatus
+		to   DispatcherStatus
+		want bool
+	}{
+		{"next status", DispatcherStatusPending, DispatcherStatusReady, true},
+		{"skip forward", DispatcherStatusPending, DispatcherStatusStreaming, true},
+		{"skip to done", DispatcherStatusReady, DispatcherStatusDone, true},
+		{"fail while pending", DispatcherStatusPending, DispatcherStatusFailed, true},
+		{"fail while streaming", DispatcherStatusStreaming, DispatcherStatusFailed, true},
+		{"same status", DispatcherStatusReady, DispatcherStatusReady, false},
+		{"regress", DispatcherStatusStreaming, DispatcherStatusReady, false},
+		{"regress to pending", DispatcherStatusDone, DispatcherStatusPending, false},
+		{"leave done", DispatcherStatusDone, DispatcherStatusFailed, false},
+		{"leave failed", DispatcherStatusFailed, DispatcherStatusDone, false},
+		{"unknown status", DispatcherStatusPending, DispatcherStatus("PAUSED"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+				t.Errorf("%s.CanTransitionTo(%s) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDispatcherStatusIsTerminal(t *testing.T) {
+	tests := []struct {
+		status DispatcherStatus
+		want   bool
+	}{
+		{DispatcherStatusPending, false},
+		{DispatcherStatusReady, false},
+		{DispatcherStatusStreaming, false},
+		{DispatcherStatusDone, true},
+		{DispatcherStatusFailed, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			if got := tt.status.IsTerminal(); got != tt.want {
+				t.Errorf("%s.IsTerminal() = %v, want %v", tt.status, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateSessionTransition(t *testing.T) {
+	tests := []struct {
+		from SessionStatus
+		to   SessionStatus
+		want error
+	}{
+		{StatusInProgress, StatusInProgress, ErrInvalidSessionStatus},
+		{StatusInProgress, StatusCompleted, nil},
+		{StatusInProgress, StatusTimeout, nil},
+		{StatusInProgress, StatusFailed, nil},
+		{StatusInProgress, StatusCancelled, nil},
+		{StatusCompleted, StatusInProgress, ErrSessionAlreadyCompleted},
+		{StatusCompleted, StatusCompleted, ErrSessionAlreadyCompleted},
+		{StatusCompleted, StatusTimeout, ErrSessionAlreadyCompleted},
+		{StatusCompleted, StatusFailed, ErrSessionAlreadyCompleted},
+		{StatusCompleted, StatusCancelled, ErrSessionAlreadyCompleted},
+		{StatusTimeout, StatusInProgress, ErrSessionNotInProgress},
+		{StatusTimeout, StatusCompleted, ErrSessionNotInProgress},
+		{StatusTimeout, StatusTimeout, ErrSessionNotInProgress},
+		{StatusTimeout, StatusFailed, ErrSessionNotInProgress},
+		{StatusTimeout, StatusCancelled, ErrSessionNotInProgress},
+		{StatusFailed, StatusInProgress, ErrSessionNotInProgress},
+		{StatusFailed, StatusCompleted, ErrSessionNotInProgress},
+		{StatusFailed, StatusTimeout, ErrSessionNotInProgress},
+		{StatusFailed, StatusFailed, ErrSessionNotInProgress},
+		{StatusFailed, StatusCancelled, ErrSessionNotInProgress},
+		{StatusCancelled, StatusInProgress, ErrSessionNotInProgress},
+		{StatusCancelled, StatusCompleted, ErrSessionNotInProgress},
+		{StatusCancelled, StatusTimeout, ErrSessionNotInProgress},
+		{StatusCancelled, StatusFailed, ErrSessionNotInProgress},
+		{StatusCancelled, StatusCancelled, ErrSessionNotInProgress},
+		{StatusInProgress, SessionStatus("PAUSED"), ErrInvalidSessionStatus},
+		{SessionStatus("PAUSED"), StatusCompleted, ErrInvalidSessionStatus},
+		{SessionStatus(""), StatusInProgress, ErrInvalidSessionStatus},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			err := ValidateSessionTransition(tt.from, tt.to)
+			if !errors.Is(err, tt.want) || (tt.want == nil) != (err == nil) {
+				t.Fatalf("ValidateSessionTransition(%s, %s) = %v, want %v", tt.from, tt.to, err, tt.want)
+			}
+			if err != nil && !IsInvalidTransition(err) {
+				t.Errorf("IsInvalidTransition(%v) = false", err)
+			}
+			if got := tt.from.CanTransitionTo(tt.to); got != (tt.want == nil) {
+				t.Errorf("%s.CanTransitionTo(%s) = %v, want %v", tt.from, tt.to, got, tt.want == nil)
+			}
+		})
+	}
+}
+
+func TestSessionStatusIsTerminal(t *testing.T) {
+	tests := []struct {
+		status SessionStatus
+		want   bool
+	}{
+		{StatusInProgress, false},
+		{StatusCompleted, true},
+		{StatusTimeout, true},
+		{StatusFailed, true},
+		{StatusCancelled, true},
+		{SessionStatus("PAUSED"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			if got := tt.status.IsTerminal(); got != tt.want {
+				t.Errorf("%s.IsTerminal() = %v, want %v", tt.status, got, tt.want)
+			}
+		})
+	}
+}
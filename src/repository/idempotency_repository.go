@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"connection-service/src/db"
+	"connection-service/src/models"
+)
+
+// IdempotencyRepository stores the outcome of requests made with an Idempotency-Key
+type IdempotencyRepository struct {
+	db *db.DB
+}
+
+// NewIdempotencyRepository creates a new idempotency repository
+func NewIdempotencyRepository(database *db.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{
+		db: database,
+	}
+}
+
+// Reserve claims an idempotency key for a new request that expires after ttl, holding
+// it for lease while the request is processed
+// It returns the new pending record and true if the key was free, had expired, or was
+// left pending by a request of the same fingerprint whose lease ran out (its replica
+// died before storing an outcome), or the existing record and false otherwise
+func (r *IdempotencyRepository) Reserve(ctx context.Context, userID, key, requestHash string, ttl, lease time.Duration) (*models.IdempotencyRecord, bool, error) {
+	now := time.Now()
+	record := &models.IdempotencyRecord{
+		UserID:      userID,
+		Key:         key,
+		RequestHash: requestHash,
+		LockedUntil: now.Add(lease),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+	}
+
+	var reserved bool
+	err := r.db.WithTransaction(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx,
+			`DELETE FROM idempotency_keys WHERE user_id = $1 AND idempotency_key = $2 AND expires_at < $3`,
+			userID, key, now)
+		if err != nil {
+			return fmt.Errorf("failed to delete expired idempotency key: %w", err)
+		}
+
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO idempotency_keys (user_id, idempotency_key, request_hash, locked_until, created_at, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (user_id, idempotency_key) DO UPDATE
+			SET locked_until = EXCLUDED.locked_until
+			WHERE idempotency_keys.status_code IS NULL
+			  AND idempotency_keys.locked_until < $5
+			  AND idempotency_keys.request_hash = EXCLUDED.request_hash
+		`, userID, key, requestHash, record.LockedUntil, record.CreatedAt, record.ExpiresAt)
+		if err != nil {
+			return fmt.Errorf("failed to reserve idempotency key: %w", err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		if rowsAffected == 1 {
+			reserved = true
+			return nil
+		}
+
+		record, err = getIdempotencyRecord(ctx, tx, userID, key)
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return record, reserved, nil
+}
+
+// Complete stores the response of the request that reserved the key
+func (r *IdempotencyRepository) Complete(ctx context.Context, userID, key string, statusCode int, response []byte, sessionID string) error {
+	query := `
+		UPDATE idempotency_keys
+		SET status_code = $1, response = $2, session_id = $3, locked_until = NULL
+		WHERE user_id = $4 AND idempotency_key = $5
+	`
+
+	_, err := r.db.GetConnection().ExecContext(ctx, query,
+		statusCode,
+		response,
+		sql.NullString{String: sessionID, Valid: sessionID != ""},
+		userID,
+		key,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+	return nil
+}
+
+// Release frees a pending key so the request can be retried, e.g. after a transient failure
+func (r *IdempotencyRepository) Release(ctx context.Context, userID, key string) error {
+	query := `DELETE FROM idempotency_keys WHERE user_id = $1 AND idempotency_key = $2 AND status_code IS NULL`
+
+	if _, err := r.db.GetConnection().ExecContext(ctx, query, userID, key); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+// Invalidate deletes the stored outcome of a key, so the next request with it runs again
+func (r *IdempotencyRepository) Invalidate(ctx context.Context, userID, key string) error {
+	query := `DELETE FROM idempotency_keys WHERE user_id = $1 AND idempotency_key = $2 AND status_code IS NOT NULL`
+
+	if _, err := r.db.GetConnection().ExecContext(ctx, query, userID, key); err != nil {
+		return fmt.Errorf("failed to invalidate idempotency key: %w", err)
+	}
+	return nil
+}
+
+// PurgeExpired deletes every expired key and returns how many were removed
+func (r *IdempotencyRepository) PurgeExpired(ctx context.Context) (int64, error) {
+	result, err := r.db.GetConnection().ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at < $1`, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge expired idempotency keys: %w", err)
+	}
+
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if purged > 0 {
+		slog.Info("Purged expired idempotency keys", "count", purged)
+	}
+	return purged, nil
+}
+
+func getIdempotencyRecord(ctx context.Context, tx *sql.Tx, userID, key string) (*models.IdempotencyRecord, error) {
+	query := `
+		SELECT user_id, idempotency_key, request_hash, status_code, response, session_id, locked_until, created_at, expires_at
+		FROM idempotency_keys
+		WHERE user_id = $1 AND idempotency_key = $2
+	`
+
+	var record models.IdempotencyRecord
+	var statusCode sql.NullInt64
+	var sessionID sql.NullString
+	var lockedUntil sql.NullTime
+	err := tx.QueryRowContext(ctx, query, userID, key).Scan(
+		&record.UserID,
+		&record.Key,
+		&record.RequestHash,
+		&statusCode,
+		&record.Response,
+		&sessionID,
+		&lockedUntil,
+		&record.CreatedAt,
+		&record.ExpiresAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+	record.StatusCode = int(statusCode.Int64)
+	record.SessionID = sessionID.String
+	record.LockedUntil = lockedUntil.Time
+
+	return &record, nil
+}
@@ -0,0 +1,321 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"connection-service/src/db"
+	"connection-service/src/models"
+)
+
+// maxOutboxBackoff caps the delay between publish attempts of a failing message
+const maxOutboxBackoff = 5 * time.Minute
+
+// OutboxPublishFunc publishes a single outbox message to the broker
+type OutboxPublishFunc func(ctx context.Context, message *models.OutboxMessage) error
+
+// OutboxBatchPublishFunc publishes outbox messages to the broker and returns one
+// error per message, nil for those the broker confirmed
+type OutboxBatchPublishFunc func(ctx context.Context, messages []models.OutboxMessage) []error
+
+// OutboxRepository handles the transactional outbox of RabbitMQ messages
+type OutboxRepository struct {
+	db *db.DB
+}
+
+// NewOutboxRepository creates a new outbox repository
+func NewOutboxRepository(database *db.DB) *OutboxRepository {
+	return &OutboxRepository{
+		db: database,
+	}
+}
+
+// PublishPending publishes up to limit messages that are due, oldest first.
+// The messages are claimed for lease in a short statement and published outside of
+// any transaction, so no row lock or pooled connection is held while the broker is
+// slow; concurrent relays skip claimed messages until their lease expires. The batch
+// is published at once and failed messages are rescheduled with backoff, except
+// unroutable ones, which are marked failed for good.
+// Returns the number of messages confirmed by the broker
+func (r *OutboxRepository) PublishPending(ctx context.Context, limit int, lease time.Duration, publish OutboxBatchPublishFunc) (int, error) {
+	query := `
+		UPDATE outbox_messages
+		SET leased_until = $1
+		WHERE message_id IN (
+			SELECT message_id
+			FROM outbox_messages
+			WHERE sent_at IS NULL AND failed_at IS NULL AND available_at <= $2
+				AND (leased_until IS NULL OR leased_until < $2)
+			ORDER BY message_id
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING message_id, session_id, exchange, routing_key, payload, attempts, available_at, created_at
+	`
+
+	now := time.Now()
+	rows, err := r.db.GetConnection().QueryContext(ctx, query, now.Add(lease), now, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim pending outbox messages: %w", err)
+	}
+	messages, err := scanOutboxMessages(rows)
+	if err != nil {
+		return 0, err
+	}
+	if len(messages) == 0 {
+		return 0, nil
+	}
+
+	publishErrs := publish(ctx, messages)
+
+	// Outcomes are recorded even if the relay is stopping, so confirmed messages are not sent again
+	recordCtx := context.WithoutCancel(ctx)
+	sent := 0
+	for i := range messages {
+		ok, err := r.recordPublishOutcome(recordCtx, &messages[i], publishErrs[i])
+		if err != nil {
+			return sent, err
+		}
+		if ok {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// PublishMessage publishes a single message right away, typically from the request
+// that created it, claiming it for lease first. It returns false without publishing
+// if the message was already sent, ErrOutboxMessageInFlight if another relay holds
+// a lease on it and ErrOutboxMessageUndeliverable if it was found unroutable before
+func (r *OutboxRepository) PublishMessage(ctx context.Context, messageID int64, lease time.Duration, publish OutboxPublishFunc) (bool, error) {
+	query := `
+		UPDATE outbox_messages
+		SET leased_until = $1
+		WHERE message_id = $2 AND sent_at IS NULL AND failed_at IS NULL
+			AND (leased_until IS NULL OR leased_until < $3)
+		RETURNING message_id, session_id, exchange, routing_key, payload, attempts, available_at, created_at
+	`
+
+	now := time.Now()
+	rows, err := r.db.GetConnection().QueryContext(ctx, query, now.Add(lease), messageID, now)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim outbox message: %w", err)
+	}
+	messages, err := scanOutboxMessages(rows)
+	if err != nil {
+		return false, err
+	}
+	if len(messages) == 0 {
+		var sent, failed bool
+		err := r.db.GetConnection().QueryRowContext(ctx,
+			`SELECT sent_at IS NOT NULL, failed_at IS NOT NULL FROM outbox_messages WHERE message_id = $1`,
+			messageID).Scan(&sent, &failed)
+		if err == sql.ErrNoRows || (err == nil && sent) {
+			// Already sent, or discarded together with its session
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to get outbox message: %w", err)
+		}
+		if failed {
+			return false, fmt.Errorf("publish outbox message %d: %w", messageID, models.ErrOutboxMessageUndeliverable)
+		}
+		return false, fmt.Errorf("publish outbox message %d: %w", messageID, models.ErrOutboxMessageInFlight)
+	}
+
+	cause := publish(ctx, &messages[0])
+	published, err := r.recordPublishOutcome(context.WithoutCancel(ctx), &messages[0], cause)
+	if err != nil {
+		return false, err
+	}
+	if !published {
+		return false, fmt.Errorf("failed to publish outbox message %d: %w", messageID, cause)
+	}
+	return true, nil
+}
+
+// ClaimUndeliverable leases up to limit released session messages that were marked
+// failed, so the relay can roll back their sessions. A message is claimed again once
+// its lease expires, until it is discarded together with its session
+func (r *OutboxRepository) ClaimUndeliverable(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxMessage, error) {
+	query := `
+		UPDATE outbox_messages
+		SET leased_until = $1
+		WHERE message_id IN (
+			SELECT message_id
+			FROM outbox_messages
+			WHERE failed_at IS NOT NULL AND session_id IS NOT NULL AND available_at IS NOT NULL
+				AND (leased_until IS NULL OR leased_until < $2)
+			ORDER BY message_id
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING message_id, session_id, exchange, routing_key, payload, attempts, available_at, created_at
+	`
+
+	now := time.Now()
+	rows, err := r.db.GetConnection().QueryContext(ctx, query, now.Add(lease), now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim undeliverable outbox messages: %w", err)
+	}
+	return scanOutboxMessages(rows)
+}
+
+// ReleaseSessionMessages makes the held messages of a session available to the relay
+// Returns the number of messages released
+func (r *OutboxRepository) ReleaseSessionMessages(ctx context.Context, sessionID string) (int64, error) {
+	query := `
+		UPDATE outbox_messages
+		SET available_at = $1
+		WHERE session_id = $2 AND sent_at IS NULL AND available_at IS NULL
+	`
+
+	result, err := r.db.GetConnection().ExecContext(ctx, query, time.Now(), sessionID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to release outbox messages: %w", err)
+	}
+
+	released, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return released, nil
+}
+
+// DiscardSessionMessages deletes the messages of a session that were never sent
+func (r *OutboxRepository) DiscardSessionMessages(ctx context.Context, sessionID string) error {
+	query := `DELETE FROM outbox_messages WHERE session_id = $1 AND sent_at IS NULL`
+
+	if _, err := r.db.GetConnection().ExecContext(ctx, query, sessionID); err != nil {
+		return fmt.Errorf("failed to discard outbox messages: %w", err)
+	}
+	return nil
+}
+
+// insertOutboxMessage stores a message as part of tx and sets its MessageID
+// A message without AvailableAt is held until released
+func insertOutboxMessage(ctx context.Context, tx *sql.Tx, message *models.OutboxMessage) error {
+	query := `
+		INSERT INTO outbox_messages
+		(session_id, exchange, routing_key, payload, available_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING message_id
+	`
+
+	message.CreatedAt = time.Now()
+
+	err := tx.QueryRowContext(ctx, query,
+		sql.NullString{String: message.SessionID, Valid: message.SessionID != ""},
+		message.Exchange,
+		message.RoutingKey,
+		message.Payload,
+		message.AvailableAt,
+		message.CreatedAt,
+	).Scan(&message.MessageID)
+	if err != nil {
+		return fmt.Errorf("failed to store outbox message: %w", err)
+	}
+	return nil
+}
+
+// recordPublishOutcome marks a claimed message as sent, or records publishErr on it
+// and reschedules it, releasing its lease either way. A message failing with
+// ErrOutboxMessageUndeliverable is marked failed instead of being rescheduled, as
+// retrying would only postpone the rollback of its session. A publish failure is recorded
+// on the row rather than returned, so the rest of the batch is still recorded; it is
+// reported through the boolean result
+func (r *OutboxRepository) recordPublishOutcome(ctx context.Context, message *models.OutboxMessage, publishErr error) (bool, error) {
+	conn := r.db.GetConnection()
+
+	if errors.Is(publishErr, models.ErrOutboxMessageUndeliverable) {
+		message.Attempts++
+		message.LastError = publishErr.Error()
+
+		slog.Error("Outbox message is undeliverable",
+			"message_id", message.MessageID,
+			"session_id", message.SessionID,
+			"exchange", message.Exchange,
+			"error", publishErr)
+
+		_, err := conn.ExecContext(ctx,
+			`UPDATE outbox_messages SET attempts = $1, last_error = $2, failed_at = $3, leased_until = NULL WHERE message_id = $4 AND sent_at IS NULL`,
+			message.Attempts, message.LastError, time.Now(), message.MessageID)
+		if err != nil {
+			return false, fmt.Errorf("failed to mark outbox message as failed: %w", err)
+		}
+		return false, nil
+	}
+
+	if publishErr != nil {
+		attempts := message.Attempts + 1
+		// Held messages stay held; only released ones are rescheduled for the relay
+		retryAt := sql.NullTime{Time: time.Now().Add(outboxBackoff(attempts)), Valid: message.AvailableAt != nil}
+		message.LastError = publishErr.Error()
+
+		slog.Warn("Failed to publish outbox message",
+			"message_id", message.MessageID,
+			"exchange", message.Exchange,
+			"attempts", attempts,
+			"retry_at", retryAt.Time,
+			"error", publishErr)
+
+		_, err := conn.ExecContext(ctx,
+			`UPDATE outbox_messages SET attempts = $1, last_error = $2, available_at = $3, leased_until = NULL WHERE message_id = $4 AND sent_at IS NULL`,
+			attempts, message.LastError, retryAt, message.MessageID)
+		if err != nil {
+			return false, fmt.Errorf("failed to record outbox publish failure: %w", err)
+		}
+		return false, nil
+	}
+
+	_, err := conn.ExecContext(ctx,
+		`UPDATE outbox_messages SET sent_at = $1, last_error = NULL, leased_until = NULL WHERE message_id = $2`,
+		time.Now(), message.MessageID)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark outbox message as sent: %w", err)
+	}
+
+	slog.Debug("Published outbox message", "message_id", message.MessageID, "exchange", message.Exchange)
+	return true, nil
+}
+
+// outboxBackoff returns the delay before retrying a message after its n-th failure
+func outboxBackoff(attempts int) time.Duration {
+	backoff := time.Duration(attempts*attempts) * time.Second
+	if backoff > maxOutboxBackoff {
+		return maxOutboxBackoff
+	}
+	return backoff
+}
+
+func scanOutboxMessages(rows *sql.Rows) ([]models.OutboxMessage, error) {
+	defer rows.Close()
+
+	var messages []models.OutboxMessage
+	for rows.Next() {
+		var message models.OutboxMessage
+		var sessionID sql.NullString
+		if err := rows.Scan(
+			&message.MessageID,
+			&sessionID,
+			&message.Exchange,
+			&message.RoutingKey,
+			&message.Payload,
+			&message.Attempts,
+			&message.AvailableAt,
+			&message.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox message: %w", err)
+		}
+		message.SessionID = sessionID.String
+		messages = append(messages, message)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate outbox messages: %w", err)
+	}
+	return messages, nil
+}
@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOutboxBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 4 * time.Second},
+		{10, 100 * time.Second},
+		{17, 289 * time.Second},
+		{18, maxOutboxBackoff},
+		{1000, maxOutboxBackoff},
+	}
+
+	for _, tt := range tests {
+		if got := outboxBackoff(tt.attempts); got != tt.want {
+			t.Errorf("outboxBackoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"connection-service/src/db"
+	"connection-service/src/models"
+)
+
+// SagaRepository persists the progress of session start sagas
+type SagaRepository struct {
+	db *db.DB
+}
+
+// NewSagaRepository creates a new saga repository
+func NewSagaRepository(database *db.DB) *SagaRepository {
+	return &SagaRepository{
+		db: database,
+	}
+}
+
+// GetSaga retrieves the start saga of a session
+// Returns nil without error if the session has no saga (e.g. it predates sagas)
+func (r *SagaRepository) GetSaga(ctx context.Context, sessionID string) (*models.SessionStartSaga, error) {
+	query := `
+		SELECT session_id, user_id, model_type, state, last_step, attempts, last_error, created_at, updated_at
+		FROM session_start_sagas
+		WHERE session_id = $1
+	`
+
+	rows, err := r.db.GetConnection().QueryContext(ctx, query, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session start saga: %w", err)
+	}
+	sagas, err := scanSagas(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(sagas) == 0 {
+		return nil, nil
+	}
+	return &sagas[0], nil
+}
+
+// RecordStep marks step as the last completed step of a running saga
+func (r *SagaRepository) RecordStep(ctx context.Context, sessionID string, step models.SagaStep) error {
+	query := `
+		UPDATE session_start_sagas
+		SET last_step = $1, updated_at = $2
+		WHERE session_id = $3 AND state = $4
+	`
+	return r.update(ctx, sessionID, "record saga step", query, step, time.Now(), sessionID, models.SagaStateRunning)
+}
+
+// Complete marks a running saga as completed
+func (r *SagaRepository) Complete(ctx context.Context, sessionID string) error {
+	query := `
+		UPDATE session_start_sagas
+		SET state = $1, last_step = $2, last_error = NULL, updated_at = $3
+		WHERE session_id = $4 AND state = $5
+	`
+	return r.update(ctx, sessionID, "complete saga", query,
+		models.SagaStateCompleted, models.SagaStepNotified, time.Now(), sessionID, models.SagaStateRunning)
+}
+
+// StartCompensation moves a running saga to COMPENSATING, recording why it failed
+// It is a no-op for a saga that is already compensating
+func (r *SagaRepository) StartCompensation(ctx context.Context, sessionID string, cause string) error {
+	query := `
+		UPDATE session_start_sagas
+		SET state = $1, last_error = $2, updated_at = $3
+		WHERE session_id = $4 AND state IN ($5, $1)
+	`
+	return r.update(ctx, sessionID, "start saga compensation", query,
+		models.SagaStateCompensating, cause, time.Now(), sessionID, models.SagaStateRunning)
+}
+
+// ReopenForCompensation moves a saga to COMPENSATING even if it already completed,
+// for a session whose notification turned out to be undeliverable after the saga
+// handed it over to the outbox relay
+func (r *SagaRepository) ReopenForCompensation(ctx context.Context, sessionID string, cause string) error {
+	query := `
+		UPDATE session_start_sagas
+		SET state = $1, last_error = $2, updated_at = $3
+		WHERE session_id = $4 AND state IN ($5, $6, $1)
+	`
+	return r.update(ctx, sessionID, "reopen saga for compensation", query,
+		models.SagaStateCompensating, cause, time.Now(), sessionID, models.SagaStateRunning, models.SagaStateCompleted)
+}
+
+// FinishCompensation marks a compensating saga as compensated
+func (r *SagaRepository) FinishCompensation(ctx context.Context, sessionID string) error {
+	query := `
+		UPDATE session_start_sagas
+		SET state = $1, updated_at = $2
+		WHERE session_id = $3 AND state = $4
+	`
+	return r.update(ctx, sessionID, "finish saga compensation", query,
+		models.SagaStateCompensated, time.Now(), sessionID, models.SagaStateCompensating)
+}
+
+// RecordFailure counts a failed attempt to advance or compensate an unfinished saga
+func (r *SagaRepository) RecordFailure(ctx context.Context, sessionID string, cause string) error {
+	query := `
+		UPDATE session_start_sagas
+		SET attempts = attempts + 1, last_error = $1, updated_at = $2
+		WHERE session_id = $3 AND state IN ($4, $5)
+	`
+	return r.update(ctx, sessionID, "record saga failure", query,
+		cause, time.Now(), sessionID, models.SagaStateRunning, models.SagaStateCompensating)
+}
+
+// ClaimStale leases up to limit unfinished sagas that made no progress since staleBefore
+// Claiming bumps updated_at, so other replicas skip the sagas until the lease goes stale again
+func (r *SagaRepository) ClaimStale(ctx context.Context, staleBefore time.Time, limit int) ([]models.SessionStartSaga, error) {
+	query := `
+		UPDATE session_start_sagas
+		SET updated_at = $1
+		WHERE session_id IN (
+			SELECT session_id
+			FROM session_start_sagas
+			WHERE state IN ($2, $3) AND updated_at < $4
+			ORDER BY updated_at
+			LIMIT $5
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING session_id, user_id, model_type, state, last_step, attempts, last_error, created_at, updated_at
+	`
+
+	rows, err := r.db.GetConnection().QueryContext(ctx, query,
+		time.Now(), models.SagaStateRunning, models.SagaStateCompensating, staleBefore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim stale sagas: %w", err)
+	}
+	return scanSagas(rows)
+}
+
+// update runs a single-row saga update and fails if the saga was not in the expected state
+func (r *SagaRepository) update(ctx context.Context, sessionID string, action string, query string, args ...interface{}) error {
+	result, err := r.db.GetConnection().ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to %s: %w", action, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s for session %s: %w", action, sessionID, models.ErrSagaStateConflict)
+	}
+
+	slog.Debug("Updated session start saga", "session_id", sessionID, "action", action)
+	return nil
+}
+
+// insertSessionStartSaga records a new saga whose first step is already done, as part of tx
+func insertSessionStartSaga(ctx context.Context, tx *sql.Tx, session *models.Session, modelType string) error {
+	query := `
+		INSERT INTO session_start_sagas
+		(session_id, user_id, model_type, state, last_step, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $6)
+	`
+
+	_, err := tx.ExecContext(ctx, query,
+		session.SessionID,
+		session.UserID,
+		modelType,
+		models.SagaStateRunning,
+		models.SagaStepSessionCreated,
+		time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record session start saga: %w", err)
+	}
+	return nil
+}
+
+func scanSagas(rows *sql.Rows) ([]models.SessionStartSaga, error) {
+	defer rows.Close()
+
+	var sagas []models.SessionStartSaga
+	for rows.Next() {
+		var saga models.SessionStartSaga
+		var lastError sql.NullString
+		if err := rows.Scan(
+			&saga.SessionID,
+			&saga.UserID,
+			&saga.ModelType,
+			&saga.State,
+			&saga.LastStep,
+			&saga.Attempts,
+			&lastError,
+			&saga.CreatedAt,
+			&saga.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan session start saga: %w", err)
+		}
+		saga.LastError = lastError.String
+		sagas = append(sagas, saga)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate session start sagas: %w", err)
+	}
+	return sagas, nil
+}
@@ -6,14 +6,39 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
 	"time"
 
 	"connection-service/src/db"
+	"connection-service/src/metrics"
 	"connection-service/src/models"
+	"connection-service/src/tracing"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// activeSessionConstraint is the partial unique index allowing one IN_PROGRESS session per user
+const activeSessionConstraint = "uq_client_sessions_active_user"
+
+// SessionFilter narrows down a session listing; zero values are ignored
+// Results are ordered by created_at DESC, session_id DESC and paginated with a keyset cursor
+type SessionFilter struct {
+	UserID           string
+	Status           models.SessionStatus
+	DispatcherStatus models.DispatcherStatus
+	CreatedAfter     *time.Time
+	CreatedBefore    *time.Time
+
+	// AfterCreatedAt and AfterSessionID identify the last row of the previous page
+	AfterCreatedAt *time.Time
+	AfterSessionID string
+
+	Limit int
+}
+
 // SessionRepository handles all database operations for sessions
 type SessionRepository struct {
 	db *db.DB
@@ -26,7 +51,10 @@ func NewSessionRepository(database *db.DB) *SessionRepository {
 	}
 }
 
-func (r *SessionRepository) GetSessionByID(ctx context.Context, sessionID string) (*models.Session, error) {
+func (r *SessionRepository) GetSessionByID(ctx context.Context, sessionID string) (_ *models.Session, err error) {
+	ctx, span := startQuerySpan(ctx, "GetSessionByID")
+	defer tracing.End(span, &err)
+
 	query := `
 		SELECT session_id, user_id, token_id, session_status, dispatcher_status, 
 		       created_at, completed_at
@@ -35,7 +63,7 @@ func (r *SessionRepository) GetSessionByID(ctx context.Context, sessionID string
 	`
 
 	var session models.Session
-	err := r.db.GetConnection().QueryRowContext(ctx, query, sessionID).Scan(
+	err = r.db.GetConnection().QueryRowContext(ctx, query, sessionID).Scan(
 		&session.SessionID,
 		&session.UserID,
 		&session.TokenID,
@@ -55,8 +83,98 @@ func (r *SessionRepository) GetSessionByID(ctx context.Context, sessionID string
 	return &session, nil
 }
 
+// ListSessions returns the sessions matching filter, newest first
+func (r *SessionRepository) ListSessions(ctx context.Context, filter SessionFilter) (_ []models.Session, err error) {
+	ctx, span := startQuerySpan(ctx, "ListSessions")
+	defer tracing.End(span, &err)
+
+	var conditions []string
+	var args []interface{}
+
+	addCondition := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.UserID != "" {
+		addCondition("user_id = $%d", filter.UserID)
+	}
+	if filter.Status != "" {
+		addCondition("session_status = $%d", filter.Status)
+	}
+	if filter.DispatcherStatus != "" {
+		addCondition("dispatcher_status = $%d", filter.DispatcherStatus)
+	}
+	if filter.CreatedAfter != nil {
+		addCondition("created_at >= $%d", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		addCondition("created_at < $%d", *filter.CreatedBefore)
+	}
+	if filter.AfterCreatedAt != nil {
+		args = append(args, *filter.AfterCreatedAt, filter.AfterSessionID)
+		conditions = append(conditions, fmt.Sprintf("(created_at, session_id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	query := `
+		SELECT session_id, user_id, token_id, session_status, dispatcher_status, 
+		       created_at, completed_at
+		FROM client_sessions
+	`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY created_at DESC, session_id DESC LIMIT $%d", len(args))
+
+	rows, err := r.db.GetConnection().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		var session models.Session
+		if err := rows.Scan(
+			&session.SessionID,
+			&session.UserID,
+			&session.TokenID,
+			&session.SessionStatus,
+			&session.DispatcherStatus,
+			&session.CreatedAt,
+			&session.CompletedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
+// CountActiveSessions returns the number of IN_PROGRESS sessions
+func (r *SessionRepository) CountActiveSessions(ctx context.Context) (_ int, err error) {
+	ctx, span := startQuerySpan(ctx, "CountActiveSessions")
+	defer tracing.End(span, &err)
+
+	query := `SELECT COUNT(*) FROM client_sessions WHERE session_status = $1`
+
+	var count int
+	if err := r.db.GetConnection().QueryRowContext(ctx, query, models.StatusInProgress).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count active sessions: %w", err)
+	}
+	return count, nil
+}
+
 // GetActiveSession retrieves an active session for a given User ID
-func (r *SessionRepository) GetActiveSession(ctx context.Context, UserID string) (*models.Session, error) {
+func (r *SessionRepository) GetActiveSession(ctx context.Context, UserID string) (_ *models.Session, err error) {
+	ctx, span := startQuerySpan(ctx, "GetActiveSession")
+	defer tracing.End(span, &err)
+
 	query := `
 		SELECT session_id, user_id, token_id, session_status, dispatcher_status, 
 		       created_at, completed_at
@@ -67,7 +185,7 @@ func (r *SessionRepository) GetActiveSession(ctx context.Context, UserID string)
 	`
 
 	var session models.Session
-	err := r.db.GetConnection().QueryRowContext(ctx, query, UserID, models.StatusInProgress).Scan(
+	err = r.db.GetConnection().QueryRowContext(ctx, query, UserID, models.StatusInProgress).Scan(
 		&session.SessionID,
 		&session.UserID,
 		&session.TokenID,
@@ -86,97 +204,201 @@ func (r *SessionRepository) GetActiveSession(ctx context.Context, UserID string)
 		return nil, fmt.Errorf("failed to get active session: %w", err)
 	}
 
-	slog.Info("Found active session",
+	slog.InfoContext(ctx, "Found active session",
 		"user_id", UserID,
 		"session_id", session.SessionID)
 
 	return &session, nil
 }
 
-// CreateSession creates a new session for a client
-func (r *SessionRepository) CreateSession(ctx context.Context, UserID string, tokenID string) (*models.Session, error) {
+// OutboxMessageBuilder builds the outbox message announcing a newly created session
+type OutboxMessageBuilder func(session *models.Session) (*models.OutboxMessage, error)
+
+// CreateSession creates a new session for a client together with its start saga
+// Returns ErrActiveSessionExists if the user already has an IN_PROGRESS session
+// tokenHash is the digest of the connection token and credentialsHash the digest of the
+// RabbitMQ password issued for the session. modelType is kept on the saga to pick the
+// queue policy of the client queues.
+// If buildNotification is not nil, the message it returns is stored in the outbox
+// in the same transaction, so the session never exists without its notification
+func (r *SessionRepository) CreateSession(ctx context.Context, UserID string, tokenID string, tokenHash string, credentialsHash string, modelType string, buildNotification OutboxMessageBuilder) (_ *models.Session, _ *models.OutboxMessage, err error) {
+	ctx, span := startQuerySpan(ctx, "CreateSession")
+	defer tracing.End(span, &err)
+
 	sessionID := uuid.New().String()
 	now := time.Now()
 
 	query := `
 		INSERT INTO client_sessions 
-		(session_id, user_id, token_id, session_status, dispatcher_status, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		(session_id, user_id, token_id, token_hash, session_status, dispatcher_status, 
+		 credentials_hash, credentials_rotated_at, last_activity_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8, $8)
 		RETURNING session_id, user_id, token_id, session_status, dispatcher_status, 
 		          created_at, completed_at
 	`
 
 	var session models.Session
-	err := r.db.GetConnection().QueryRowContext(
-		ctx,
-		query,
-		sessionID,
-		UserID,
-		tokenID,
-		models.StatusInProgress,
-		"PENDING", // dispatcher_status
-		now,       // created_at
-	).Scan(
-		&session.SessionID,
-		&session.UserID,
-		&session.TokenID,
-		&session.SessionStatus,
-		&session.DispatcherStatus,
-		&session.CreatedAt,
-		&session.CompletedAt,
-	)
+	var notification *models.OutboxMessage
+	err = r.db.WithTransaction(ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			sessionID,
+			UserID,
+			tokenID,
+			tokenHash,
+			models.StatusInProgress,
+			models.DispatcherStatusPending,
+			credentialsHash,
+			now, // credentials_rotated_at, last_activity_at and created_at
+		).Scan(
+			&session.SessionID,
+			&session.UserID,
+			&session.TokenID,
+			&session.SessionStatus,
+			&session.DispatcherStatus,
+			&session.CreatedAt,
+			&session.CompletedAt,
+		)
+		if isUniqueViolation(err, activeSessionConstraint) {
+			return fmt.Errorf("create session for user %s: %w", UserID, models.ErrActiveSessionExists)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create session: %w", err)
+		}
+
+		err = insertSessionEvent(ctx, tx, sessionID, models.EventTypeSessionStatus, "", string(models.StatusInProgress),
+			models.StatusChange{Actor: models.ActorClient, Reason: "session started"})
+		if err != nil {
+			return err
+		}
+
+		if err := insertSessionStartSaga(ctx, tx, &session, modelType); err != nil {
+			return err
+		}
+
+		if buildNotification == nil {
+			return nil
+		}
+		notification, err = buildNotification(&session)
+		if err != nil {
+			return fmt.Errorf("failed to build session notification: %w", err)
+		}
+		notification.SessionID = session.SessionID
+		return insertOutboxMessage(ctx, tx, notification)
+	})
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to create session: %w", err)
+		return nil, nil, err
 	}
 
-	slog.Info("Created new session",
+	slog.InfoContext(ctx, "Created new session",
 		"user_id", UserID,
 		"session_id", session.SessionID)
+	metrics.RecordSessionTransition(string(models.StatusInProgress))
 
-	return &session, nil
+	return &session, notification, nil
 }
 
-// UpdateSessionStatus updates the status of a session
-func (r *SessionRepository) UpdateSessionStatus(ctx context.Context, sessionID string, status models.SessionStatus) error {
+// TransitionSessionStatus atomically moves a session from status from to status to
+// The transition is validated against the session state machine and only applied if the
+// row still has status from; terminal statuses also set completed_at.
+// Rejected transitions return ErrSessionNotInProgress, ErrSessionAlreadyCompleted or
+// ErrInvalidSessionStatus, and a missing row returns ErrSessionNotFound
+// The change is recorded in session_events within the same transaction
+func (r *SessionRepository) TransitionSessionStatus(ctx context.Context, sessionID string, from, to models.SessionStatus, change models.StatusChange) (err error) {
+	ctx, span := startQuerySpan(ctx, "TransitionSessionStatus")
+	defer tracing.End(span, &err)
+
+	if err := models.ValidateSessionTransition(from, to); err != nil {
+		return fmt.Errorf("update session %s: %w", sessionID, err)
+	}
+
 	query := `
 		UPDATE client_sessions
-		SET session_status = $1
-		WHERE session_id = $2
+		SET session_status = $1,
+		    completed_at = CASE WHEN $2 THEN $3 ELSE completed_at END
+		WHERE session_id = $4 AND session_status = $5
 	`
 
-	result, err := r.db.GetConnection().ExecContext(ctx, query, status, sessionID)
-	if err != nil {
-		return fmt.Errorf("failed to update session status: %w", err)
-	}
+	var rowsAffected int64
+	err = r.db.WithTransaction(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, query, to, to.IsTerminal(), time.Now(), sessionID, from)
+		if err != nil {
+			return fmt.Errorf("failed to update session status: %w", err)
+		}
 
-	rowsAffected, err := result.RowsAffected()
+		rowsAffected, err = result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			return nil
+		}
+
+		return insertSessionEvent(ctx, tx, sessionID, models.EventTypeSessionStatus, string(from), string(to), change)
+	})
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return err
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("update session %s: %w", sessionID, models.ErrSessionNotFound)
+		// Either the session does not exist or its status changed concurrently
+		current, err := r.GetSessionStatus(ctx, sessionID)
+		if err != nil {
+			return fmt.Errorf("update session %s: %w", sessionID, err)
+		}
+		if err := models.ValidateSessionTransition(current, to); err != nil {
+			return fmt.Errorf("update session %s: %w", sessionID, err)
+		}
+		return fmt.Errorf("update session %s: status changed from %s to %s: %w", sessionID, from, current, models.ErrSessionNotInProgress)
 	}
 
-	slog.Info("Updated session status",
+	slog.InfoContext(ctx, "Updated session status",
 		"session_id", sessionID,
-		"status", status)
+		"from", from,
+		"to", to,
+		"actor", change.Actor)
+	metrics.RecordSessionTransition(string(to))
 
 	return nil
 }
 
-// SetSessionStatusToCompleted updates the session status to COMPLETED and sets completed_at
-func (r *SessionRepository) SetSessionStatusToCompleted(ctx context.Context, sessionID string) error {
+// GetSessionOwner returns the user of a session and the digest of the token it was
+// started with. The digest is empty for sessions created before digests were stored
+func (r *SessionRepository) GetSessionOwner(ctx context.Context, sessionID string) (_ string, _ string, err error) {
+	ctx, span := startQuerySpan(ctx, "GetSessionOwner")
+	defer tracing.End(span, &err)
+
+	query := `SELECT user_id, token_hash FROM client_sessions WHERE session_id = $1`
+
+	var userID string
+	var tokenHash sql.NullString
+	err = r.db.GetConnection().QueryRowContext(ctx, query, sessionID).Scan(&userID, &tokenHash)
+	if err == sql.ErrNoRows {
+		return "", "", fmt.Errorf("get owner of session %s: %w", sessionID, models.ErrSessionNotFound)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get session owner: %w", err)
+	}
+
+	return userID, tokenHash.String, nil
+}
+
+// UpdateSessionCredentials stores the digest of a newly issued RabbitMQ password for a session
+func (r *SessionRepository) UpdateSessionCredentials(ctx context.Context, sessionID string, credentialsHash string) (err error) {
+	ctx, span := startQuerySpan(ctx, "UpdateSessionCredentials")
+	defer tracing.End(span, &err)
+
 	query := `
 		UPDATE client_sessions
-		SET session_status = $1, completed_at = $2
+		SET credentials_hash = $1, credentials_rotated_at = $2, last_activity_at = $2
 		WHERE session_id = $3
 	`
 
-	result, err := r.db.GetConnection().ExecContext(ctx, query, models.StatusCompleted, time.Now(), sessionID)
+	result, err := r.db.GetConnection().ExecContext(ctx, query, credentialsHash, time.Now(), sessionID)
 	if err != nil {
-		return fmt.Errorf("failed to update session status to completed: %w", err)
+		return fmt.Errorf("failed to update session credentials: %w", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
@@ -185,46 +407,153 @@ func (r *SessionRepository) SetSessionStatusToCompleted(ctx context.Context, ses
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("update session %s: %w", sessionID, models.ErrSessionNotFound)
+		return fmt.Errorf("update credentials for session %s: %w", sessionID, models.ErrSessionNotFound)
 	}
 
-	slog.Info("Updated session status to COMPLETED",
-		"session_id", sessionID)
+	slog.InfoContext(ctx, "Rotated session credentials", "session_id", sessionID)
 
 	return nil
 }
 
-// UpdateDispatcherStatus updates the dispatcher status of a session
-func (r *SessionRepository) UpdateDispatcherStatus(ctx context.Context, sessionID string, status string) error {
-	query := `
+// UpdateDispatcherStatus moves the dispatcher status of a session to a new value and
+// returns the status it replaced. The session row is locked while the transition is
+// checked, so concurrent reports are applied one after the other instead of failing
+// Reporting the current status again is a no-op; a transition the current status does
+// not allow (a stale or regressive report) returns ErrInvalidDispatcherTransition
+// The change is recorded in session_events within the same transaction
+func (r *SessionRepository) UpdateDispatcherStatus(ctx context.Context, sessionID string, to models.DispatcherStatus, change models.StatusChange) (from models.DispatcherStatus, err error) {
+	ctx, span := startQuerySpan(ctx, "UpdateDispatcherStatus")
+	defer tracing.End(span, &err)
+
+	selectQuery := `
+		SELECT dispatcher_status
+		FROM client_sessions
+		WHERE session_id = $1
+		FOR UPDATE
+	`
+	updateQuery := `
 		UPDATE client_sessions
-		SET dispatcher_status = $1
-		WHERE session_id = $2
+		SET dispatcher_status = $1, last_activity_at = $2
+		WHERE session_id = $3
 	`
 
-	result, err := r.db.GetConnection().ExecContext(ctx, query, status, sessionID)
+	var updated bool
+	err = r.db.WithTransaction(ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, selectQuery, sessionID).Scan(&from)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("update dispatcher status for session %s: %w", sessionID, models.ErrSessionNotFound)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get dispatcher status: %w", err)
+		}
+
+		if from == to {
+			return nil
+		}
+		if !from.CanTransitionTo(to) {
+			return fmt.Errorf("update dispatcher status for session %s from %s to %s: %w", sessionID, from, to, models.ErrInvalidDispatcherTransition)
+		}
+
+		if _, err := tx.ExecContext(ctx, updateQuery, to, time.Now(), sessionID); err != nil {
+			return fmt.Errorf("failed to update dispatcher status: %w", err)
+		}
+		updated = true
+
+		return insertSessionEvent(ctx, tx, sessionID, models.EventTypeDispatcherStatus, string(from), string(to), change)
+	})
 	if err != nil {
-		return fmt.Errorf("failed to update dispatcher status: %w", err)
+		return from, err
 	}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+	if updated {
+		slog.InfoContext(ctx, "Updated dispatcher status",
+			"session_id", sessionID,
+			"from", from,
+			"to", to,
+			"actor", change.Actor)
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("update dispatcher status for session %s: %w", sessionID, models.ErrSessionNotFound)
+	return from, nil
+}
+
+// ListSessionEvents returns the status change history of a session, oldest first
+func (r *SessionRepository) ListSessionEvents(ctx context.Context, sessionID string) (_ []models.SessionEvent, err error) {
+	ctx, span := startQuerySpan(ctx, "ListSessionEvents")
+	defer tracing.End(span, &err)
+
+	query := `
+		SELECT event_id, session_id, event_type, old_status, new_status, actor, reason, created_at
+		FROM session_events
+		WHERE session_id = $1
+		ORDER BY created_at, event_id
+	`
+
+	rows, err := r.db.GetConnection().QueryContext(ctx, query, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list session events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []models.SessionEvent{}
+	for rows.Next() {
+		var event models.SessionEvent
+		var oldStatus, reason sql.NullString
+		if err := rows.Scan(
+			&event.EventID,
+			&event.SessionID,
+			&event.EventType,
+			&oldStatus,
+			&event.NewStatus,
+			&event.Actor,
+			&reason,
+			&event.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan session event: %w", err)
+		}
+		event.OldStatus = oldStatus.String
+		event.Reason = reason.String
+		events = append(events, event)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate session events: %w", err)
+	}
+
+	return events, nil
+}
 
+// insertSessionEvent records a status change in session_events as part of tx
+// An empty oldStatus or reason is stored as NULL
+func insertSessionEvent(ctx context.Context, tx *sql.Tx, sessionID string, eventType models.SessionEventType, oldStatus, newStatus string, change models.StatusChange) error {
+	query := `
+		INSERT INTO session_events
+		(session_id, event_type, old_status, new_status, actor, reason, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := tx.ExecContext(ctx, query,
+		sessionID,
+		eventType,
+		sql.NullString{String: oldStatus, Valid: oldStatus != ""},
+		newStatus,
+		change.Actor,
+		sql.NullString{String: change.Reason, Valid: change.Reason != ""},
+		time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record session event: %w", err)
+	}
 	return nil
 }
 
 // GetSessionStatus retrieves the session status for the given session ID
 // Returns the session status and error if not found
-func (r *SessionRepository) GetSessionStatus(ctx context.Context, sessionID string) (models.SessionStatus, error) {
+func (r *SessionRepository) GetSessionStatus(ctx context.Context, sessionID string) (_ models.SessionStatus, err error) {
+	ctx, span := startQuerySpan(ctx, "GetSessionStatus")
+	defer tracing.End(span, &err)
+
 	query := `SELECT session_status FROM client_sessions WHERE session_id = $1`
 	var status models.SessionStatus
-	err := r.db.GetConnection().QueryRowContext(ctx, query, sessionID).Scan(&status)
+	err = r.db.GetConnection().QueryRowContext(ctx, query, sessionID).Scan(&status)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return "", fmt.Errorf("get status for session %s: %w", sessionID, models.ErrSessionNotFound)
@@ -234,26 +563,116 @@ func (r *SessionRepository) GetSessionStatus(ctx context.Context, sessionID stri
 	return status, nil
 }
 
-// DeleteSession deletes a session by session ID
-// Just used in case of rollback during connection setup
-func (r *SessionRepository) DeleteSession(ctx context.Context, sessionID string) error {
-	query := `DELETE FROM client_sessions WHERE session_id = $1`
+// GetSessionCredentials returns the status of a session and the digest of the broker
+// password it was last issued
+func (r *SessionRepository) GetSessionCredentials(ctx context.Context, sessionID string) (_ models.SessionStatus, _ string, err error) {
+	ctx, span := startQuerySpan(ctx, "GetSessionCredentials")
+	defer tracing.End(span, &err)
+
+	query := `SELECT session_status, credentials_hash FROM client_sessions WHERE session_id = $1`
 
-	result, err := r.db.GetConnection().ExecContext(ctx, query, sessionID)
+	var status models.SessionStatus
+	var credentialsHash sql.NullString
+	err = r.db.GetConnection().QueryRowContext(ctx, query, sessionID).Scan(&status, &credentialsHash)
+	if err == sql.ErrNoRows {
+		return "", "", fmt.Errorf("get credentials of session %s: %w", sessionID, models.ErrSessionNotFound)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to delete session: %w", err)
+		return "", "", fmt.Errorf("failed to get session credentials: %w", err)
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	return status, credentialsHash.String, nil
+}
+
+// CountSessionsByToken returns how many sessions were started with a connection token
+func (r *SessionRepository) CountSessionsByToken(ctx context.Context, tokenID string) (_ int, err error) {
+	ctx, span := startQuerySpan(ctx, "CountSessionsByToken")
+	defer tracing.End(span, &err)
+
+	query := `SELECT COUNT(*) FROM client_sessions WHERE token_id = $1`
+
+	var count int
+	if err := r.db.GetConnection().QueryRowContext(ctx, query, tokenID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count sessions by token: %w", err)
+	}
+	return count, nil
+}
+
+// HasEndedSessionByToken reports whether a session started with a connection token was
+// completed, cancelled or timed out. A FAILED session never started, so it does not count
+func (r *SessionRepository) HasEndedSessionByToken(ctx context.Context, tokenID string) (_ bool, err error) {
+	ctx, span := startQuerySpan(ctx, "HasEndedSessionByToken")
+	defer tracing.End(span, &err)
+
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM client_sessions
+			WHERE token_id = $1 AND session_status IN ($2, $3, $4)
+		)
+	`
+
+	var ended bool
+	err = r.db.GetConnection().QueryRowContext(ctx, query, tokenID,
+		models.StatusCompleted, models.StatusCancelled, models.StatusTimeout).Scan(&ended)
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return false, fmt.Errorf("failed to check ended sessions by token: %w", err)
 	}
+	return ended, nil
+}
 
-	if rowsAffected == 0 {
-		slog.Warn("Attempted to delete non-existent session", "session_id", sessionID)
-		return nil // Don't fail if session doesn't exist (already deleted or never created)
+// ListStaleSessionIDs returns IN_PROGRESS sessions created before createdBefore or
+// without activity since idleBefore, oldest first. A NULL bound disables that check.
+func (r *SessionRepository) ListStaleSessionIDs(ctx context.Context, createdBefore, idleBefore sql.NullTime, limit int) (_ []string, err error) {
+	ctx, span := startQuerySpan(ctx, "ListStaleSessionIDs")
+	defer tracing.End(span, &err)
+
+	query := `
+		SELECT session_id
+		FROM client_sessions
+		WHERE session_status = $1
+		  AND (($2::timestamp IS NOT NULL AND created_at < $2)
+		    OR ($3::timestamp IS NOT NULL AND last_activity_at < $3))
+		ORDER BY created_at
+		LIMIT $4
+	`
+
+	rows, err := r.db.GetConnection().QueryContext(ctx, query, models.StatusInProgress, createdBefore, idleBefore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stale sessions: %w", err)
 	}
+	defer rows.Close()
 
-	slog.Info("Deleted session", "session_id", sessionID)
-	return nil
+	var sessionIDs []string
+	for rows.Next() {
+		var sessionID string
+		if err := rows.Scan(&sessionID); err != nil {
+			return nil, fmt.Errorf("failed to scan stale session: %w", err)
+		}
+		sessionIDs = append(sessionIDs, sessionID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate stale sessions: %w", err)
+	}
+
+	return sessionIDs, nil
+}
+
+// TryWithLock runs fn while holding the named cluster-wide advisory lock
+// Returns false without running fn if another replica already holds it
+func (r *SessionRepository) TryWithLock(ctx context.Context, name string, fn func(ctx context.Context) error) (bool, error) {
+	return r.db.TryWithAdvisoryLock(ctx, name, fn)
+}
+
+// startQuerySpan starts the client span of a repository query, named after the method
+func startQuerySpan(ctx context.Context, operation string) (context.Context, trace.Span) {
+	return tracing.StartSpan(ctx, "SessionRepository."+operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.operation.name", operation),
+	))
+}
+
+// isUniqueViolation reports whether err is a PostgreSQL unique violation of constraint
+func isUniqueViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == constraint
 }
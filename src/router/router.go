@@ -1,17 +1,26 @@
 package router
 
 import (
+	"connection-service/src/auth"
 	"connection-service/src/config"
 	"connection-service/src/controller"
 	"connection-service/src/db"
+	"connection-service/src/health"
+	"connection-service/src/metrics"
 	"connection-service/src/middleware"
 	"connection-service/src/repository"
+	"connection-service/src/requestid"
+	"connection-service/src/schemas"
 	"connection-service/src/service"
+	"connection-service/src/tokens"
+	"connection-service/src/usersclient"
 	"log/slog"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 // @title           Connection Service API
@@ -29,11 +38,40 @@ import (
 // @host      localhost:8080
 // @BasePath  /
 
-// @securityDefinitions.basic  BasicAuth
+// @securityDefinitions.apikey  ApiKeyAuth
+// @in                          header
+// @name                        X-API-Key
+
+// @securityDefinitions.apikey  BearerAuth
+// @in                          header
+// @name                        Authorization
+// @description                 API key sent as "Bearer <key>"
+
+// @securityDefinitions.apikey  HMACKeyId
+// @in                          header
+// @name                        X-Auth-Key-Id
+// @description                 Caller whose secret signed the request
+
+// @securityDefinitions.apikey  HMACTimestamp
+// @in                          header
+// @name                        X-Auth-Timestamp
+// @description                 Unix time of the signature, rejected outside AUTH_SIGNATURE_MAX_SKEW
+
+// @securityDefinitions.apikey  HMACSignature
+// @in                          header
+// @name                        X-Auth-Signature
+// @description                 Hex HMAC-SHA256 of METHOD, request URI, timestamp and hex SHA-256 of the body, joined by newlines
 
 // @externalDocs.description  OpenAPI
 // @externalDocs.url          https://swagger.io/resources/open-api/
 
+// untracedPaths are the routes left out of tracing
+var untracedPaths = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
 func createRouterFromConfig(config *config.GlobalConfig) *gin.Engine {
 	if config.GetLogLevel() == "production" {
 		gin.SetMode(gin.ReleaseMode)
@@ -42,19 +80,57 @@ func createRouterFromConfig(config *config.GlobalConfig) *gin.Engine {
 	}
 
 	r := gin.Default()
+	r.Use(requestid.Middleware())
+	r.Use(metrics.HTTPMiddleware())
+	// Probes and scrapes are frequent and carry no request of interest, so they are not traced
+	r.Use(otelgin.Middleware(config.GetTracingConfig().GetServiceName(), otelgin.WithFilter(func(req *http.Request) bool {
+		return !untracedPaths[req.URL.Path]
+	})))
 	return r
 }
 
-func InitializeSessionRoutes(r *gin.Engine, sessionController *controller.SessionController) {
+// InitializeHealthRoutes registers the Kubernetes probes and the Prometheus metrics,
+// which need no credentials
+func InitializeHealthRoutes(r *gin.Engine, healthController *controller.HealthController) {
+	r.GET("/healthz", healthController.Liveness)
+	r.GET("/readyz", healthController.Readiness)
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+}
+
+// InitializeSessionRoutes registers the session routes. /sessions/start and
+// /sessions/:session_id/disconnect are called by clients, which authenticate with their
+// connection token; every other route is for internal services and requires
+// credentials with the listed scopes
+func InitializeSessionRoutes(r *gin.Engine, sessionController *controller.SessionController, authenticator *auth.Authenticator) {
 	sessionsGroup := r.Group("/sessions")
 	{
+		sessionsGroup.GET("", authenticator.Require(auth.ScopeSessionsRead), sessionController.ListSessions)
 		sessionsGroup.POST("/start", sessionController.Start)
-		sessionsGroup.PUT("/:session_id/status/completed", sessionController.SetSessionStatusToCompleted)
-		sessionsGroup.PUT("/:session_id/status/timeout", sessionController.SetSessionStatusToTimeout)
+		sessionsGroup.GET("/:session_id", authenticator.Require(auth.ScopeSessionsRead), sessionController.GetSession)
+		sessionsGroup.GET("/:session_id/events", authenticator.Require(auth.ScopeSessionsRead), sessionController.GetSessionEvents)
+		sessionsGroup.PUT("/:session_id/status/completed", authenticator.Require(auth.ScopeSessionsWrite), sessionController.SetSessionStatusToCompleted)
+		sessionsGroup.PUT("/:session_id/status/timeout", authenticator.Require(auth.ScopeSessionsWrite), sessionController.SetSessionStatusToTimeout)
+		sessionsGroup.POST("/:session_id/disconnect", sessionController.Disconnect)
+		sessionsGroup.POST("/:session_id/credentials/rotate", authenticator.Require(auth.ScopeSessionsCredentials), sessionController.RotateCredentials)
+		sessionsGroup.PUT("/:session_id/dispatcher-status", authenticator.Require(auth.ScopeSessionsWrite), sessionController.UpdateDispatcherStatus)
 	}
 }
 
-func NewRouter(cfg *config.GlobalConfig, database *db.DB, rabbitmqMiddleware *middleware.Middleware) *gin.Engine {
+// NewProbeRouter serves the probes and metrics while the broker connection the other
+// routes depend on is being set up; every other route answers 503 until then
+func NewProbeRouter(cfg *config.GlobalConfig, checker *health.Checker) *gin.Engine {
+	r := createRouterFromConfig(cfg)
+	InitializeHealthRoutes(r, controller.NewHealthController(checker))
+
+	r.NoRoute(func(ctx *gin.Context) {
+		apiError := schemas.NewServiceUnavailableError("the service is starting, try again later", ctx.Request.URL.Path)
+		apiError.RequestID = requestid.FromContext(ctx.Request.Context())
+		ctx.JSON(apiError.Status, apiError)
+	})
+	return r
+}
+
+func NewRouter(cfg *config.GlobalConfig, database *db.DB, rabbitmqMiddleware *middleware.Middleware, usersClient usersclient.Client, tokenValidator tokens.Validator, authenticator *auth.Authenticator, checker *health.Checker) *gin.Engine {
 	r := createRouterFromConfig(cfg)
 
 	slog.Info("Initializing Connection Service router")
@@ -63,21 +139,34 @@ func NewRouter(cfg *config.GlobalConfig, database *db.DB, rabbitmqMiddleware *mi
 	tm := middleware.NewTopologyManager(cfg, rabbitmqMiddleware)
 
 	tm.GetMiddleware().DeclareExchange(config.CONNECTION_EXCHANGE, "fanout", true)
+	if err := tm.DeclareDeadLetterExchange(); err != nil {
+		slog.Error("Failed to declare dead-letter exchange", "error", err)
+	}
 
 	// Initialize session repository
 	sessionRepository := repository.NewSessionRepository(database)
 
+	// Initialize outbox relay used to publish notifications from the request path
+	outboxRepository := repository.NewOutboxRepository(database)
+	outboxRelay := service.NewOutboxRelay(outboxRepository, rabbitmqMiddleware, cfg.GetOutboxConfig())
+
+	// Initialize session start saga runner (recovery runs as a background worker)
+	sagaRunner := service.NewSessionStartSagaRunner(repository.NewSagaRepository(database), sessionRepository, outboxRepository, tm, cfg)
+
 	// Initialize connection service
-	connectionService := service.NewConnectionService(rabbitmqMiddleware, tm, cfg, sessionRepository)
+	connectionService := service.NewConnectionService(outboxRelay, sagaRunner, repository.NewIdempotencyRepository(database), usersClient, tokenValidator, tm, cfg, sessionRepository)
 
 	// Initialize session service
-	sessionService := service.NewSessionService(sessionRepository, tm, cfg)
+	sessionService := service.NewSessionService(sessionRepository, tm, usersClient, cfg)
 
 	// Initialize session controller
 	sessionController := controller.NewSessionController(sessionService, connectionService, cfg)
 
+	// Initialize health controller
+	healthController := controller.NewHealthController(checker)
+
 	// Initialize all routes
-	InitializeRoutes(r, sessionController)
+	InitializeRoutes(r, sessionController, healthController, authenticator)
 
 	// Swagger documentation
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
@@ -89,6 +178,9 @@ func NewRouter(cfg *config.GlobalConfig, database *db.DB, rabbitmqMiddleware *mi
 func InitializeRoutes(
 	r *gin.Engine,
 	sessionController *controller.SessionController,
+	healthController *controller.HealthController,
+	authenticator *auth.Authenticator,
 ) {
-	InitializeSessionRoutes(r, sessionController)
+	InitializeHealthRoutes(r, healthController)
+	InitializeSessionRoutes(r, sessionController, authenticator)
 }
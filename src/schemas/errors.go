@@ -13,6 +13,8 @@ type ErrorResponse struct {
 	Status   int    `json:"status"` // HTTP Status Code
 	Detail   string `json:"detail"`
 	Instance string `json:"instance"`
+	// RequestID is the X-Request-ID of the failed request, logged as request_id
+	RequestID string `json:"request_id,omitempty"`
 }
 
 // Error implements the error interface.
@@ -39,6 +41,16 @@ func NewBadRequestError(detail, instance string) *ErrorResponse {
 	return NewErrorResponse(http.StatusBadRequest, "Bad Request", detail, instance)
 }
 
+// NewUnauthorizedError creates a 401 Unauthorized error.
+func NewUnauthorizedError(detail, instance string) *ErrorResponse {
+	return NewErrorResponse(http.StatusUnauthorized, "Unauthorized", detail, instance)
+}
+
+// NewForbiddenError creates a 403 Forbidden error.
+func NewForbiddenError(detail, instance string) *ErrorResponse {
+	return NewErrorResponse(http.StatusForbidden, "Forbidden", detail, instance)
+}
+
 // NewNotFoundError creates a 404 Not Found error.
 func NewNotFoundError(detail, instance string) *ErrorResponse {
 	return NewErrorResponse(http.StatusNotFound, "Not Found", detail, instance)
@@ -49,6 +61,11 @@ func NewConflictError(detail, instance string) *ErrorResponse {
 	return NewErrorResponse(http.StatusConflict, "Conflict", detail, instance)
 }
 
+// NewUnprocessableEntityError creates a 422 Unprocessable Entity error.
+func NewUnprocessableEntityError(detail, instance string) *ErrorResponse {
+	return NewErrorResponse(http.StatusUnprocessableEntity, "Unprocessable Entity", detail, instance)
+}
+
 // NewInternalError creates a 500 Internal Server Error.
 // Note: Be careful not to expose sensitive technical details in production.
 func NewInternalError(detail, instance string) *ErrorResponse {
@@ -61,6 +78,12 @@ func NewBadGatewayError(detail, instance string) *ErrorResponse {
 	return NewErrorResponse(http.StatusBadGateway, "Bad Gateway", detail, instance)
 }
 
+// NewServiceUnavailableError creates a 503 Service Unavailable error.
+// Used when an upstream service is known to be down and the call was not attempted.
+func NewServiceUnavailableError(detail, instance string) *ErrorResponse {
+	return NewErrorResponse(http.StatusServiceUnavailable, "Service Unavailable", detail, instance)
+}
+
 // --- Domain-Specific Error Constructors ---
 
 // SessionNotInProgressError creates a 409 Conflict error for session not in progress.
@@ -0,0 +1,6 @@
+package schemas
+
+// LivenessResponse represents the response of the liveness probe
+type LivenessResponse struct {
+	Status string `json:"status"`
+}
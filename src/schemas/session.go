@@ -1,14 +1,81 @@
 package schemas
 
+import "connection-service/src/models"
+
 // UpdateSessionStatusRequest represents the request body for updating session status
 type UpdateSessionStatusRequest struct {
 	SessionID string `json:"session_id" binding:"required"`
 	Status    string `json:"status" binding:"required"`
 }
 
+// StatusChangeRequest represents the optional body of the session status endpoints
+type StatusChangeRequest struct {
+	Reason string `json:"reason"`
+}
+
+// DisconnectRequest represents the body of a client ending its own session
+// The client authenticates with its user ID and the token the session was started with
+type DisconnectRequest struct {
+	UserID string `json:"user_id" binding:"required"`
+	Token  string `json:"token" binding:"required"`
+	Reason string `json:"reason"`
+}
+
 // UpdateSessionStatusResponse represents the response for updating session status
 type UpdateSessionStatusResponse struct {
 	Message   string `json:"message"`
 	SessionID string `json:"session_id"`
 	Status    string `json:"status"`
 }
+
+// RotateCredentialsResponse represents the response for rotating session credentials
+type RotateCredentialsResponse struct {
+	Message     string               `json:"message"`
+	SessionID   string               `json:"session_id"`
+	Credentials *RabbitMQCredentials `json:"credentials"`
+}
+
+// ListSessionsQuery represents the query parameters accepted by GET /sessions
+// created_after and created_before are RFC 3339 timestamps
+type ListSessionsQuery struct {
+	UserID           string `form:"user_id"`
+	Status           string `form:"status"`
+	DispatcherStatus string `form:"dispatcher_status"`
+	CreatedAfter     string `form:"created_after"`
+	CreatedBefore    string `form:"created_before"`
+	Cursor           string `form:"cursor"`
+	Limit            int    `form:"limit"`
+}
+
+// ListSessionsResponse represents a page of sessions
+// NextCursor is empty when there are no more results
+type ListSessionsResponse struct {
+	Sessions   []models.Session `json:"sessions"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+// UpdateDispatcherStatusRequest represents the request body for reporting the dispatcher status
+type UpdateDispatcherStatusRequest struct {
+	Status string `json:"status" binding:"required"`
+	Reason string `json:"reason"`
+}
+
+// UpdateDispatcherStatusResponse represents the response for reporting the dispatcher status
+type UpdateDispatcherStatusResponse struct {
+	Message          string `json:"message"`
+	SessionID        string `json:"session_id"`
+	DispatcherStatus string `json:"dispatcher_status"`
+}
+
+// DispatcherStatusUpdate is the message the dispatcher publishes on the dispatcher status exchange
+type DispatcherStatusUpdate struct {
+	SessionID string `json:"session_id"`
+	Status    string `json:"status"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// SessionEventsResponse represents the status change history of a session
+type SessionEventsResponse struct {
+	SessionID string                `json:"session_id"`
+	Events    []models.SessionEvent `json:"events"`
+}
@@ -23,3 +23,9 @@ type TokenInfo struct {
 	UsageCount *int       `json:"usage_count,omitempty"`
 	MaxUses    *int       `json:"max_uses,omitempty"`
 }
+
+// UserUpdatedEvent is published by users-service on USER_EVENTS_EXCHANGE
+// whenever a user's profile or authorization changes
+type UserUpdatedEvent struct {
+	UserID string `json:"user_id"`
+}
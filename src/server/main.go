@@ -1,19 +1,31 @@
 package server
 
 import (
+	"connection-service/src/auth"
 	"connection-service/src/config"
 	"connection-service/src/db"
+	"connection-service/src/health"
+	"connection-service/src/metrics"
 	"connection-service/src/middleware"
+	"connection-service/src/repository"
 	"connection-service/src/router"
+	"connection-service/src/service"
+	"connection-service/src/tokens"
+	"connection-service/src/tracing"
+	"connection-service/src/usersclient"
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"syscall"
 
 	_ "connection-service/src/docs"
 
+	"github.com/gin-gonic/gin"
 	_ "github.com/swaggo/files"
 	_ "github.com/swaggo/gin-swagger"
 )
@@ -22,23 +34,82 @@ import (
 type Server struct {
 	config          *config.GlobalConfig
 	database        *db.DB
+	users           *usersclient.CachingClient
+	tokens          tokens.Validator
+	authenticator   *auth.Authenticator
+	health          *health.Checker
+	tracing         *tracing.Provider
 	http            *http.Server
+	broker          atomic.Pointer[middleware.Middleware] // Set once the broker is connected
 	shutdownHandler ShutdownHandlerInterface
 }
 
+// routerSwitch serves the probe router until the full router is ready, so the
+// probes answer while the broker connection is being set up
+type routerSwitch struct {
+	current atomic.Pointer[gin.Engine]
+}
+
+func (rs *routerSwitch) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	rs.current.Load().ServeHTTP(w, req)
+}
+
 // NewServer creates a new server instance
 func NewServer(cfg *config.GlobalConfig) (*Server, error) {
+	// Tracing comes first so the spans of the startup calls are exported too
+	tracer, err := tracing.Setup(cfg.GetTracingConfig())
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up tracing: %w", err)
+	}
+
 	// Initialize database connection
 	database, err := db.NewDB(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
+	// One users-service client for the whole process, so every caller shares its
+	// circuit breaker and profile cache
+	usersConfig := cfg.GetUsersServiceConfig()
+	usersHTTPClient := usersclient.NewHTTPClient(usersConfig)
+	usersClient := usersclient.NewCachingClient(
+		usersHTTPClient,
+		usersConfig.GetCacheTTL(),
+		usersConfig.GetCacheSize(),
+	)
+
+	tokenValidator, err := tokens.NewValidator(cfg.GetTokenConfig(), usersClient, repository.NewSessionRepository(database))
+	if err != nil {
+		database.Close()
+		return nil, fmt.Errorf("failed to set up token validation: %w", err)
+	}
+
+	authenticator, err := auth.NewAuthenticator(cfg.GetAuthConfig())
+	if err != nil {
+		database.Close()
+		return nil, fmt.Errorf("failed to set up authentication: %w", err)
+	}
+
+	// Readiness checks; the RabbitMQ ones fail until the broker connection exists
+	checker := health.NewChecker(cfg.GetHealthConfig().GetCheckTimeout())
+	checker.Register("postgres", true, database.Ping)
+	if cfg.GetHealthConfig().GetCheckUsersService() {
+		checker.Register("users-service", false, usersHTTPClient.Ping)
+	}
+
 	server := &Server{
-		config:   cfg,
-		database: database,
+		config:        cfg,
+		database:      database,
+		users:         usersClient,
+		tokens:        tokenValidator,
+		authenticator: authenticator,
+		health:        checker,
+		tracing:       tracer,
 	}
 
+	server.registerMetrics()
+	server.registerBrokerChecks()
+
 	// Create and assign shutdown handler
 	server.shutdownHandler = NewShutdownHandler(server)
 
@@ -56,32 +127,134 @@ func (s *Server) Run() error {
 }
 
 // startServerGoroutine starts the HTTP server in a goroutine and returns a channel for errors
+// The probes are served right away and report not ready until RabbitMQ is connected;
+// the other routes are served from then on. Failing to connect stops the server
 func (s *Server) startServerGoroutine() chan error {
-	serverDone := make(chan error, 1)
+	// The listener and the broker connection may both report an error
+	serverDone := make(chan error, 2)
+
+	handler := &routerSwitch{}
+	handler.current.Store(router.NewProbeRouter(s.config, s.health))
+
+	s.http = &http.Server{
+		Addr:    fmt.Sprintf("%s:%s", s.config.GetHost(), s.config.GetPort()),
+		Handler: handler,
+	}
+
+	slog.Info("Starting connection service",
+		"host", s.config.GetHost(),
+		"port", s.config.GetPort())
 
 	go func() {
+		serverDone <- s.startServer()
+	}()
 
-		middleware, err := middleware.NewMiddleware(s.config)
-		s.shutdownHandler.SetMiddleware(middleware)
-		r := router.NewRouter(s.config, s.database, middleware)
-		// Create HTTP server
-		httpServer := &http.Server{
-			Addr:    fmt.Sprintf("%s:%s", s.config.GetHost(), s.config.GetPort()),
-			Handler: r,
+	go func() {
+		mw, err := middleware.NewMiddleware(s.config)
+		if err != nil {
+			serverDone <- err
+			return
 		}
-		s.http = httpServer
+		s.shutdownHandler.SetMiddleware(mw)
+		s.startBackgroundWorkers(mw)
+		handler.current.Store(router.NewRouter(s.config, s.database, mw, s.users, s.tokens, s.authenticator, s.health))
+		s.broker.Store(mw)
 
-		slog.Info("Starting connection service",
-			"host", s.config.GetHost(),
-			"port", s.config.GetPort())
-
-		err = s.startServer()
-		serverDone <- err
+		slog.Info("Connected to RabbitMQ, serving all routes")
 	}()
 
 	return serverDone
 }
 
+// registerMetrics exports the gauges read from the database and the user profile cache
+func (s *Server) registerMetrics() {
+	metrics.RegisterDBStats(s.database.GetConnection(), s.config.GetDatabaseConfig().GetDBName())
+	metrics.RegisterActiveSessions(repository.NewSessionRepository(s.database).CountActiveSessions)
+
+	if !s.users.Enabled() {
+		return
+	}
+	metrics.RegisterCounterFunc("user_cache_hits_total", "User profile lookups served from the cache.", func() float64 {
+		return float64(s.users.Stats().Hits)
+	})
+	metrics.RegisterCounterFunc("user_cache_misses_total", "User profile lookups that went to users-service.", func() float64 {
+		return float64(s.users.Stats().Misses)
+	})
+	metrics.RegisterCounterFunc("user_cache_evictions_total", "User profiles evicted to stay within the cache size.", func() float64 {
+		return float64(s.users.Stats().Evictions)
+	})
+	metrics.RegisterCounterFunc("user_cache_invalidations_total", "User profiles dropped after a revocation or user-updated event.", func() float64 {
+		return float64(s.users.Stats().Invalidations)
+	})
+	metrics.RegisterGaugeFunc("user_cache_size", "User profiles currently cached.", func() float64 {
+		return float64(s.users.Stats().Size)
+	})
+}
+
+// errBrokerNotConnected fails the broker checks until the first connection is made
+var errBrokerNotConnected = errors.New("RabbitMQ is not connected yet")
+
+// registerBrokerChecks adds the RabbitMQ connection and Management API to the readiness
+// checks; both fail until the broker is connected and every route is served
+func (s *Server) registerBrokerChecks() {
+	s.health.Register("rabbitmq", true, func(ctx context.Context) error {
+		mw := s.broker.Load()
+		if mw == nil {
+			return errBrokerNotConnected
+		}
+		if !mw.IsConnected() {
+			return errors.New("AMQP connection is closed")
+		}
+		return nil
+	})
+	s.health.Register("rabbitmq-management", true, func(ctx context.Context) error {
+		mw := s.broker.Load()
+		if mw == nil {
+			return errBrokerNotConnected
+		}
+		return mw.PingAdminAPI(ctx)
+	})
+}
+
+// startBackgroundWorkers starts the components that run alongside the HTTP server
+// Each worker is registered with the shutdown handler so it is stopped on shutdown
+func (s *Server) startBackgroundWorkers(mw *middleware.Middleware) {
+	tm := middleware.NewTopologyManager(s.config, mw)
+	sessionRepository := repository.NewSessionRepository(s.database)
+	sessionService := service.NewSessionService(sessionRepository, tm, s.users, s.config)
+
+	reaper := service.NewSessionReaper(sessionService, sessionRepository, repository.NewIdempotencyRepository(s.database), s.config.GetReaperConfig())
+	s.shutdownHandler.RegisterWorker(reaper)
+	reaper.Start()
+
+	outboxRepository := repository.NewOutboxRepository(s.database)
+	sagaRunner := service.NewSessionStartSagaRunner(repository.NewSagaRepository(s.database), sessionRepository, outboxRepository, tm, s.config)
+
+	outboxRelay := service.NewOutboxRelay(outboxRepository, mw, s.config.GetOutboxConfig())
+	outboxRelay.OnUndeliverable(sagaRunner.CompensateUndeliverable)
+	s.shutdownHandler.RegisterWorker(outboxRelay)
+	outboxRelay.Start()
+
+	s.shutdownHandler.RegisterWorker(sagaRunner)
+	sagaRunner.Start()
+
+	consumer := service.NewDispatcherStatusConsumer(sessionService, mw, s.config)
+	if err := consumer.Start(); err != nil {
+		slog.Error("Failed to start dispatcher status consumer", "error", err)
+	} else {
+		s.shutdownHandler.RegisterWorker(consumer)
+	}
+
+	if s.users.Enabled() {
+		userEvents := service.NewUserEventsConsumer(s.users, mw, s.config)
+		if err := userEvents.Start(); err != nil {
+			slog.Error("Failed to start user events consumer", "error", err)
+		} else {
+			s.shutdownHandler.RegisterWorker(userEvents)
+		}
+	}
+}
+
 // startServer starts the HTTP server and handles errors
 func (s *Server) startServer() error {
 	if err := s.http.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -5,6 +5,8 @@ import (
 	"context"
 	"log/slog"
 	"os"
+	"sync"
+	"time"
 )
 
 // ShutdownHandlerInterface defines the interface for handling graceful shutdown
@@ -18,12 +20,23 @@ type ShutdownHandlerInterface interface {
 
 	// SetMiddleware sets the middleware for the shutdown handler
 	SetMiddleware(mw *middleware.Middleware)
+
+	// RegisterWorker adds a background worker to be stopped on shutdown
+	RegisterWorker(worker BackgroundWorker)
+}
+
+// BackgroundWorker is a long-running component started alongside the HTTP server
+type BackgroundWorker interface {
+	Stop()
 }
 
 // ShutdownHandler implements the ShutdownHandlerInterface
 type ShutdownHandler struct {
-	server *Server
+	server     *Server
 	middleware *middleware.Middleware
+	workers    []BackgroundWorker
+	stopped    bool // Set once shutdown started; later workers are stopped right away
+	mu         sync.Mutex
 }
 
 // NewShutdownHandler creates a new shutdown handler
@@ -52,6 +65,7 @@ func (h *ShutdownHandler) HandleShutdown(serverDone chan error, osSignals chan o
 			return nil
 		}
 		slog.Info("Received OS signal, initiating shutdown", "signal", sig)
+		h.drain()
 		h.ShutdownServer()
 
 		// Wait for server to finish
@@ -70,17 +84,46 @@ func (h *ShutdownHandler) handleServerError(err error) error {
 	return nil
 }
 
+// drain fails readiness and keeps serving for the configured delay, so the load
+// balancer stops sending new requests before the HTTP server stops accepting them
+func (h *ShutdownHandler) drain() {
+	h.server.health.StartDraining()
+
+	delay := h.server.config.GetHealthConfig().GetDrainDelay()
+	if delay <= 0 {
+		return
+	}
+	slog.Info("Draining: readiness is failing, waiting before shutdown", "delay", delay)
+	time.Sleep(delay)
+}
+
 // ShutdownServer initiates the shutdown of all server components
 func (h *ShutdownHandler) ShutdownServer() {
 	slog.Info("Shutting down server components...")
 
+	// Readiness fails from here on, whatever the dependencies report
+	h.server.health.StartDraining()
+
 	// Attempt graceful shutdown of HTTP server
-	if err := h.server.http.Shutdown(context.Background()); err != nil {
-		slog.Error("Error during HTTP server shutdown", "error", err)
+	if h.server.http != nil {
+		if err := h.server.http.Shutdown(context.Background()); err != nil {
+			slog.Error("Error during HTTP server shutdown", "error", err)
+		}
+	}
+
+	// Stop background workers before their dependencies go away. The broker may still
+	// be connecting; whatever it starts afterwards is stopped as it registers
+	h.mu.Lock()
+	h.stopped = true
+	workers := h.workers
+	mw := h.middleware
+	h.mu.Unlock()
+	for _, worker := range workers {
+		worker.Stop()
 	}
 
-	if h.middleware != nil {
-		h.middleware.HandleSigterm()
+	if mw != nil {
+		mw.HandleSigterm()
 	}
 
 	// Close database connection
@@ -89,10 +132,32 @@ func (h *ShutdownHandler) ShutdownServer() {
 		slog.Info("Database connection closed")
 	}
 
+	// Flush the spans of the requests finished during shutdown
+	h.server.tracing.Stop()
+
 	slog.Info("Server shutdown complete")
 }
 
-
 func (h *ShutdownHandler) SetMiddleware(mw *middleware.Middleware) {
+	h.mu.Lock()
+	stopped := h.stopped
 	h.middleware = mw
-}
\ No newline at end of file
+	h.mu.Unlock()
+
+	if stopped && mw != nil {
+		mw.HandleSigterm()
+	}
+}
+
+func (h *ShutdownHandler) RegisterWorker(worker BackgroundWorker) {
+	h.mu.Lock()
+	stopped := h.stopped
+	if !stopped {
+		h.workers = append(h.workers, worker)
+	}
+	h.mu.Unlock()
+
+	if stopped {
+		worker.Stop()
+	}
+}
@@ -1,83 +1,112 @@
 package service
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log/slog"
 	"net/http"
 
 	"connection-service/src/config"
 	"connection-service/src/middleware"
+	"connection-service/src/models"
 	"connection-service/src/repository"
 	"connection-service/src/schemas"
+	"connection-service/src/tokens"
+	"connection-service/src/usersclient"
 )
 
 type ConnectionService struct {
-	Publisher         middleware.Publisher
+	Outbox            *OutboxRelay
+	Saga              *SessionStartSagaRunner
+	Idempotency       *repository.IdempotencyRepository
+	Users             usersclient.Client
+	Tokens            tokens.Validator
 	TopologyManager   *middleware.RabbitMQTopologyManager
 	Config            *config.GlobalConfig
 	SessionRepository *repository.SessionRepository
 }
 
-func NewConnectionService(publisher middleware.Publisher, topologyManager *middleware.RabbitMQTopologyManager, cfg *config.GlobalConfig, sessionRepo *repository.SessionRepository) *ConnectionService {
+func NewConnectionService(outbox *OutboxRelay, saga *SessionStartSagaRunner, idempotency *repository.IdempotencyRepository, users usersclient.Client, tokenValidator tokens.Validator, topologyManager *middleware.RabbitMQTopologyManager, cfg *config.GlobalConfig, sessionRepo *repository.SessionRepository) *ConnectionService {
 	return &ConnectionService{
-		Publisher:         publisher,
+		Outbox:            outbox,
+		Saga:              saga,
+		Idempotency:       idempotency,
+		Users:             users,
+		Tokens:            tokenValidator,
 		TopologyManager:   topologyManager,
 		Config:            cfg,
 		SessionRepository: sessionRepo,
 	}
 }
 
-func (s *ConnectionService) NotifyNewConnection(UserID, sessionId, email, inputsFormat, outputsFormat, modelType string) error {
-
-	exchangeName := config.CONNECTION_EXCHANGE
-
-	notification := schemas.NotifyNewConnection{
-		UserID:        UserID,
-		SessionId:     sessionId,
-		Email:         email,
-		InputsFormat:  inputsFormat,
-		OutputsFormat: outputsFormat,
-		ModelType:     modelType,
-	}
-	body, err := json.Marshal(notification)
-	if err != nil {
-		return fmt.Errorf("failed to marshal notification: %w", err)
+// newConnectionNotification builds the outbox message announcing a new session to the dispatcher
+func (s *ConnectionService) newConnectionNotification(userData *schemas.UserInfo) repository.OutboxMessageBuilder {
+	return func(session *models.Session) (*models.OutboxMessage, error) {
+		notification := schemas.NotifyNewConnection{
+			UserID:        userData.ID,
+			SessionId:     session.SessionID,
+			Email:         userData.Email,
+			InputsFormat:  userData.InputsFormat,
+			OutputsFormat: userData.OutputsFormat,
+			ModelType:     userData.ModelType,
+		}
+		body, err := json.Marshal(notification)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal notification: %w", err)
+		}
+		return s.Outbox.NewMessage(config.CONNECTION_EXCHANGE, "", body), nil
 	}
-	return s.Publisher.Publish(exchangeName, body)
 }
 
 // HandleClientConnection manages the entire client connection flow
+// If idempotencyKey is set, retries of the same request get the first outcome replayed
 // Returns (response, error) following idiomatic Go error handling
-func (s *ConnectionService) HandleClientConnection(ctx context.Context, UserID string, token string) (*schemas.ConnectResponse, error) {
+func (s *ConnectionService) HandleClientConnection(ctx context.Context, UserID string, token string, idempotencyKey string) (*schemas.ConnectResponse, error) {
+	// A retry with an Idempotency-Key is answered before the token is validated again,
+	// which would spend another of its uses
+	if idempotencyKey != "" {
+		return s.connectIdempotent(ctx, UserID, token, idempotencyKey)
+	}
+
 	// Step 1: Validate Connection y obtener datos del usuario
-	userData, tokenID, err := s.validateConnection(token, UserID)
+	userData, tokenID, err := s.validateConnection(ctx, token, UserID)
 	if err != nil {
 		return nil, err
 	}
 
+	response, _, err := s.connect(ctx, UserID, token, userData, tokenID)
+	return response, err
+}
+
+// connect reconnects the client to its active session or starts a new one
+// It also returns the ID of the session the client is connected to
+func (s *ConnectionService) connect(ctx context.Context, UserID string, token string, userData *schemas.UserInfo, tokenID string) (*schemas.ConnectResponse, string, error) {
 	// Step 2: Query Database for Active Session
 	activeSession, err := s.SessionRepository.GetActiveSession(ctx, UserID)
 	if err != nil {
-		return nil, schemas.NewInternalError(
+		return nil, "", schemas.NewInternalError(
 			fmt.Sprintf("failed to query database: %v", err),
 			"/sessions/start",
 		)
 	}
 
-	// Prepare credentials (deterministic naming)
-	credentials := s.generateCredentials(UserID)
-
 	// Step 3: Check Active Session
 	if activeSession != nil {
 		// CASE A: Active Session Found - Client is reconnecting
-		slog.Info("Client reconnecting to existing session",
+		slog.InfoContext(ctx, "Client reconnecting to existing session",
 			"user_id", UserID,
 			"session_id", activeSession.SessionID)
 
+		credentials, err := s.reissueCredentials(ctx, activeSession.SessionID, UserID)
+		if err != nil {
+			return nil, "", err
+		}
+		if err := s.repairTopology(ctx, UserID, userData.ModelType); err != nil {
+			return nil, "", err
+		}
+
 		return &schemas.ConnectResponse{
 			Status:        "success",
 			Message:       "Client reconnected to existing session",
@@ -85,47 +114,70 @@ func (s *ConnectionService) HandleClientConnection(ctx context.Context, UserID s
 			InputsFormat:  userData.InputsFormat,
 			OutputsFormat: userData.OutputsFormat,
 			ModelType:     userData.ModelType,
-		}, nil
+		}, activeSession.SessionID, nil
 	}
 
 	// CASE B: No Active Session - New Client Connection (New Session)
-	slog.Info("Creating new session for client", "user_id", UserID)
+	slog.InfoContext(ctx, "Creating new session for client", "user_id", UserID)
 
-	// Action 1: Create new session in database
-	newSession, err := s.SessionRepository.CreateSession(ctx, UserID, tokenID)
+	// Prepare credentials (deterministic username, fresh random password)
+	credentials, err := s.generateCredentials(UserID)
 	if err != nil {
-		return nil, schemas.NewInternalError(
-			fmt.Sprintf("failed to create session: %v", err),
+		return nil, "", schemas.NewInternalError(
+			fmt.Sprintf("failed to generate credentials: %v", err),
 			"/sessions/start",
 		)
 	}
 
-	slog.Info("Created new session", "user_id", UserID, "session_id", newSession.SessionID)
-
-	// Action 2: Set up RabbitMQ topology
-	if err := s.TopologyManager.SetUpTopologyFor(UserID, credentials.Password); err != nil {
-		slog.Error("Failed to setup RabbitMQ topology", "user_id", UserID, "error", err)
-		s.SessionRepository.DeleteSession(ctx, newSession.SessionID)
-		return nil, schemas.NewInternalError(
-			fmt.Sprintf("failed to setup RabbitMQ topology: %v", err),
+	// Action 1: Create new session in database, together with its start saga and its
+	// (held) dispatcher notification in the outbox
+	newSession, notification, err := s.SessionRepository.CreateSession(ctx, UserID, tokenID, hashToken(token), hashPassword(credentials.Password), userData.ModelType, s.newConnectionNotification(userData))
+	if errors.Is(err, models.ErrActiveSessionExists) {
+		// A concurrent request for the same user won the race
+		return nil, "", schemas.NewConflictError(
+			fmt.Sprintf("user %s already has an active session", UserID),
+			"/sessions/start",
+		)
+	}
+	if err != nil {
+		return nil, "", schemas.NewInternalError(
+			fmt.Sprintf("failed to create session: %v", err),
 			"/sessions/start",
 		)
 	}
 
-	// Action 3: Notificar dispatcher service usando userData
-	slog.Info("Fetched user data", "user_id", UserID, "user_data", userData)
+	slog.InfoContext(ctx, "Created new session", "user_id", UserID, "session_id", newSession.SessionID)
 
-	if err := s.NotifyNewConnection(userData.ID, newSession.SessionID, userData.Email, userData.InputsFormat, userData.OutputsFormat, userData.ModelType); err != nil {
-		slog.Error("Failed to notify new connection", "user_id", UserID, "session_id", newSession.SessionID, "error", err)
-		s.TopologyManager.DeleteTopologyFor(UserID)
-		s.SessionRepository.DeleteSession(ctx, newSession.SessionID)
-		return nil, schemas.NewInternalError(
-			fmt.Sprintf("failed to notify new connection: %v", err),
+	// Action 2: Run the remaining saga steps: broker user, queues, permissions and the
+	// dispatcher notification. A failed step compensates everything done so far
+	slog.InfoContext(ctx, "Fetched user data", "user_id", UserID, "user_data", userData)
+
+	saga := &models.SessionStartSaga{
+		SessionID: newSession.SessionID,
+		UserID:    UserID,
+		ModelType: userData.ModelType,
+		State:     models.SagaStateRunning,
+		LastStep:  models.SagaStepSessionCreated,
+	}
+	notify := func(ctx context.Context) error {
+		return s.Outbox.PublishNow(ctx, notification.MessageID)
+	}
+	if err := s.Saga.Execute(ctx, saga, credentials.Password, notify); err != nil {
+		slog.ErrorContext(ctx, "Failed to start session", "user_id", UserID, "session_id", newSession.SessionID, "error", err)
+		if errors.Is(err, middleware.ErrUnroutable) {
+			// No dispatcher queue is bound to receive the notification; the session was rolled back
+			return nil, "", schemas.NewServiceUnavailableError(
+				"no dispatcher is available to serve the session, try again later",
+				"/sessions/start",
+			)
+		}
+		return nil, "", schemas.NewInternalError(
+			fmt.Sprintf("failed to start session: %v", err),
 			"/sessions/start",
 		)
 	}
 
-	// Action 4: Return success response with credentials
+	// Action 3: Return success response with credentials
 	return &schemas.ConnectResponse{
 		Status:        "success",
 		Message:       "Client connected successfully with new session",
@@ -133,139 +185,125 @@ func (s *ConnectionService) HandleClientConnection(ctx context.Context, UserID s
 		InputsFormat:  userData.InputsFormat,
 		OutputsFormat: userData.OutputsFormat,
 		ModelType:     userData.ModelType,
-	}, nil
+	}, newSession.SessionID, nil
 }
 
-// generateCredentials creates RabbitMQ credentials for a client
-func (s *ConnectionService) generateCredentials(UserID string) *schemas.RabbitMQCredentials {
-	return &schemas.RabbitMQCredentials{
-		Username: UserID,
-		Password: "123",
-		Host:     s.Config.GetRabbitPublicIp(),
-		Port:     s.Config.GetRabbitMQPort(),
+// reissueCredentials rotates the broker password of an active session so
+// credentials handed out earlier stop working, and returns the new ones
+func (s *ConnectionService) reissueCredentials(ctx context.Context, sessionID string, UserID string) (*schemas.RabbitMQCredentials, error) {
+	// A half-finished start has no usable topology yet; let the saga settle first
+	settling, err := s.Saga.IsSettling(ctx, sessionID)
+	if err != nil {
+		return nil, schemas.NewInternalError(
+			fmt.Sprintf("failed to query session start progress: %v", err),
+			"/sessions/start",
+		)
 	}
-}
-
-// validateConnection validates a client connection with the users-service
-// Ahora devuelve los datos del usuario si la validación es exitosa
-func (s *ConnectionService) validateConnection(token, userID string) (*schemas.UserInfo, string, error) {
-
-	if userID == "" || token == "" {
-		return nil, "", &schemas.ErrorResponse{
-			Type:     "https://connection-service.com/invalid-request",
-			Title:    "Invalid Request",
-			Status:   http.StatusBadRequest,
-			Detail:   "UserID and Token must be provided",
-			Instance: "/sessions/start",
-		}
+	if settling {
+		return nil, schemas.NewConflictError(
+			fmt.Sprintf("session %s is still being set up, retry later", sessionID),
+			"/sessions/start",
+		)
 	}
 
-	// User Validation
-	userResp, err := http.Get(fmt.Sprintf("%s/users/%s", s.Config.GetUsersServiceURL(), userID))
+	credentials, err := s.generateCredentials(UserID)
 	if err != nil {
-		return nil, "", schemas.NewBadGatewayError(
-			fmt.Sprintf("failed to connect to users-service: %v", err),
+		return nil, schemas.NewInternalError(
+			fmt.Sprintf("failed to generate credentials: %v", err),
 			"/sessions/start",
 		)
 	}
-	defer userResp.Body.Close()
 
-	if userResp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(userResp.Body)
-		slog.Warn("Failed to fetch user data", "user_id", userID, "status", userResp.StatusCode, "body", string(body))
-		return nil, "", &schemas.ErrorResponse{
-			Type:     "https://connection-service.com/external-service-error",
-			Title:    "User Not Found",
-			Status:   userResp.StatusCode,
-			Detail:   string(body),
-			Instance: "/sessions/start",
-		}
-	}
-	userInfo := schemas.UserInfo{}
-	if err := json.NewDecoder(userResp.Body).Decode(&userInfo); err != nil {
-		return nil, "", schemas.NewInternalError(
-			fmt.Sprintf("failed to decode user info response: %v", err),
+	if err := s.TopologyManager.RotateCredentialsFor(ctx, UserID, credentials.Password); err != nil {
+		slog.ErrorContext(ctx, "Failed to rotate RabbitMQ credentials", "user_id", UserID, "error", err)
+		return nil, schemas.NewInternalError(
+			fmt.Sprintf("failed to rotate credentials: %v", err),
 			"/sessions/start",
 		)
 	}
 
-	if !userInfo.IsAuthorized {
-		return nil, "", &schemas.ErrorResponse{
-			Type:     "https://connection-service.com/unauthorized",
-			Title:    "User Not Authorized",
-			Status:   http.StatusForbidden,
-			Detail:   "User is not authorized to connect",
-			Instance: "/sessions/start",
-		}
-	}
-
-	// Token Validation
-	postBody, err := json.Marshal(map[string]string{"token": token, "user_id": userID})
-	if err != nil {
-		return nil, "", schemas.NewInternalError(
-			fmt.Sprintf("failed to marshal connection validation request: %v", err),
+	if err := s.SessionRepository.UpdateSessionCredentials(ctx, sessionID, hashPassword(credentials.Password)); err != nil {
+		return nil, schemas.NewInternalError(
+			fmt.Sprintf("failed to store rotated credentials: %v", err),
 			"/sessions/start",
 		)
 	}
 
-	validateTokenResp, err := http.Post(fmt.Sprintf("%s/tokens/validate", s.Config.GetUsersServiceURL()), "application/json", bytes.NewBuffer(postBody))
-	if err != nil {
-		return nil, "", schemas.NewBadGatewayError(
-			fmt.Sprintf("failed to connect to users-service: %v", err),
+	return credentials, nil
+}
+
+// repairTopology re-creates the queues and permissions of a reconnecting client when
+// some of its queues are missing, e.g. after the broker node holding them was lost
+func (s *ConnectionService) repairTopology(ctx context.Context, UserID string, modelType string) error {
+	err := s.TopologyManager.VerifyTopologyFor(ctx, UserID)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, middleware.ErrTopologyIncomplete) {
+		return schemas.NewInternalError(
+			fmt.Sprintf("failed to verify broker topology: %v", err),
 			"/sessions/start",
 		)
 	}
-	defer validateTokenResp.Body.Close()
 
-	body, err := io.ReadAll(validateTokenResp.Body)
-	if err != nil {
-		return nil, "", schemas.NewBadGatewayError(
-			"failed to read response from users-service",
+	slog.WarnContext(ctx, "Repairing incomplete RabbitMQ topology of reconnecting client", "user_id", UserID, "error", err)
+	if err := s.TopologyManager.DeclareClientQueues(ctx, UserID, modelType); err != nil {
+		return schemas.NewInternalError(
+			fmt.Sprintf("failed to repair broker topology: %v", err),
 			"/sessions/start",
 		)
 	}
-	if validateTokenResp.StatusCode >= 500 {
-		// Handle 5xx server errors - return 502 Bad Gateway
-		slog.Warn("Users-service returned server error", "status", validateTokenResp.StatusCode, "body", string(body))
-		return nil, "", schemas.NewBadGatewayError(
-			fmt.Sprintf("users-service returned status %d: %s", validateTokenResp.StatusCode, string(body)),
+	if err := s.TopologyManager.SetClientPermissions(ctx, UserID); err != nil {
+		return schemas.NewInternalError(
+			fmt.Sprintf("failed to repair broker permissions: %v", err),
 			"/sessions/start",
 		)
 	}
+	return nil
+}
 
-	// Handle 4xx client errors - propagate them
-	if validateTokenResp.StatusCode >= 400 {
+// generateCredentials creates RabbitMQ credentials with a random password for a client
+func (s *ConnectionService) generateCredentials(UserID string) (*schemas.RabbitMQCredentials, error) {
+	return newCredentials(s.Config, UserID)
+}
 
-		var legacyError struct {
-			Detail string `json:"detail"`
-		}
-		if err := json.Unmarshal(body, &legacyError); err == nil && legacyError.Detail != "" {
-			return nil, "", &schemas.ErrorResponse{
-				Type:     "https://connection-service.com/external-service-error",
-				Title:    "Validation Failed",
-				Status:   validateTokenResp.StatusCode,
-				Detail:   legacyError.Detail,
-				Instance: "/sessions/start",
-			}
+// validateConnection validates a client connection with the users-service
+// Ahora devuelve los datos del usuario si la validación es exitosa
+func (s *ConnectionService) validateConnection(ctx context.Context, token, userID string) (*schemas.UserInfo, string, error) {
+
+	if userID == "" || token == "" {
+		return nil, "", &schemas.ErrorResponse{
+			Type:     "https://connection-service.com/invalid-request",
+			Title:    "Invalid Request",
+			Status:   http.StatusBadRequest,
+			Detail:   "UserID and Token must be provided",
+			Instance: "/sessions/start",
 		}
+	}
 
-		// Could not decode - return a generic error with the status code
-		slog.Warn("Connection validation failed", "user_id", userID, "status", validateTokenResp.StatusCode, "body", string(body))
+	// User Validation
+	userInfo, err := s.Users.GetUser(ctx, userID)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to fetch user data", "user_id", userID, "error", err)
+		return nil, "", usersclient.ToErrorResponse(err, "/sessions/start")
+	}
+
+	if !userInfo.IsAuthorized {
 		return nil, "", &schemas.ErrorResponse{
-			Type:     "https://connection-service.com/external-service-error",
-			Title:    "Validation Failed",
-			Status:   validateTokenResp.StatusCode,
-			Detail:   string(body),
+			Type:     "https://connection-service.com/unauthorized",
+			Title:    "User Not Authorized",
+			Status:   http.StatusForbidden,
+			Detail:   "User is not authorized to connect",
 			Instance: "/sessions/start",
 		}
 	}
-	tokenInfo := schemas.TokenInfo{}
-	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&tokenInfo); err != nil {
-		return nil, "", schemas.NewInternalError(
-			fmt.Sprintf("failed to decode token info response: %v", err),
-			"/sessions/start",
-		)
+
+	// Token Validation (locally or by users-service, depending on TOKEN_VALIDATION_MODE)
+	tokenInfo, err := s.Tokens.Validate(ctx, userID, token)
+	if err != nil {
+		slog.WarnContext(ctx, "Connection validation failed", "user_id", userID, "error", err)
+		return nil, "", tokens.ToErrorResponse(err, "/sessions/start")
 	}
 
-	return &userInfo, tokenInfo.TokenID, nil
+	return userInfo, tokenInfo.TokenID, nil
 }
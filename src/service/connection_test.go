@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"connection-service/src/schemas"
+	"connection-service/src/tokens"
+	"connection-service/src/usersclient"
+)
+
+func TestValidateConnection(t *testing.T) {
+	alice := "alice"
+	bob := "bob"
+
+	tests := []struct {
+		name       string
+		userID     string
+		token      string
+		usersErr   error
+		wantStatus int
+		wantToken  string
+	}{
+		{
+			name:      "authorized user with a valid token",
+			userID:    "alice",
+			token:     "alice-token",
+			wantToken: "token-1",
+		},
+		{
+			name:       "missing token",
+			userID:     "alice",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown user",
+			userID:     "mallory",
+			token:      "alice-token",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "user not authorized",
+			userID:     "carol",
+			token:      "carol-token",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "token of another user",
+			userID:     "alice",
+			token:      "bob-token",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "revoked token",
+			userID:     "alice",
+			token:      "revoked-token",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "users-service unavailable",
+			userID:     "alice",
+			token:      "alice-token",
+			usersErr:   usersclient.ErrCircuitOpen,
+			wantStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := usersclient.NewFake()
+			users.AddUser(schemas.UserInfo{ID: "alice", ModelType: "llm", IsAuthorized: true})
+			users.AddUser(schemas.UserInfo{ID: "carol", IsAuthorized: false})
+			users.AddToken("alice-token", schemas.TokenInfo{TokenID: "token-1", IsValid: true, UserID: &alice})
+			users.AddToken("bob-token", schemas.TokenInfo{TokenID: "token-2", IsValid: true, UserID: &bob})
+			users.AddToken("revoked-token", schemas.TokenInfo{TokenID: "token-3", IsValid: false, UserID: &alice})
+			users.Err = tt.usersErr
+
+			s := &ConnectionService{Users: users, Tokens: tokens.NewRemoteValidator(users)}
+			userInfo, tokenID, err := s.validateConnection(context.Background(), tt.token, tt.userID)
+			if tt.wantStatus != 0 {
+				assertStatus(t, err, tt.wantStatus)
+				return
+			}
+			if err != nil {
+				t.Fatalf("validateConnection() error = %v", err)
+			}
+			if userInfo.ID != tt.userID || tokenID != tt.wantToken {
+				t.Errorf("validateConnection() = %s, %s, want %s, %s", userInfo.ID, tokenID, tt.userID, tt.wantToken)
+			}
+		})
+	}
+}
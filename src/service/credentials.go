@@ -0,0 +1,54 @@
+package service
+
+import (
+	"connection-service/src/config"
+	"connection-service/src/schemas"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// passwordLength is the number of random bytes used for a RabbitMQ password
+const passwordLength = 32
+
+// generatePassword returns a cryptographically random, URL-safe password
+func generatePassword() (string, error) {
+	buf := make([]byte, passwordLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate password: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashPassword returns the hex-encoded SHA-256 digest persisted for a password.
+// Passwords are high-entropy random values, so a fast digest is enough to make
+// a leaked database row useless for connecting to the broker.
+func hashPassword(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+// hashToken returns the hex-encoded SHA-256 digest persisted for a connection token,
+// which lets a client prove it holds the token a session was started with
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newCredentials builds RabbitMQ credentials with a fresh random password for a client
+// The plaintext password is only ever returned to the caller, never persisted
+func newCredentials(cfg *config.GlobalConfig, UserID string) (*schemas.RabbitMQCredentials, error) {
+	password, err := generatePassword()
+	if err != nil {
+		return nil, err
+	}
+
+	return &schemas.RabbitMQCredentials{
+		Username: UserID,
+		Password: password,
+		Host:     cfg.GetRabbitPublicIp(),
+		Port:     cfg.GetRabbitMQPort(),
+	}, nil
+}
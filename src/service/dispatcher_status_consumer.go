@@ -0,0 +1,149 @@
+package service
+
+import (
+	"connection-service/src/config"
+	"connection-service/src/middleware"
+	"connection-service/src/models"
+	"connection-service/src/schemas"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+const (
+	// dispatcherStatusPrefetch bounds the unacknowledged status reports per replica
+	dispatcherStatusPrefetch = 10
+	// consumerRetryDelay is the wait before re-subscribing after the consumer channel closes
+	consumerRetryDelay = 5 * time.Second
+)
+
+// DispatcherStatusConsumer applies status reports the dispatcher publishes on
+// DISPATCHER_STATUS_EXCHANGE. All replicas share one durable queue, so each
+// report is handled by exactly one of them.
+// Only reports published by the dispatcher's broker user are applied: the dispatcher
+// must set the user_id property, which RabbitMQ checks against the publishing user
+type DispatcherStatusConsumer struct {
+	sessionService *SessionService
+	middleware     *middleware.Middleware
+	dispatcherUser string
+	consumerTag    string
+	cancel         context.CancelFunc
+	done           chan struct{}
+	stopOnce       sync.Once
+}
+
+// NewDispatcherStatusConsumer creates a consumer; call Start to begin consuming
+func NewDispatcherStatusConsumer(sessionService *SessionService, mw *middleware.Middleware, cfg *config.GlobalConfig) *DispatcherStatusConsumer {
+	return &DispatcherStatusConsumer{
+		sessionService: sessionService,
+		middleware:     mw,
+		dispatcherUser: cfg.GetMiddlewareConfig().GetDispatcherUser(),
+		consumerTag:    cfg.GetPodName() + "-dispatcher-status",
+		done:           make(chan struct{}),
+	}
+}
+
+// Start declares the status exchange and queue, then consumes in the background
+func (c *DispatcherStatusConsumer) Start() error {
+	if err := c.middleware.DeclareExchange(config.DISPATCHER_STATUS_EXCHANGE, "fanout", true); err != nil {
+		return err
+	}
+	if err := c.middleware.DeclareQueue(config.DISPATCHER_STATUS_QUEUE, true); err != nil {
+		return err
+	}
+	if err := c.middleware.BindQueue(config.DISPATCHER_STATUS_QUEUE, config.DISPATCHER_STATUS_EXCHANGE, ""); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+
+	go c.run(ctx)
+	return nil
+}
+
+// Stop cancels the consumer and waits for the in-flight report to finish
+func (c *DispatcherStatusConsumer) Stop() {
+	c.stopOnce.Do(func() {
+		if c.cancel == nil {
+			return
+		}
+		c.cancel()
+		c.middleware.CancelConsumer(c.consumerTag)
+		<-c.done
+		slog.Info("Dispatcher status consumer stopped")
+	})
+}
+
+func (c *DispatcherStatusConsumer) run(ctx context.Context) {
+	defer close(c.done)
+
+	for {
+		deliveries, err := c.middleware.Consume(config.DISPATCHER_STATUS_QUEUE, c.consumerTag, dispatcherStatusPrefetch)
+		if err != nil {
+			slog.Error("Failed to start dispatcher status consumer", "error", err, "retry_in", consumerRetryDelay)
+		} else {
+			for delivery := range deliveries {
+				c.handle(ctx, delivery)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(consumerRetryDelay):
+			slog.Info("Re-subscribing dispatcher status consumer")
+		}
+	}
+}
+
+// handle applies a single status report
+// Reports that can never succeed (not from the dispatcher, malformed, for an unknown
+// session, or stale or regressive once a later status was applied) are dropped; other
+// failures are requeued. Concurrent reports for a session are serialized by the
+// repository, so a race never causes a drop
+func (c *DispatcherStatusConsumer) handle(ctx context.Context, delivery amqp.Delivery) {
+	if delivery.UserId != c.dispatcherUser {
+		slog.Warn("Discarding dispatcher status report from another broker user", "user_id", delivery.UserId)
+		delivery.Nack(false, false)
+		return
+	}
+
+	var update schemas.DispatcherStatusUpdate
+	if err := json.Unmarshal(delivery.Body, &update); err != nil || update.SessionID == "" {
+		slog.Warn("Discarding malformed dispatcher status report", "body", string(delivery.Body), "error", err)
+		delivery.Nack(false, false)
+		return
+	}
+
+	err := c.sessionService.UpdateDispatcherStatus(ctx, update.SessionID, models.DispatcherStatus(update.Status), models.StatusChange{
+		Actor:  models.ActorDispatcher,
+		Reason: update.Reason,
+	})
+	if err == nil {
+		delivery.Ack(false)
+		return
+	}
+
+	var apiError *schemas.ErrorResponse
+	if errors.As(err, &apiError) && apiError.Status < 500 {
+		slog.WarnContext(ctx, "Dropping dispatcher status report",
+			"session_id", update.SessionID,
+			"status", update.Status,
+			"http_status", apiError.Status,
+			"reason", apiError.Detail)
+		delivery.Nack(false, false)
+		return
+	}
+
+	slog.Error("Failed to apply dispatcher status report, requeueing",
+		"session_id", update.SessionID,
+		"status", update.Status,
+		"error", err)
+	delivery.Nack(false, true)
+}
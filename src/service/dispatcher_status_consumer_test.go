@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/streadway/amqp"
+)
+
+// recordingAcknowledger records how a delivery was settled
+type recordingAcknowledger struct {
+	acked    bool
+	nacked   bool
+	requeued bool
+}
+
+func (a *recordingAcknowledger) Ack(tag uint64, multiple bool) error {
+	a.acked = true
+	return nil
+}
+
+func (a *recordingAcknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	a.nacked = true
+	a.requeued = requeue
+	return nil
+}
+
+func (a *recordingAcknowledger) Reject(tag uint64, requeue bool) error {
+	return a.Nack(tag, false, requeue)
+}
+
+func TestDispatcherStatusConsumerDropsReports(t *testing.T) {
+	report := []byte(`{"session_id": "s-1", "status": "FAILED"}`)
+
+	tests := []struct {
+		name   string
+		userID string
+		body   []byte
+	}{
+		{"no user_id property", "", report},
+		{"published by a client", "alice", report},
+		{"user_id differing in case", "Dispatcher", report},
+		{"malformed report from the dispatcher", "dispatcher", []byte(`{`)},
+		{"report without a session from the dispatcher", "dispatcher", []byte(`{"status": "FAILED"}`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// No session service: a dropped report must never reach it
+			c := &DispatcherStatusConsumer{dispatcherUser: "dispatcher"}
+			acknowledger := &recordingAcknowledger{}
+			c.handle(context.Background(), amqp.Delivery{Acknowledger: acknowledger, UserId: tt.userID, Body: tt.body})
+
+			if acknowledger.acked || !acknowledger.nacked || acknowledger.requeued {
+				t.Errorf("report settled as %+v, want dropped", *acknowledger)
+			}
+		})
+	}
+}
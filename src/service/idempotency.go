@@ -0,0 +1,233 @@
+package service
+
+import (
+	"connection-service/src/models"
+	"connection-service/src/schemas"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// maxIdempotencyKeyLength matches the idempotency_key column
+const maxIdempotencyKeyLength = 255
+
+// errCredentialsRotated reports a stored response whose credentials have since been
+// replaced by a rotation, so replaying it would hand out a dead password
+var errCredentialsRotated = errors.New("stored credentials were rotated")
+
+// connectIdempotent runs connect at most once per idempotency key and replays its
+// outcome to retries. The key is looked up before anything else, so a retry neither
+// spends another use of the token nor rotates the credentials of the first response.
+// Only successful responses are stored, sealed with the connection token; failures
+// release the key so a retry runs the request again, and so does a stored response
+// whose credentials were rotated since
+func (s *ConnectionService) connectIdempotent(ctx context.Context, UserID, token, key string) (*schemas.ConnectResponse, error) {
+	if len(key) > maxIdempotencyKeyLength {
+		return nil, schemas.NewBadRequestError(
+			fmt.Sprintf("Idempotency-Key must be at most %d characters", maxIdempotencyKeyLength),
+			"/sessions/start",
+		)
+	}
+
+	requestHash := requestFingerprint(UserID, token)
+	record, reserved, err := s.reserveKey(ctx, UserID, key, requestHash)
+	if err != nil {
+		return nil, err
+	}
+	if !reserved {
+		response, err := s.replay(ctx, record, token, requestHash)
+		if !errors.Is(err, errCredentialsRotated) {
+			return response, err
+		}
+
+		if err := s.Idempotency.Invalidate(ctx, UserID, key); err != nil {
+			return nil, schemas.NewInternalError(
+				fmt.Sprintf("failed to invalidate idempotency key: %v", err),
+				"/sessions/start",
+			)
+		}
+		record, reserved, err = s.reserveKey(ctx, UserID, key, requestHash)
+		if err != nil {
+			return nil, err
+		}
+		if !reserved {
+			// A concurrent retry took the key over first
+			return nil, schemas.NewConflictError(
+				"a request with this Idempotency-Key is still being processed",
+				"/sessions/start",
+			)
+		}
+	}
+
+	// Only the first request with the key is validated: a retry is known to carry the
+	// same token because its fingerprint matches
+	userData, tokenID, err := s.validateConnection(ctx, token, UserID)
+	if err != nil {
+		s.recordOutcome(context.WithoutCancel(ctx), record, token, nil, "", err)
+		return nil, err
+	}
+
+	response, sessionID, err := s.connect(ctx, UserID, token, userData, tokenID)
+
+	// Store the outcome even if the client went away, since that is when it retries
+	s.recordOutcome(context.WithoutCancel(ctx), record, token, response, sessionID, err)
+	return response, err
+}
+
+// reserveKey reserves an idempotency key for the lease configured for a request
+func (s *ConnectionService) reserveKey(ctx context.Context, UserID, key, requestHash string) (*models.IdempotencyRecord, bool, error) {
+	record, reserved, err := s.Idempotency.Reserve(ctx, UserID, key, requestHash, s.Config.GetIdempotencyKeyTTL(), s.Config.GetIdempotencyKeyLease())
+	if err != nil {
+		return nil, false, schemas.NewInternalError(
+			fmt.Sprintf("failed to reserve idempotency key: %v", err),
+			"/sessions/start",
+		)
+	}
+	return record, reserved, nil
+}
+
+// replay answers a retry with the response of the first request using the same key,
+// credentials included, as long as the session it started is still in progress. It
+// returns errCredentialsRotated if those credentials are no longer the session's
+func (s *ConnectionService) replay(ctx context.Context, record *models.IdempotencyRecord, token, requestHash string) (*schemas.ConnectResponse, error) {
+	if record.RequestHash != requestHash {
+		return nil, schemas.NewUnprocessableEntityError(
+			"Idempotency-Key was already used for a different request",
+			"/sessions/start",
+		)
+	}
+	if record.IsPending() {
+		return nil, schemas.NewConflictError(
+			"a request with this Idempotency-Key is still being processed",
+			"/sessions/start",
+		)
+	}
+
+	body, err := openResponse(token, record.Response)
+	if err != nil {
+		return nil, schemas.NewInternalError(
+			fmt.Sprintf("failed to decrypt stored response: %v", err),
+			"/sessions/start",
+		)
+	}
+	var response schemas.ConnectResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, schemas.NewInternalError(
+			fmt.Sprintf("failed to decode stored response: %v", err),
+			"/sessions/start",
+		)
+	}
+
+	status, credentialsHash, err := s.SessionRepository.GetSessionCredentials(ctx, record.SessionID)
+	if err != nil && !errors.Is(err, models.ErrSessionNotFound) {
+		return nil, schemas.NewInternalError(
+			fmt.Sprintf("failed to get session credentials: %v", err),
+			"/sessions/start",
+		)
+	}
+	if err != nil || status != models.StatusInProgress {
+		return nil, schemas.SessionNotInProgressError(
+			fmt.Sprintf("session %s started by this Idempotency-Key is no longer in progress", record.SessionID),
+			"/sessions/start",
+		)
+	}
+	if credentialsRotated(&response, credentialsHash) {
+		slog.InfoContext(ctx, "Skipped replay of rotated credentials",
+			"user_id", record.UserID,
+			"session_id", record.SessionID)
+		return nil, errCredentialsRotated
+	}
+
+	slog.InfoContext(ctx, "Replayed idempotent session start",
+		"user_id", record.UserID,
+		"session_id", record.SessionID)
+
+	return &response, nil
+}
+
+// credentialsRotated reports whether the credentials of a stored response differ from
+// the ones last issued to its session. Sessions without a stored digest are not checked
+func credentialsRotated(response *schemas.ConnectResponse, credentialsHash string) bool {
+	if response.Credentials == nil || credentialsHash == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(hashPassword(response.Credentials.Password)), []byte(credentialsHash)) != 1
+}
+
+// recordOutcome stores a successful response for replay, sealed with the connection
+// token, or releases the key
+func (s *ConnectionService) recordOutcome(ctx context.Context, record *models.IdempotencyRecord, token string, response *schemas.ConnectResponse, sessionID string, connectErr error) {
+	if connectErr == nil {
+		body, err := json.Marshal(response)
+		if err == nil {
+			body, err = sealResponse(token, body)
+		}
+		if err == nil {
+			err = s.Idempotency.Complete(ctx, record.UserID, record.Key, http.StatusOK, body, sessionID)
+		}
+		if err == nil {
+			return
+		}
+		slog.ErrorContext(ctx, "Failed to store idempotent response", "user_id", record.UserID, "error", err)
+	}
+
+	if err := s.Idempotency.Release(ctx, record.UserID, record.Key); err != nil {
+		slog.ErrorContext(ctx, "Failed to release idempotency key", "user_id", record.UserID, "error", err)
+	}
+}
+
+// sealResponse encrypts a stored response with AES-GCM under a key derived from the
+// connection token, so the credentials it holds can only be read back by a retry
+// presenting the same token
+func sealResponse(token string, plaintext []byte) ([]byte, error) {
+	aead, err := responseCipher(token)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// openResponse decrypts a response sealed by sealResponse
+func openResponse(token string, sealed []byte) ([]byte, error) {
+	aead, err := responseCipher(token)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("stored response is too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
+
+// responseCipher derives the response key from the token; the label keeps it
+// unrelated to the request fingerprint, which is stored in the clear
+func responseCipher(token string) (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write([]byte("connection-service idempotent response"))
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create response cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// requestFingerprint identifies the request an idempotency key was first used for
+func requestFingerprint(UserID, token string) string {
+	digest := sha256.Sum256([]byte(UserID + "\x00" + token))
+	return hex.EncodeToString(digest[:])
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"connection-service/src/models"
+	"connection-service/src/schemas"
+)
+
+func TestSealResponse(t *testing.T) {
+	plaintext := []byte(`{"status":"success","credentials":{"password":"secret"}}`)
+	sealed, err := sealResponse("token-a", plaintext)
+	if err != nil {
+		t.Fatalf("sealResponse() error = %v", err)
+	}
+	if bytes.Contains(sealed, []byte("secret")) {
+		t.Fatal("sealed response contains the password in the clear")
+	}
+
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)-1] ^= 0xff
+
+	tests := []struct {
+		name    string
+		token   string
+		sealed  []byte
+		wantErr bool
+	}{
+		{"same token", "token-a", sealed, false},
+		{"another token", "token-b", sealed, true},
+		{"tampered", "token-a", tampered, true},
+		{"truncated", "token-a", sealed[:4], true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opened, err := openResponse(tt.token, tt.sealed)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("openResponse() succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("openResponse() error = %v", err)
+			}
+			if !bytes.Equal(opened, plaintext) {
+				t.Errorf("openResponse() = %s, want %s", opened, plaintext)
+			}
+		})
+	}
+}
+
+func TestSealResponseUsesFreshNonces(t *testing.T) {
+	first, err := sealResponse("token-a", []byte("response"))
+	if err != nil {
+		t.Fatalf("sealResponse() error = %v", err)
+	}
+	second, err := sealResponse("token-a", []byte("response"))
+	if err != nil {
+		t.Fatalf("sealResponse() error = %v", err)
+	}
+	if bytes.Equal(first, second) {
+		t.Error("sealing the same response twice gave the same ciphertext")
+	}
+}
+
+func TestRequestFingerprint(t *testing.T) {
+	base := requestFingerprint("alice", "token")
+
+	tests := []struct {
+		name   string
+		userID string
+		token  string
+		same   bool
+	}{
+		{"same request", "alice", "token", true},
+		{"another token", "alice", "other-token", false},
+		{"another user", "bob", "token", false},
+		{"shifted separator", "alicet", "oken", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := requestFingerprint(tt.userID, tt.token) == base; got != tt.same {
+				t.Errorf("fingerprint matches = %v, want %v", got, tt.same)
+			}
+		})
+	}
+}
+
+// TestReplayRejects covers the retries answered without looking up the session
+func TestReplayRejects(t *testing.T) {
+	sealed, err := sealResponse("token", []byte(`{"status":"success"}`))
+	if err != nil {
+		t.Fatalf("sealResponse() error = %v", err)
+	}
+	requestHash := requestFingerprint("alice", "token")
+
+	tests := []struct {
+		name       string
+		record     *models.IdempotencyRecord
+		wantStatus int
+	}{
+		{
+			name:       "different request",
+			record:     &models.IdempotencyRecord{UserID: "alice", RequestHash: requestFingerprint("alice", "other-token"), StatusCode: http.StatusOK, Response: sealed},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "first request still running",
+			record:     &models.IdempotencyRecord{UserID: "alice", RequestHash: requestHash},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "response that does not open",
+			record:     &models.IdempotencyRecord{UserID: "alice", RequestHash: requestHash, StatusCode: http.StatusOK, Response: []byte("garbage")},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &ConnectionService{}
+			_, err := s.replay(context.Background(), tt.record, "token", requestHash)
+			assertStatus(t, err, tt.wantStatus)
+		})
+	}
+}
+
+func TestCredentialsRotated(t *testing.T) {
+	response := &schemas.ConnectResponse{Credentials: &schemas.RabbitMQCredentials{Password: "first"}}
+
+	tests := []struct {
+		name            string
+		response        *schemas.ConnectResponse
+		credentialsHash string
+		want            bool
+	}{
+		{"same password", response, hashPassword("first"), false},
+		{"rotated password", response, hashPassword("second"), true},
+		{"session without digest", response, "", false},
+		{"response without credentials", &schemas.ConnectResponse{}, hashPassword("first"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := credentialsRotated(tt.response, tt.credentialsHash); got != tt.want {
+				t.Errorf("credentialsRotated() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConnectIdempotentRejectsLongKey(t *testing.T) {
+	s := &ConnectionService{}
+	key := strings.Repeat("k", maxIdempotencyKeyLength+1)
+
+	_, err := s.connectIdempotent(context.Background(), "alice", "token", key)
+	assertStatus(t, err, http.StatusBadRequest)
+}
+
+// assertStatus fails unless err is an API error with the given status
+func assertStatus(t *testing.T, err error, want int) {
+	t.Helper()
+	var apiError *schemas.ErrorResponse
+	if !errors.As(err, &apiError) {
+		t.Fatalf("error = %v, want an API error with status %d", err, want)
+	}
+	if apiError.Status != want {
+		t.Errorf("status = %d (%s), want %d", apiError.Status, apiError.Detail, want)
+	}
+}
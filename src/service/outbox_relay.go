@@ -0,0 +1,171 @@
+package service
+
+import (
+	"connection-service/src/config"
+	"connection-service/src/middleware"
+	"connection-service/src/models"
+	"connection-service/src/repository"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// OutboxRelay publishes outbox messages to RabbitMQ with publisher confirms.
+// Messages are normally published right away by the request that created them
+// (PublishNow); the background loop delivers released messages left behind,
+// e.g. by a saga resumed after a crash, giving at-least-once delivery.
+// Messages the broker cannot route to any queue are not retried; the sessions they
+// announce are rolled back through onUndeliverable.
+type OutboxRelay struct {
+	repo            *repository.OutboxRepository
+	middleware      *middleware.Middleware
+	config          *config.OutboxConfig
+	onUndeliverable UndeliverableFunc
+	cancel          context.CancelFunc
+	done            chan struct{}
+	stopOnce        sync.Once
+}
+
+// UndeliverableFunc rolls back the session of a notification that could not be routed
+type UndeliverableFunc func(ctx context.Context, sessionID string, cause string) error
+
+// NewOutboxRelay creates a relay; call Start to run the background loop
+func NewOutboxRelay(repo *repository.OutboxRepository, mw *middleware.Middleware, cfg *config.OutboxConfig) *OutboxRelay {
+	return &OutboxRelay{
+		repo:       repo,
+		middleware: mw,
+		config:     cfg,
+		done:       make(chan struct{}),
+	}
+}
+
+// NewMessage builds an outbox message that is held until it is published with
+// PublishNow or released, so the relay never sends it ahead of its saga
+func (r *OutboxRelay) NewMessage(exchange, routingKey string, payload []byte) *models.OutboxMessage {
+	return &models.OutboxMessage{
+		Exchange:   exchange,
+		RoutingKey: routingKey,
+		Payload:    payload,
+	}
+}
+
+// PublishNow publishes a single outbox message immediately
+// It is a no-op if the message was already sent, and returns ErrOutboxMessageInFlight
+// if another relay is sending it
+func (r *OutboxRelay) PublishNow(ctx context.Context, messageID int64) error {
+	_, err := r.repo.PublishMessage(ctx, messageID, r.config.GetLeaseDuration(), r.publish)
+	return err
+}
+
+// OnUndeliverable sets how the background loop rolls back the sessions of undeliverable
+// messages; without it they are kept as failed. Call it before Start
+func (r *OutboxRelay) OnUndeliverable(fn UndeliverableFunc) {
+	r.onUndeliverable = fn
+}
+
+// Start launches the relay loop in a background goroutine
+func (r *OutboxRelay) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+
+	slog.Info("Starting outbox relay", "interval", r.config.GetRelayInterval())
+
+	go r.run(ctx)
+}
+
+// Stop cancels the relay loop and waits for the current batch to finish
+func (r *OutboxRelay) Stop() {
+	r.stopOnce.Do(func() {
+		if r.cancel == nil {
+			return
+		}
+		r.cancel()
+		<-r.done
+		slog.Info("Outbox relay stopped")
+	})
+}
+
+func (r *OutboxRelay) run(ctx context.Context) {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.config.GetRelayInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sent, err := r.repo.PublishPending(ctx, r.config.GetBatchSize(), r.config.GetLeaseDuration(), r.publishBatch)
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.Error("Outbox relay batch failed", "error", err)
+				}
+				continue
+			}
+			if sent > 0 {
+				slog.Info("Outbox relay published pending messages", "count", sent)
+			}
+			r.rollBackUndeliverable(ctx)
+		}
+	}
+}
+
+// rollBackUndeliverable rolls back the sessions of released messages that failed as
+// unroutable. A session whose rollback fails is retried once the lease of its message expires
+func (r *OutboxRelay) rollBackUndeliverable(ctx context.Context) {
+	if r.onUndeliverable == nil {
+		return
+	}
+
+	messages, err := r.repo.ClaimUndeliverable(ctx, r.config.GetBatchSize(), r.config.GetLeaseDuration())
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Error("Failed to claim undeliverable outbox messages", "error", err)
+		}
+		return
+	}
+
+	for _, message := range messages {
+		cause := fmt.Sprintf("notification %d could not be routed to any dispatcher", message.MessageID)
+		if err := r.onUndeliverable(ctx, message.SessionID, cause); err != nil {
+			slog.Error("Failed to roll back session of undeliverable notification",
+				"message_id", message.MessageID,
+				"session_id", message.SessionID,
+				"error", err)
+		}
+	}
+}
+
+func (r *OutboxRelay) publish(ctx context.Context, message *models.OutboxMessage) error {
+	return undeliverable(r.middleware.PublishWithRouting(ctx, message.RoutingKey, message.Payload, message.Exchange))
+}
+
+// publishBatch publishes the messages of a relay batch with a single round of confirmations
+func (r *OutboxRelay) publishBatch(ctx context.Context, messages []models.OutboxMessage) []error {
+	batch := make([]middleware.Message, len(messages))
+	for i, message := range messages {
+		batch[i] = middleware.Message{
+			Exchange:   message.Exchange,
+			RoutingKey: message.RoutingKey,
+			Body:       message.Payload,
+		}
+	}
+	errs := r.middleware.PublishBatch(ctx, batch)
+	for i := range errs {
+		errs[i] = undeliverable(errs[i])
+	}
+	return errs
+}
+
+// undeliverable marks an unroutable publish as final for the outbox, so the session
+// is rolled back rather than waiting for a dispatcher queue to be bound
+func undeliverable(err error) error {
+	if errors.Is(err, middleware.ErrUnroutable) {
+		return fmt.Errorf("%w: %w", models.ErrOutboxMessageUndeliverable, err)
+	}
+	return err
+}
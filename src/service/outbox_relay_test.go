@@ -0,0 +1,35 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"connection-service/src/middleware"
+	"connection-service/src/models"
+)
+
+func TestUndeliverable(t *testing.T) {
+	tests := []struct {
+		name              string
+		err               error
+		wantUndeliverable bool
+	}{
+		{"confirmed", nil, false},
+		{"unroutable", fmt.Errorf("%w: NO_ROUTE", middleware.ErrUnroutable), true},
+		{"nacked", errors.New("message was nacked by the broker"), false},
+		{"not connected", middleware.ErrNotConnected, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := undeliverable(tt.err)
+			if got := errors.Is(err, models.ErrOutboxMessageUndeliverable); got != tt.wantUndeliverable {
+				t.Errorf("undeliverable(%v) is ErrOutboxMessageUndeliverable = %v, want %v", tt.err, got, tt.wantUndeliverable)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("undeliverable(%v) = %v, lost the original error", tt.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"connection-service/src/config"
+	"connection-service/src/models"
+	"connection-service/src/repository"
+	"connection-service/src/schemas"
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// reaperLockName identifies the advisory lock shared by all replicas
+// Only the replica holding it sweeps, so sessions are not timed out twice
+const reaperLockName = "connection-service:session-reaper"
+
+// SessionReaper periodically moves stale IN_PROGRESS sessions to TIMEOUT
+// and purges expired idempotency keys
+type SessionReaper struct {
+	sessionService  *SessionService
+	repo            *repository.SessionRepository
+	idempotencyRepo *repository.IdempotencyRepository
+	config          *config.ReaperConfig
+	cancel          context.CancelFunc
+	done            chan struct{}
+	stopOnce        sync.Once
+}
+
+// NewSessionReaper creates a reaper; call Start to begin sweeping
+func NewSessionReaper(sessionService *SessionService, repo *repository.SessionRepository, idempotencyRepo *repository.IdempotencyRepository, cfg *config.ReaperConfig) *SessionReaper {
+	return &SessionReaper{
+		sessionService:  sessionService,
+		repo:            repo,
+		idempotencyRepo: idempotencyRepo,
+		config:          cfg,
+		done:            make(chan struct{}),
+	}
+}
+
+// Start launches the sweep loop in a background goroutine
+func (r *SessionReaper) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+
+	slog.Info("Starting session reaper",
+		"interval", r.config.GetInterval(),
+		"max_age", r.config.GetMaxAge(),
+		"idle_timeout", r.config.GetIdleTimeout())
+
+	go r.run(ctx)
+}
+
+// Stop cancels any in-flight sweep and waits for the loop to exit
+func (r *SessionReaper) Stop() {
+	r.stopOnce.Do(func() {
+		if r.cancel == nil {
+			return
+		}
+		r.cancel()
+		<-r.done
+		slog.Info("Session reaper stopped")
+	})
+}
+
+func (r *SessionReaper) run(ctx context.Context) {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.config.GetInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.sweep(ctx)
+			r.purgeIdempotencyKeys(ctx)
+		}
+	}
+}
+
+// sweep times out one batch of stale sessions if this replica wins the lock
+func (r *SessionReaper) sweep(ctx context.Context) {
+	createdBefore, idleBefore := reaperCutoffs(time.Now(), r.config.GetMaxAge(), r.config.GetIdleTimeout())
+	if !createdBefore.Valid && !idleBefore.Valid {
+		return // Both checks disabled
+	}
+
+	acquired, err := r.repo.TryWithLock(ctx, reaperLockName, func(ctx context.Context) error {
+		sessionIDs, err := r.repo.ListStaleSessionIDs(ctx, createdBefore, idleBefore, r.config.GetBatchSize())
+		if err != nil {
+			return err
+		}
+
+		for _, sessionID := range sessionIDs {
+			r.timeoutSession(ctx, sessionID)
+		}
+		return nil
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Error("Session reaper sweep failed", "error", err)
+		}
+		return
+	}
+	if !acquired {
+		slog.Debug("Session reaper lock held by another replica, skipping sweep")
+	}
+}
+
+// purgeIdempotencyKeys removes idempotency keys past their TTL
+// Deleting is idempotent, so no lock is needed between replicas
+func (r *SessionReaper) purgeIdempotencyKeys(ctx context.Context) {
+	if _, err := r.idempotencyRepo.PurgeExpired(ctx); err != nil && ctx.Err() == nil {
+		slog.Error("Failed to purge expired idempotency keys", "error", err)
+	}
+}
+
+func (r *SessionReaper) timeoutSession(ctx context.Context, sessionID string) {
+	err := r.sessionService.SetSessionStatusToTimeout(ctx, sessionID, models.StatusChange{
+		Actor:  models.ActorReaper,
+		Reason: "session exceeded the configured max age or idle timeout",
+	})
+	if err == nil {
+		slog.Info("Session timed out by reaper", "session_id", sessionID)
+		return
+	}
+
+	// The session may have been completed between listing and updating
+	var apiError *schemas.ErrorResponse
+	if errors.As(err, &apiError) && apiError.Status < 500 {
+		slog.Debug("Skipping session already handled", "session_id", sessionID, "reason", apiError.Detail)
+		return
+	}
+	slog.Error("Failed to time out stale session", "session_id", sessionID, "error", err)
+}
+
+// reaperCutoffs converts the configured windows into timestamps; a zero window yields a NULL bound
+func reaperCutoffs(now time.Time, maxAge, idleTimeout time.Duration) (createdBefore, idleBefore sql.NullTime) {
+	if maxAge > 0 {
+		createdBefore = sql.NullTime{Time: now.Add(-maxAge), Valid: true}
+	}
+	if idleTimeout > 0 {
+		idleBefore = sql.NullTime{Time: now.Add(-idleTimeout), Valid: true}
+	}
+	return createdBefore, idleBefore
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReaperCutoffs(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		maxAge        time.Duration
+		idleTimeout   time.Duration
+		createdBefore time.Time // Zero when the bound must be NULL
+		idleBefore    time.Time
+	}{
+		{"both disabled", 0, 0, time.Time{}, time.Time{}},
+		{"idle timeout only", 0, time.Hour, time.Time{}, now.Add(-time.Hour)},
+		{"max age only", 24 * time.Hour, 0, now.Add(-24 * time.Hour), time.Time{}},
+		{"both enabled", 24 * time.Hour, 30 * time.Minute, now.Add(-24 * time.Hour), now.Add(-30 * time.Minute)},
+		{"negative windows disable the checks", -time.Hour, -time.Minute, time.Time{}, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			createdBefore, idleBefore := reaperCutoffs(now, tt.maxAge, tt.idleTimeout)
+
+			if createdBefore.Valid != !tt.createdBefore.IsZero() || !createdBefore.Time.Equal(tt.createdBefore) {
+				t.Errorf("createdBefore = %+v, want %v", createdBefore, tt.createdBefore)
+			}
+			if idleBefore.Valid != !tt.idleBefore.IsZero() || !idleBefore.Time.Equal(tt.idleBefore) {
+				t.Errorf("idleBefore = %+v, want %v", idleBefore, tt.idleBefore)
+			}
+		})
+	}
+}
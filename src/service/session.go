@@ -6,253 +6,442 @@ import (
 	"connection-service/src/models"
 	"connection-service/src/repository"
 	"connection-service/src/schemas"
+	"connection-service/src/usersclient"
 	"context"
-	"encoding/json"
+	"crypto/subtle"
+	"encoding/base64"
 	"errors"
 	"fmt"
-	"io"
-	"net/http"
+	"log/slog"
 	"strings"
+	"time"
+)
+
+const (
+	// defaultSessionPageSize is used when GET /sessions has no limit
+	defaultSessionPageSize = 50
+	// maxSessionPageSize caps the limit accepted by GET /sessions
+	maxSessionPageSize = 200
 )
 
 type SessionService struct {
 	repo   *repository.SessionRepository
 	tm     *middleware.RabbitMQTopologyManager
+	users  usersclient.Client
 	config *config.GlobalConfig
 }
 
-func NewSessionService(repo *repository.SessionRepository, tm *middleware.RabbitMQTopologyManager, cfg *config.GlobalConfig) *SessionService {
+func NewSessionService(repo *repository.SessionRepository, tm *middleware.RabbitMQTopologyManager, users usersclient.Client, cfg *config.GlobalConfig) *SessionService {
 	return &SessionService{
 		repo:   repo,
 		tm:     tm,
+		users:  users,
 		config: cfg,
 	}
 }
 
-// SetSessionStatusToCompleted sets the session status to COMPLETED and revokes user authorization
-func (s *SessionService) SetSessionStatusToCompleted(ctx context.Context, sessionID string) error {
-	// Check if session exists and is in progress
+// GetSession returns the session with the given ID
+func (s *SessionService) GetSession(ctx context.Context, sessionID string) (*models.Session, error) {
 	session, err := s.repo.GetSessionByID(ctx, sessionID)
 	if err != nil {
 		if errors.Is(err, models.ErrSessionNotFound) {
-			return schemas.NewNotFoundError(
+			return nil, schemas.NewNotFoundError(
 				fmt.Sprintf("session with ID %s not found", sessionID),
-				"/sessions/"+sessionID+"/status/completed",
+				"/sessions/"+sessionID,
 			)
 		}
-		return schemas.NewInternalError(
-			fmt.Sprintf("failed to get session status: %v", err),
-			"/sessions/"+sessionID+"/status/completed",
+		return nil, schemas.NewInternalError(
+			fmt.Sprintf("failed to get session: %v", err),
+			"/sessions/"+sessionID,
 		)
 	}
+	return session, nil
+}
 
-	// Check if session is in progress
-	if session.SessionStatus != models.StatusInProgress {
-		return schemas.SessionNotInProgressError(
-			"cannot update status: session is not IN_PROGRESS",
-			"/sessions/"+sessionID+"/status/completed",
+// GetSessionEvents returns the status change history of a session, oldest first
+func (s *SessionService) GetSessionEvents(ctx context.Context, sessionID string) (*schemas.SessionEventsResponse, error) {
+	instance := "/sessions/" + sessionID + "/events"
+
+	if _, err := s.GetSession(ctx, sessionID); err != nil {
+		var apiError *schemas.ErrorResponse
+		if errors.As(err, &apiError) {
+			apiError.Instance = instance
+		}
+		return nil, err
+	}
+
+	events, err := s.repo.ListSessionEvents(ctx, sessionID)
+	if err != nil {
+		return nil, schemas.NewInternalError(
+			fmt.Sprintf("failed to list session events: %v", err),
+			instance,
+		)
+	}
+
+	return &schemas.SessionEventsResponse{
+		SessionID: sessionID,
+		Events:    events,
+	}, nil
+}
+
+// ListSessions returns a page of sessions matching the query filters, newest first
+func (s *SessionService) ListSessions(ctx context.Context, query schemas.ListSessionsQuery) (*schemas.ListSessionsResponse, error) {
+	const instance = "/sessions"
+
+	filter := repository.SessionFilter{
+		UserID:           query.UserID,
+		Status:           models.SessionStatus(query.Status),
+		DispatcherStatus: models.DispatcherStatus(query.DispatcherStatus),
+		Limit:            query.Limit,
+	}
+
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return nil, schemas.NewBadRequestError(
+			fmt.Sprintf("invalid status %q", query.Status),
+			instance,
+		)
+	}
+
+	if filter.DispatcherStatus != "" && !filter.DispatcherStatus.IsValid() {
+		return nil, schemas.NewBadRequestError(
+			fmt.Sprintf("invalid dispatcher_status %q", query.DispatcherStatus),
+			instance,
+		)
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = defaultSessionPageSize
+	}
+	if filter.Limit < 0 || filter.Limit > maxSessionPageSize {
+		return nil, schemas.NewBadRequestError(
+			fmt.Sprintf("limit must be between 1 and %d", maxSessionPageSize),
+			instance,
+		)
+	}
+
+	var err error
+	if filter.CreatedAfter, err = parseTimeParam("created_after", query.CreatedAfter); err != nil {
+		return nil, schemas.NewBadRequestError(err.Error(), instance)
+	}
+	if filter.CreatedBefore, err = parseTimeParam("created_before", query.CreatedBefore); err != nil {
+		return nil, schemas.NewBadRequestError(err.Error(), instance)
+	}
+
+	if query.Cursor != "" {
+		createdAt, sessionID, err := decodeSessionCursor(query.Cursor)
+		if err != nil {
+			return nil, schemas.NewBadRequestError("invalid cursor", instance)
+		}
+		filter.AfterCreatedAt = &createdAt
+		filter.AfterSessionID = sessionID
+	}
+
+	// Fetch one extra row to know whether another page exists
+	pageSize := filter.Limit
+	filter.Limit++
+
+	sessions, err := s.repo.ListSessions(ctx, filter)
+	if err != nil {
+		return nil, schemas.NewInternalError(
+			fmt.Sprintf("failed to list sessions: %v", err),
+			instance,
 		)
 	}
 
-	// Update session status to COMPLETED and set completed_at
-	err = s.repo.SetSessionStatusToCompleted(ctx, sessionID)
+	response := &schemas.ListSessionsResponse{Sessions: sessions}
+	if len(sessions) > pageSize {
+		response.Sessions = sessions[:pageSize]
+		last := response.Sessions[pageSize-1]
+		response.NextCursor = encodeSessionCursor(last.CreatedAt, last.SessionID)
+	}
+
+	return response, nil
+}
+
+// SetSessionStatusToCompleted sets the session status to COMPLETED and revokes user authorization
+func (s *SessionService) SetSessionStatusToCompleted(ctx context.Context, sessionID string, change models.StatusChange) error {
+	instance := "/sessions/" + sessionID + "/status/completed"
+
+	session, err := s.transitionSession(ctx, sessionID, models.StatusCompleted, change, instance)
+	if err != nil {
+		return err
+	}
+
+	return s.releaseSession(ctx, session, instance)
+}
+
+// DisconnectClient lets a client end its own session early. The client proves it owns
+// the session with its user ID and the token the session was started with; the session
+// is CANCELLED and torn down like a completed one, freeing its broker resources
+func (s *SessionService) DisconnectClient(ctx context.Context, sessionID, userID, token, reason string) error {
+	instance := "/sessions/" + sessionID + "/disconnect"
+
+	if err := s.authenticateClient(ctx, sessionID, userID, token, instance); err != nil {
+		return err
+	}
+
+	if reason == "" {
+		reason = "disconnected by client"
+	}
+	session, err := s.transitionSession(ctx, sessionID, models.StatusCancelled, models.StatusChange{
+		Actor:  models.ActorClient,
+		Reason: reason,
+	}, instance)
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "Client disconnected its session", "session_id", sessionID, "user_id", session.UserID)
+
+	return s.releaseSession(ctx, session, instance)
+}
+
+// authenticateClient checks that userID and token are those the session was started with
+// Mismatches return the same 401 so callers cannot probe which part was wrong
+func (s *SessionService) authenticateClient(ctx context.Context, sessionID, userID, token, instance string) error {
+	ownerID, tokenHash, err := s.repo.GetSessionOwner(ctx, sessionID)
 	if err != nil {
 		if errors.Is(err, models.ErrSessionNotFound) {
 			return schemas.NewNotFoundError(
 				fmt.Sprintf("session with ID %s not found", sessionID),
-				"/sessions/"+sessionID+"/status/completed",
+				instance,
 			)
 		}
 		return schemas.NewInternalError(
-			fmt.Sprintf("failed to update session status to COMPLETED: %v", err),
-			"/sessions/"+sessionID+"/status/completed",
+			fmt.Sprintf("failed to get session: %v", err),
+			instance,
 		)
 	}
 
+	if tokenHash == "" {
+		return schemas.NewUnauthorizedError(
+			"session was started before client disconnects were supported and can only be ended by the service",
+			instance,
+		)
+	}
+
+	tokenMatches := subtle.ConstantTimeCompare([]byte(hashToken(token)), []byte(tokenHash)) == 1
+	if !tokenMatches || ownerID != userID {
+		return schemas.NewUnauthorizedError("user ID or token does not match the session", instance)
+	}
+	return nil
+}
+
+// releaseSession frees what an ended session holds: the user's authorization and
+// connection token are revoked in users-service and the broker topology is deleted
+func (s *SessionService) releaseSession(ctx context.Context, session *models.Session, instance string) error {
 	// Revoke user authorization
-	if err := s.RevokeAuthorization(session.UserID, sessionID); err != nil {
+	if err := s.RevokeAuthorization(ctx, session.UserID, instance); err != nil {
 		return err
 	}
 
-	if err := s.RevokeToken(session.UserID, session.TokenID); err != nil {
+	if err := s.RevokeToken(ctx, session.UserID, session.TokenID); err != nil {
 		return err
 	}
 
-	s.tm.DeleteTopologyFor(session.UserID)
+	s.tm.DeleteTopologyFor(ctx, session.UserID)
 
 	return nil
 }
 
 // SetSessionStatusToTimeout sets the session status to TIMEOUT
-func (s *SessionService) SetSessionStatusToTimeout(ctx context.Context, sessionID string) error {
-	// Check if session exists and is in progress
+func (s *SessionService) SetSessionStatusToTimeout(ctx context.Context, sessionID string, change models.StatusChange) error {
+	session, err := s.transitionSession(ctx, sessionID, models.StatusTimeout, change, "/sessions/"+sessionID+"/status/timeout")
+	if err != nil {
+		return err
+	}
+
+	s.tm.DeleteTopologyFor(ctx, session.UserID)
+
+	return nil
+}
+
+// transitionSession moves a session to status to through the session state machine
+// and returns the session as it was before the change. Rejected transitions map to
+// 409 SessionNotInProgressError and unknown sessions to 404
+func (s *SessionService) transitionSession(ctx context.Context, sessionID string, to models.SessionStatus, change models.StatusChange, instance string) (*models.Session, error) {
 	session, err := s.repo.GetSessionByID(ctx, sessionID)
 	if err != nil {
-		// Translate repository errors to schema errors
 		if errors.Is(err, models.ErrSessionNotFound) {
-			return schemas.NewNotFoundError(
+			return nil, schemas.NewNotFoundError(
 				fmt.Sprintf("session with ID %s not found", sessionID),
-				"/sessions/"+sessionID+"/status/timeout",
+				instance,
 			)
 		}
-		return schemas.NewInternalError(
+		return nil, schemas.NewInternalError(
 			fmt.Sprintf("failed to get session status: %v", err),
-			"/sessions/"+sessionID+"/status/timeout",
-		)
-	}
-
-	// Check if session is in progress
-	if session.SessionStatus != models.StatusInProgress {
-		return schemas.SessionNotInProgressError(
-			"cannot update status: session is not IN_PROGRESS",
-			"/sessions/"+sessionID+"/status/timeout",
+			instance,
 		)
 	}
 
-	// Update session status to TIMEOUT
-	err = s.repo.UpdateSessionStatus(ctx, sessionID, models.StatusTimeout)
+	err = s.repo.TransitionSessionStatus(ctx, sessionID, session.SessionStatus, to, change)
 	if err != nil {
 		if errors.Is(err, models.ErrSessionNotFound) {
-			return schemas.NewNotFoundError(
+			return nil, schemas.NewNotFoundError(
 				fmt.Sprintf("session with ID %s not found", sessionID),
-				"/sessions/"+sessionID+"/status/timeout",
+				instance,
 			)
 		}
-		return schemas.NewInternalError(
-			fmt.Sprintf("failed to update session status to TIMEOUT: %v", err),
-			"/sessions/"+sessionID+"/status/timeout",
+		if models.IsInvalidTransition(err) {
+			return nil, schemas.SessionNotInProgressError(
+				fmt.Sprintf("cannot update status to %s: %v", to, err),
+				instance,
+			)
+		}
+		return nil, schemas.NewInternalError(
+			fmt.Sprintf("failed to update session status to %s: %v", to, err),
+			instance,
 		)
 	}
 
-	s.tm.DeleteTopologyFor(session.UserID)
-
-	return nil
+	return session, nil
 }
 
+// RotateCredentials issues a new RabbitMQ password for an active session
+// The session, its queues and permissions are preserved; only the password changes
+func (s *SessionService) RotateCredentials(ctx context.Context, sessionID string) (*schemas.RabbitMQCredentials, error) {
+	instance := "/sessions/" + sessionID + "/credentials/rotate"
 
-func (s *SessionService) RevokeToken(userID, tokenID string) error {
-	url := fmt.Sprintf("%s/tokens/revoke/%s?user_id=%s", s.config.GetUsersServiceURL(), tokenID, userID)
-	instance := "/tokens/revoke/" + tokenID
-
-	req, err := http.NewRequest(http.MethodDelete, url, nil)
+	session, err := s.repo.GetSessionByID(ctx, sessionID)
 	if err != nil {
-		// Network error - return 502 Bad Gateway
-		return schemas.NewBadGatewayError(
-			fmt.Sprintf("failed to connect to users-service: %v", err),
+		if errors.Is(err, models.ErrSessionNotFound) {
+			return nil, schemas.NewNotFoundError(
+				fmt.Sprintf("session with ID %s not found", sessionID),
+				instance,
+			)
+		}
+		return nil, schemas.NewInternalError(
+			fmt.Sprintf("failed to get session: %v", err),
 			instance,
 		)
 	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-
-	if err != nil {
-		return fmt.Errorf("failed to execute request: %w", err)
+	// Only active sessions have a broker user to rotate
+	if session.SessionStatus != models.StatusInProgress {
+		return nil, schemas.SessionNotInProgressError(
+			"cannot rotate credentials: session is not IN_PROGRESS",
+			instance,
+		)
 	}
-	defer resp.Body.Close()
 
-	// Read response body for error details
-	bodyBytes, readErr := io.ReadAll(resp.Body)
-	if readErr != nil {
-		return schemas.NewBadGatewayError(
-			"failed to read response from users-service",
+	credentials, err := newCredentials(s.config, session.UserID)
+	if err != nil {
+		return nil, schemas.NewInternalError(
+			fmt.Sprintf("failed to generate credentials: %v", err),
 			instance,
 		)
 	}
 
-	// Success case
-	if resp.StatusCode == http.StatusNoContent {
-		return nil
+	if err := s.tm.RotateCredentialsFor(ctx, session.UserID, credentials.Password); err != nil {
+		return nil, schemas.NewBadGatewayError(
+			fmt.Sprintf("failed to rotate broker credentials: %v", err),
+			instance,
+		)
 	}
 
-	// Handle 4xx client errors - propagate them
-	if resp.StatusCode >= 400 && resp.StatusCode < 500 {
-		// Try to decode the error response from the remote service
-		var remoteError schemas.ErrorResponse
-		if err := json.Unmarshal(bodyBytes, &remoteError); err == nil {
-			// Successfully decoded remote error - propagate it
-			return &remoteError
-		}
-		// Could not decode - return a generic error with the status code
-		return &schemas.ErrorResponse{
-			Type:     "https://connection-service.com/external-service-error",
-			Title:    "External Service Error",
-			Status:   resp.StatusCode,
-			Detail:   fmt.Sprintf("users-service returned error: %s", string(bodyBytes)),
-			Instance: instance,
+	if err := s.repo.UpdateSessionCredentials(ctx, sessionID, hashPassword(credentials.Password)); err != nil {
+		if errors.Is(err, models.ErrSessionNotFound) {
+			return nil, schemas.NewNotFoundError(
+				fmt.Sprintf("session with ID %s not found", sessionID),
+				instance,
+			)
 		}
+		return nil, schemas.NewInternalError(
+			fmt.Sprintf("failed to store rotated credentials: %v", err),
+			instance,
+		)
 	}
 
-	// Handle 5xx server errors - return 502 Bad Gateway
-	return schemas.NewBadGatewayError(
-		fmt.Sprintf("users-service returned status %d: %s", resp.StatusCode, string(bodyBytes)),
-		instance,
-	)
+	slog.InfoContext(ctx, "Rotated session credentials", "session_id", sessionID, "user_id", session.UserID)
+
+	return credentials, nil
 }
 
-// RevokeAuthorization revokes user authorization in users-service
-// Implements smart propagation: 4xx errors are propagated, 5xx/network errors return 502
-func (s *SessionService) RevokeAuthorization(userID, sessionID string) error {
-	url := fmt.Sprintf("%s/users/%s/status", s.config.GetUsersServiceURL(), userID)
-	body := `{"is_authorized": false}`
-	instance := "/sessions/" + sessionID + "/status/completed"
+// UpdateDispatcherStatus records the status reported by the dispatcher for a session
+// Repeating the current status is accepted as a no-op so redelivered reports are harmless,
+// and a later status is accepted even if it skips some; stale or regressive reports get 409
+func (s *SessionService) UpdateDispatcherStatus(ctx context.Context, sessionID string, status models.DispatcherStatus, change models.StatusChange) error {
+	instance := "/sessions/" + sessionID + "/dispatcher-status"
 
-	resp, err := httpPatchJSON(url, body)
-	if err != nil {
-		// Network error - return 502 Bad Gateway
-		return schemas.NewBadGatewayError(
-			fmt.Sprintf("failed to connect to users-service: %v", err),
+	if !status.IsValid() {
+		return schemas.NewBadRequestError(
+			fmt.Sprintf("invalid dispatcher status %q", status),
 			instance,
 		)
 	}
-	defer resp.Body.Close()
 
-	// Read response body for error details
-	bodyBytes, readErr := io.ReadAll(resp.Body)
-	if readErr != nil {
-		return schemas.NewBadGatewayError(
-			"failed to read response from users-service",
+	from, err := s.repo.UpdateDispatcherStatus(ctx, sessionID, status, change)
+	if err != nil {
+		if errors.Is(err, models.ErrSessionNotFound) {
+			return schemas.NewNotFoundError(
+				fmt.Sprintf("session with ID %s not found", sessionID),
+				instance,
+			)
+		}
+		if errors.Is(err, models.ErrInvalidDispatcherTransition) {
+			return schemas.NewConflictError(
+				fmt.Sprintf("cannot change dispatcher status from %s to %s", from, status),
+				instance,
+			)
+		}
+		return schemas.NewInternalError(
+			fmt.Sprintf("failed to update dispatcher status: %v", err),
 			instance,
 		)
 	}
 
-	// Success case
-	if resp.StatusCode == http.StatusOK {
-		return nil
+	return nil
+}
+
+// RevokeToken revokes the connection token of a session in users-service
+// 4xx errors are propagated, 5xx/network errors return 502 and an open circuit 503
+func (s *SessionService) RevokeToken(ctx context.Context, userID, tokenID string) error {
+	if err := s.users.RevokeToken(ctx, userID, tokenID); err != nil {
+		return usersclient.ToErrorResponse(err, "/tokens/revoke/"+tokenID)
 	}
+	return nil
+}
 
-	// Handle 4xx client errors - propagate them
-	if resp.StatusCode >= 400 && resp.StatusCode < 500 {
-		// Try to decode the error response from the remote service
-		var remoteError schemas.ErrorResponse
-		if err := json.Unmarshal(bodyBytes, &remoteError); err == nil {
-			// Successfully decoded remote error - propagate it
-			return &remoteError
-		}
-		// Could not decode - return a generic error with the status code
-		return &schemas.ErrorResponse{
-			Type:     "https://connection-service.com/external-service-error",
-			Title:    "External Service Error",
-			Status:   resp.StatusCode,
-			Detail:   fmt.Sprintf("users-service returned error: %s", string(bodyBytes)),
-			Instance: instance,
-		}
+// RevokeAuthorization revokes user authorization in users-service; errors are reported for instance
+// 4xx errors are propagated, 5xx/network errors return 502 and an open circuit 503
+func (s *SessionService) RevokeAuthorization(ctx context.Context, userID, instance string) error {
+	if err := s.users.RevokeAuthorization(ctx, userID); err != nil {
+		return usersclient.ToErrorResponse(err, instance)
+	}
+	return nil
+}
+
+// parseTimeParam parses an optional RFC 3339 query parameter
+func parseTimeParam(name, value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
 	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+	}
+	return &parsed, nil
+}
 
-	// Handle 5xx server errors - return 502 Bad Gateway
-	return schemas.NewBadGatewayError(
-		fmt.Sprintf("users-service returned status %d: %s", resp.StatusCode, string(bodyBytes)),
-		instance,
-	)
+// encodeSessionCursor builds an opaque pagination cursor from the last row of a page
+func encodeSessionCursor(createdAt time.Time, sessionID string) string {
+	raw := createdAt.Format(time.RFC3339Nano) + "|" + sessionID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
 }
 
-// httpPatchJSON performs a PATCH request with JSON body and returns the response
-func httpPatchJSON(url, body string) (*http.Response, error) {
-	req, err := http.NewRequest("PATCH", url, strings.NewReader(body))
+// decodeSessionCursor reverses encodeSessionCursor
+func decodeSessionCursor(cursor string) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
 	if err != nil {
-		return nil, err
+		return time.Time{}, "", err
+	}
+	createdAt, sessionID, found := strings.Cut(string(raw), "|")
+	if !found || sessionID == "" {
+		return time.Time{}, "", fmt.Errorf("malformed cursor")
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return time.Time{}, "", err
 	}
-	req.Header.Set("Content-Type", "application/json")
-	client := &http.Client{}
-	return client.Do(req)
+	return parsed, sessionID, nil
 }
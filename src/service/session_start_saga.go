@@ -0,0 +1,322 @@
+package service
+
+import (
+	"connection-service/src/config"
+	"connection-service/src/middleware"
+	"connection-service/src/models"
+	"connection-service/src/repository"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// NotifyFunc publishes the new-connection notification of a session
+type NotifyFunc func(ctx context.Context) error
+
+// SessionStartSagaRunner drives the session start saga: session row, broker user,
+// queues, permissions and notification. Every completed step is persisted, so if a
+// pod dies half-way the recovery loop of another replica resumes the saga or
+// compensates it instead of leaking broker users and queues.
+type SessionStartSagaRunner struct {
+	sagaRepo    *repository.SagaRepository
+	sessionRepo *repository.SessionRepository
+	outboxRepo  *repository.OutboxRepository
+	tm          *middleware.RabbitMQTopologyManager
+	config      *config.GlobalConfig
+	cancel      context.CancelFunc
+	done        chan struct{}
+	stopOnce    sync.Once
+}
+
+// NewSessionStartSagaRunner creates a saga runner; call Start to run the recovery loop
+func NewSessionStartSagaRunner(sagaRepo *repository.SagaRepository, sessionRepo *repository.SessionRepository, outboxRepo *repository.OutboxRepository, tm *middleware.RabbitMQTopologyManager, cfg *config.GlobalConfig) *SessionStartSagaRunner {
+	return &SessionStartSagaRunner{
+		sagaRepo:    sagaRepo,
+		sessionRepo: sessionRepo,
+		outboxRepo:  outboxRepo,
+		tm:          tm,
+		config:      cfg,
+		done:        make(chan struct{}),
+	}
+}
+
+// Execute runs the remaining steps of a freshly created saga from the request path
+// password is the broker password issued to the client and notify publishes the
+// new-connection notification. If a step fails the saga is compensated right away;
+// a compensation that fails as well is left to the recovery loop
+func (r *SessionStartSagaRunner) Execute(ctx context.Context, saga *models.SessionStartSaga, password string, notify NotifyFunc) error {
+	err := r.advance(ctx, saga, password, notify)
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, models.ErrSagaStateConflict) {
+		// Recovery took the saga over while this request was stalled
+		return err
+	}
+
+	// Compensate even if the client went away, otherwise the broker resources leak until recovery
+	r.compensate(context.WithoutCancel(ctx), saga, err.Error())
+	return err
+}
+
+// IsSettling reports whether the start saga of a session is still running or compensating
+// Sessions created before sagas existed have none and are considered settled
+func (r *SessionStartSagaRunner) IsSettling(ctx context.Context, sessionID string) (bool, error) {
+	saga, err := r.sagaRepo.GetSaga(ctx, sessionID)
+	if err != nil {
+		return false, err
+	}
+	return saga != nil && !saga.IsFinished(), nil
+}
+
+// CompensateUndeliverable rolls back a session whose new-connection notification the
+// broker could not route to any queue. Its saga may have completed already, as a
+// resumed saga only hands the notification over to the outbox relay
+func (r *SessionStartSagaRunner) CompensateUndeliverable(ctx context.Context, sessionID string, cause string) error {
+	saga, err := r.sagaRepo.GetSaga(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if saga == nil || saga.State == models.SagaStateCompensated {
+		// Nothing left to roll back, only the failed notification
+		return r.outboxRepo.DiscardSessionMessages(ctx, sessionID)
+	}
+
+	if err := r.sagaRepo.ReopenForCompensation(ctx, sessionID, cause); err != nil {
+		return err
+	}
+	saga.State = models.SagaStateCompensating
+
+	r.finishCompensation(ctx, saga, cause)
+	return nil
+}
+
+// Start launches the recovery loop in a background goroutine
+func (r *SessionStartSagaRunner) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+
+	sagaConfig := r.config.GetSagaConfig()
+	slog.InfoContext(ctx, "Starting session start saga recovery",
+		"interval", sagaConfig.GetRecoveryInterval(),
+		"stale_after", sagaConfig.GetStaleAfter(),
+		"max_attempts", sagaConfig.GetMaxAttempts())
+
+	go r.run(ctx)
+}
+
+// Stop cancels the recovery loop and waits for the current batch to finish
+func (r *SessionStartSagaRunner) Stop() {
+	r.stopOnce.Do(func() {
+		if r.cancel == nil {
+			return
+		}
+		r.cancel()
+		<-r.done
+		slog.Info("Session start saga recovery stopped")
+	})
+}
+
+func (r *SessionStartSagaRunner) run(ctx context.Context) {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.config.GetSagaConfig().GetRecoveryInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.recover(ctx)
+		}
+	}
+}
+
+// recover claims a batch of stale sagas and resumes or compensates each of them
+// Claimed sagas are leased to this replica until they go stale again
+func (r *SessionStartSagaRunner) recover(ctx context.Context) {
+	sagaConfig := r.config.GetSagaConfig()
+
+	sagas, err := r.sagaRepo.ClaimStale(ctx, time.Now().Add(-sagaConfig.GetStaleAfter()), sagaConfig.GetBatchSize())
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Failed to claim stale session start sagas", "error", err)
+		}
+		return
+	}
+
+	for i := range sagas {
+		saga := &sagas[i]
+		slog.InfoContext(ctx, "Recovering session start saga",
+			"session_id", saga.SessionID,
+			"state", saga.State,
+			"last_step", saga.LastStep,
+			"attempts", saga.Attempts)
+
+		switch {
+		case saga.State == models.SagaStateCompensating:
+			r.compensate(ctx, saga, saga.LastError)
+		case saga.Attempts >= sagaConfig.GetMaxAttempts():
+			r.compensate(ctx, saga, fmt.Sprintf("gave up after %d recovery attempts: %s", saga.Attempts, saga.LastError))
+		default:
+			r.resume(ctx, saga)
+		}
+	}
+}
+
+// resume continues a stalled saga from its last completed step
+// The password issued by the original request is unknown, so a new one is stored if the
+// broker user still has to be created; the client gets it when it reconnects
+func (r *SessionStartSagaRunner) resume(ctx context.Context, saga *models.SessionStartSaga) {
+	status, err := r.sessionRepo.GetSessionStatus(ctx, saga.SessionID)
+	if errors.Is(err, models.ErrSessionNotFound) || (err == nil && status != models.StatusInProgress) {
+		r.compensate(ctx, saga, fmt.Sprintf("session is no longer %s", models.StatusInProgress))
+		return
+	}
+
+	var password string
+	if err == nil && !models.SagaStepBrokerUserCreated.Reached(saga.LastStep) {
+		password, err = r.issuePassword(ctx, saga)
+	}
+
+	if err == nil {
+		err = r.advance(ctx, saga, password, func(ctx context.Context) error {
+			// Hand the held notification over to the outbox relay
+			_, err := r.outboxRepo.ReleaseSessionMessages(ctx, saga.SessionID)
+			return err
+		})
+	}
+	if err == nil {
+		slog.InfoContext(ctx, "Resumed session start saga", "session_id", saga.SessionID)
+		return
+	}
+
+	slog.WarnContext(ctx, "Failed to resume session start saga",
+		"session_id", saga.SessionID,
+		"last_step", saga.LastStep,
+		"error", err)
+	if err := r.sagaRepo.RecordFailure(ctx, saga.SessionID, err.Error()); err != nil {
+		slog.ErrorContext(ctx, "Failed to record saga failure", "session_id", saga.SessionID, "error", err)
+	}
+}
+
+// issuePassword generates a broker password for a resumed saga and stores its digest
+func (r *SessionStartSagaRunner) issuePassword(ctx context.Context, saga *models.SessionStartSaga) (string, error) {
+	credentials, err := newCredentials(r.config, saga.UserID)
+	if err != nil {
+		return "", err
+	}
+	if err := r.sessionRepo.UpdateSessionCredentials(ctx, saga.SessionID, hashPassword(credentials.Password)); err != nil {
+		return "", err
+	}
+	return credentials.Password, nil
+}
+
+// advance runs every step after saga.LastStep, persisting each one as it completes
+func (r *SessionStartSagaRunner) advance(ctx context.Context, saga *models.SessionStartSaga, password string, notify NotifyFunc) error {
+	for _, step := range saga.LastStep.RemainingSteps() {
+		if err := r.runStep(ctx, saga, step, password, notify); err != nil {
+			slog.ErrorContext(ctx, "Session start saga step failed",
+				"session_id", saga.SessionID,
+				"step", step,
+				"error", err)
+			return fmt.Errorf("saga step %s failed: %w", step, err)
+		}
+
+		var err error
+		if step == models.SagaStepNotified {
+			err = r.sagaRepo.Complete(ctx, saga.SessionID)
+		} else {
+			err = r.sagaRepo.RecordStep(ctx, saga.SessionID, step)
+		}
+		if err != nil {
+			return err
+		}
+		saga.LastStep = step
+	}
+
+	saga.State = models.SagaStateCompleted
+	return nil
+}
+
+func (r *SessionStartSagaRunner) runStep(ctx context.Context, saga *models.SessionStartSaga, step models.SagaStep, password string, notify NotifyFunc) error {
+	switch step {
+	case models.SagaStepBrokerUserCreated:
+		return r.tm.CreateClientUser(ctx, saga.UserID, password)
+	case models.SagaStepQueuesDeclared:
+		return r.tm.DeclareClientQueues(ctx, saga.UserID, saga.ModelType)
+	case models.SagaStepPermissionsSet:
+		return r.tm.SetClientPermissions(ctx, saga.UserID)
+	case models.SagaStepNotified:
+		return notify(ctx)
+	default:
+		return fmt.Errorf("unknown saga step %s", step)
+	}
+}
+
+// compensate undoes a saga: the notification is discarded, the broker topology deleted
+// and the session marked FAILED. Failures are recorded and retried by the recovery loop
+func (r *SessionStartSagaRunner) compensate(ctx context.Context, saga *models.SessionStartSaga, cause string) {
+	if err := r.sagaRepo.StartCompensation(ctx, saga.SessionID, cause); err != nil {
+		if !errors.Is(err, models.ErrSagaStateConflict) {
+			slog.ErrorContext(ctx, "Failed to start saga compensation", "session_id", saga.SessionID, "error", err)
+		}
+		return
+	}
+	saga.State = models.SagaStateCompensating
+
+	r.finishCompensation(ctx, saga, cause)
+}
+
+// finishCompensation undoes a compensating saga and marks it compensated
+func (r *SessionStartSagaRunner) finishCompensation(ctx context.Context, saga *models.SessionStartSaga, cause string) {
+	if err := r.undo(ctx, saga, cause); err != nil {
+		slog.ErrorContext(ctx, "Failed to compensate session start saga", "session_id", saga.SessionID, "error", err)
+		if err := r.sagaRepo.RecordFailure(ctx, saga.SessionID, err.Error()); err != nil {
+			slog.ErrorContext(ctx, "Failed to record saga failure", "session_id", saga.SessionID, "error", err)
+		}
+		return
+	}
+
+	if err := r.sagaRepo.FinishCompensation(ctx, saga.SessionID); err != nil {
+		slog.ErrorContext(ctx, "Failed to finish saga compensation", "session_id", saga.SessionID, "error", err)
+		return
+	}
+	saga.State = models.SagaStateCompensated
+
+	slog.InfoContext(ctx, "Compensated session start saga",
+		"session_id", saga.SessionID,
+		"user_id", saga.UserID,
+		"cause", cause)
+}
+
+// undo reverts the effects of a saga; every action is idempotent so it can be retried
+func (r *SessionStartSagaRunner) undo(ctx context.Context, saga *models.SessionStartSaga, cause string) error {
+	if err := r.outboxRepo.DiscardSessionMessages(ctx, saga.SessionID); err != nil {
+		return err
+	}
+
+	// Broker resources are keyed by user, so leave them alone if a newer session owns them
+	active, err := r.sessionRepo.GetActiveSession(ctx, saga.UserID)
+	if err != nil {
+		return err
+	}
+	if active == nil || active.SessionID == saga.SessionID {
+		if err := r.tm.DeleteTopologyFor(ctx, saga.UserID); err != nil {
+			return err
+		}
+	}
+
+	err = r.sessionRepo.TransitionSessionStatus(ctx, saga.SessionID, models.StatusInProgress, models.StatusFailed, models.StatusChange{
+		Actor:  models.ActorSaga,
+		Reason: cause,
+	})
+	if err != nil && !errors.Is(err, models.ErrSessionNotFound) && !models.IsInvalidTransition(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"connection-service/src/config"
+	"connection-service/src/middleware"
+	"connection-service/src/schemas"
+	"connection-service/src/usersclient"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// userEventsPrefetch bounds the unacknowledged user events per replica
+const userEventsPrefetch = 50
+
+// UserEventsConsumer drops cached user profiles when users-service announces a
+// change on USER_EVENTS_EXCHANGE. Every replica has its own cache, so each one
+// consumes a temporary queue of its own, named by the broker so replicas sharing
+// a POD_NAME cannot end up on the same queue.
+type UserEventsConsumer struct {
+	cache       *usersclient.CachingClient
+	middleware  *middleware.Middleware
+	consumerTag string
+	cancel      context.CancelFunc
+	done        chan struct{}
+	stopOnce    sync.Once
+}
+
+// NewUserEventsConsumer creates a consumer; call Start to begin consuming
+func NewUserEventsConsumer(cache *usersclient.CachingClient, mw *middleware.Middleware, cfg *config.GlobalConfig) *UserEventsConsumer {
+	return &UserEventsConsumer{
+		cache:       cache,
+		middleware:  mw,
+		consumerTag: cfg.GetPodName() + "-user-events",
+		done:        make(chan struct{}),
+	}
+}
+
+// Start declares the user events exchange, then consumes in the background
+func (c *UserEventsConsumer) Start() error {
+	if err := c.middleware.DeclareExchange(config.USER_EVENTS_EXCHANGE, "fanout", true); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+
+	go c.run(ctx)
+	return nil
+}
+
+// Stop cancels the consumer and waits for it to exit
+func (c *UserEventsConsumer) Stop() {
+	c.stopOnce.Do(func() {
+		if c.cancel == nil {
+			return
+		}
+		c.cancel()
+		c.middleware.CancelConsumer(c.consumerTag)
+		<-c.done
+		slog.Info("User events consumer stopped")
+	})
+}
+
+func (c *UserEventsConsumer) run(ctx context.Context) {
+	defer close(c.done)
+
+	for {
+		deliveries, err := c.subscribe()
+		if err != nil {
+			slog.Error("Failed to start user events consumer", "error", err, "retry_in", consumerRetryDelay)
+		} else {
+			for delivery := range deliveries {
+				c.handle(delivery)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(consumerRetryDelay):
+			slog.Info("Re-subscribing user events consumer")
+		}
+	}
+}
+
+// subscribe declares a new temporary queue, which the broker deletes whenever the
+// consumer goes away, and starts consuming it
+func (c *UserEventsConsumer) subscribe() (<-chan amqp.Delivery, error) {
+	queueName, err := c.middleware.DeclareTemporaryQueue()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.middleware.BindQueue(queueName, config.USER_EVENTS_EXCHANGE, ""); err != nil {
+		return nil, err
+	}
+	return c.middleware.Consume(queueName, c.consumerTag, userEventsPrefetch)
+}
+
+// handle invalidates the cached profile named by a single event
+func (c *UserEventsConsumer) handle(delivery amqp.Delivery) {
+	var event schemas.UserUpdatedEvent
+	if err := json.Unmarshal(delivery.Body, &event); err != nil || event.UserID == "" {
+		slog.Warn("Discarding malformed user event", "body", string(delivery.Body), "error", err)
+		delivery.Nack(false, false)
+		return
+	}
+
+	c.cache.Invalidate(event.UserID)
+	delivery.Ack(false)
+}
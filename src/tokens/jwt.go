@@ -0,0 +1,118 @@
+package tokens
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"connection-service/src/config"
+	"connection-service/src/schemas"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signingMethods are the asymmetric algorithms accepted for connection tokens
+// Symmetric and "none" algorithms are rejected so a public key can never act as a secret
+var signingMethods = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+	"EdDSA",
+}
+
+// Claims are the claims users-service puts in a signed connection token
+// The token ID is carried in the standard jti claim
+type Claims struct {
+	jwt.RegisteredClaims
+	UserID  string `json:"user_id"`
+	MaxUses *int   `json:"max_uses,omitempty"`
+}
+
+// JWTValidator verifies signed connection tokens without calling users-service
+// It checks the signature, expiry, user_id and max_uses claims; uses are counted
+// as the sessions started with the token. Revocations made in users-service are not
+// seen, so a token is only known to be revoked once a session started with it has
+// ended: tokens must carry max_uses or expire shortly after they are issued
+type JWTValidator struct {
+	keys   *KeySet
+	usage  TokenUsage
+	parser *jwt.Parser
+}
+
+// NewJWTValidator creates a validator for tokens signed by one of keys
+func NewJWTValidator(keys *KeySet, usage TokenUsage, cfg *config.TokenConfig) *JWTValidator {
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(signingMethods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(cfg.GetLeeway()),
+	}
+	if cfg.GetIssuer() != "" {
+		options = append(options, jwt.WithIssuer(cfg.GetIssuer()))
+	}
+	if cfg.GetAudience() != "" {
+		options = append(options, jwt.WithAudience(cfg.GetAudience()))
+	}
+
+	return &JWTValidator{
+		keys:   keys,
+		usage:  usage,
+		parser: jwt.NewParser(options...),
+	}
+}
+
+// Validate verifies token for userID
+func (v *JWTValidator) Validate(ctx context.Context, userID, token string) (*schemas.TokenInfo, error) {
+	var claims Claims
+	_, err := v.parser.ParseWithClaims(token, &claims, v.keyFunc)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenMalformed) || errors.Is(err, errUnknownKey) {
+			return nil, fmt.Errorf("%w: %v", ErrNotVerifiable, err)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if claims.UserID != userID {
+		return nil, fmt.Errorf("%w: token was issued to another user", ErrInvalidToken)
+	}
+	if claims.ID == "" {
+		return nil, fmt.Errorf("%w: token has no jti claim", ErrInvalidToken)
+	}
+
+	// releaseSession revokes the token in users-service, which is not consulted here
+	revoked, err := v.usage.HasEndedSessionByToken(ctx, claims.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	if revoked {
+		return nil, fmt.Errorf("%w: token %s was revoked when its session ended", ErrInvalidToken, claims.ID)
+	}
+
+	info := &schemas.TokenInfo{
+		TokenID: claims.ID,
+		IsValid: true,
+		UserID:  &claims.UserID,
+		MaxUses: claims.MaxUses,
+	}
+	if claims.ExpiresAt != nil {
+		info.ExpiresAt = &claims.ExpiresAt.Time
+	}
+
+	if claims.MaxUses != nil {
+		used, err := v.usage.CountSessionsByToken(ctx, claims.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count token uses: %w", err)
+		}
+		if used >= *claims.MaxUses {
+			return nil, fmt.Errorf("%w: token %s was used %d of %d times", ErrTokenExhausted, claims.ID, used, *claims.MaxUses)
+		}
+		info.UsageCount = &used
+	}
+
+	return info, nil
+}
+
+// keyFunc picks the verification key named by the kid header
+func (v *JWTValidator) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	return v.keys.Lookup(kid)
+}
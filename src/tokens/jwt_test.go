@@ -0,0 +1,234 @@
+package tokens
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"connection-service/src/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// fakeTokenUsage reports the sessions started with each token ID
+type fakeTokenUsage struct {
+	sessions map[string]int
+	ended    map[string]bool
+	err      error
+}
+
+func (f *fakeTokenUsage) CountSessionsByToken(ctx context.Context, tokenID string) (int, error) {
+	return f.sessions[tokenID], f.err
+}
+
+func (f *fakeTokenUsage) HasEndedSessionByToken(ctx context.Context, tokenID string) (bool, error) {
+	return f.ended[tokenID], f.err
+}
+
+// testSigner signs connection tokens with a fresh Ed25519 key
+type testSigner struct {
+	public  ed25519.PublicKey
+	private ed25519.PrivateKey
+}
+
+func newTestSigner(t *testing.T) *testSigner {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return &testSigner{public: public, private: private}
+}
+
+func (s *testSigner) keys() *KeySet {
+	return &KeySet{keys: map[string]crypto.PublicKey{"": s.public}}
+}
+
+func (s *testSigner) sign(t *testing.T, claims Claims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims).SignedString(s.private)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return token
+}
+
+// validClaims returns the claims of a token issued to alice, expiring in an hour
+func validClaims() Claims {
+	return Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "token-1",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+		UserID: "alice",
+	}
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func TestJWTValidatorValidate(t *testing.T) {
+	signer := newTestSigner(t)
+	otherSigner := newTestSigner(t)
+
+	tests := []struct {
+		name      string
+		token     func(t *testing.T) string
+		usage     *fakeTokenUsage
+		wantErr   error
+		wantUsage *int
+	}{
+		{
+			name:  "valid token",
+			token: func(t *testing.T) string { return signer.sign(t, validClaims()) },
+			usage: &fakeTokenUsage{},
+		},
+		{
+			name: "uses left",
+			token: func(t *testing.T) string {
+				claims := validClaims()
+				claims.MaxUses = intPtr(3)
+				return signer.sign(t, claims)
+			},
+			usage:     &fakeTokenUsage{sessions: map[string]int{"token-1": 2}},
+			wantUsage: intPtr(2),
+		},
+		{
+			name: "no uses left",
+			token: func(t *testing.T) string {
+				claims := validClaims()
+				claims.MaxUses = intPtr(1)
+				return signer.sign(t, claims)
+			},
+			usage:   &fakeTokenUsage{sessions: map[string]int{"token-1": 1}},
+			wantErr: ErrTokenExhausted,
+		},
+		{
+			name:    "session of the token ended",
+			token:   func(t *testing.T) string { return signer.sign(t, validClaims()) },
+			usage:   &fakeTokenUsage{ended: map[string]bool{"token-1": true}},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "issued to another user",
+			token: func(t *testing.T) string {
+				claims := validClaims()
+				claims.UserID = "bob"
+				return signer.sign(t, claims)
+			},
+			usage:   &fakeTokenUsage{},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "no jti",
+			token: func(t *testing.T) string {
+				claims := validClaims()
+				claims.ID = ""
+				return signer.sign(t, claims)
+			},
+			usage:   &fakeTokenUsage{},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "expired",
+			token: func(t *testing.T) string {
+				claims := validClaims()
+				claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+				return signer.sign(t, claims)
+			},
+			usage:   &fakeTokenUsage{},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "no expiry",
+			token: func(t *testing.T) string {
+				claims := validClaims()
+				claims.ExpiresAt = nil
+				return signer.sign(t, claims)
+			},
+			usage:   &fakeTokenUsage{},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "signed with another key",
+			token:   func(t *testing.T) string { return otherSigner.sign(t, validClaims()) },
+			usage:   &fakeTokenUsage{},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "symmetric algorithm",
+			token: func(t *testing.T) string {
+				token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims()).SignedString([]byte(signer.public))
+				if err != nil {
+					t.Fatalf("failed to sign token: %v", err)
+				}
+				return token
+			},
+			usage:   &fakeTokenUsage{},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "unknown key ID",
+			token: func(t *testing.T) string {
+				token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, validClaims())
+				token.Header["kid"] = "rotated-away"
+				signed, err := token.SignedString(signer.private)
+				if err != nil {
+					t.Fatalf("failed to sign token: %v", err)
+				}
+				return signed
+			},
+			usage:   &fakeTokenUsage{},
+			wantErr: ErrNotVerifiable,
+		},
+		{
+			name:    "opaque token",
+			token:   func(t *testing.T) string { return "not-a-jwt" },
+			usage:   &fakeTokenUsage{},
+			wantErr: ErrNotVerifiable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator := NewJWTValidator(signer.keys(), tt.usage, &config.TokenConfig{})
+
+			info, err := validator.Validate(context.Background(), "alice", tt.token(t))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Validate() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if info.TokenID != "token-1" || !info.IsValid {
+				t.Errorf("Validate() = %+v, want valid token-1", info)
+			}
+			if (info.UsageCount == nil) != (tt.wantUsage == nil) ||
+				(info.UsageCount != nil && *info.UsageCount != *tt.wantUsage) {
+				t.Errorf("UsageCount = %v, want %v", info.UsageCount, tt.wantUsage)
+			}
+		})
+	}
+}
+
+func TestJWTValidatorUsageFailure(t *testing.T) {
+	signer := newTestSigner(t)
+	usageErr := errors.New("database is down")
+	validator := NewJWTValidator(signer.keys(), &fakeTokenUsage{err: usageErr}, &config.TokenConfig{})
+
+	_, err := validator.Validate(context.Background(), "alice", signer.sign(t, validClaims()))
+	if !errors.Is(err, usageErr) {
+		t.Fatalf("Validate() error = %v, want %v", err, usageErr)
+	}
+	if errors.Is(err, ErrInvalidToken) {
+		t.Error("a failed revocation check rejected the token as invalid")
+	}
+}
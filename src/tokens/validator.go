@@ -0,0 +1,131 @@
+package tokens
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"connection-service/src/config"
+	"connection-service/src/schemas"
+	"connection-service/src/usersclient"
+)
+
+// Token validation modes selected with TOKEN_VALIDATION_MODE
+const (
+	ModeRemote          = "remote"
+	ModeJWT             = "jwt"
+	ModeJWTWithFallback = "jwt_with_fallback"
+)
+
+var (
+	// ErrInvalidToken indicates that a token was verified and rejected
+	ErrInvalidToken = errors.New("invalid token")
+
+	// ErrTokenExhausted indicates that a token has no uses left
+	ErrTokenExhausted = errors.New("token has no uses left")
+
+	// ErrNotVerifiable indicates that a token cannot be checked locally,
+	// e.g. it is not a JWT or was signed with an unknown key
+	ErrNotVerifiable = errors.New("token cannot be verified locally")
+)
+
+// Validator checks the connection token a user presents on /sessions/start
+type Validator interface {
+	Validate(ctx context.Context, userID, token string) (*schemas.TokenInfo, error)
+}
+
+// TokenUsage reports what the sessions started with a token tell about it
+type TokenUsage interface {
+	// CountSessionsByToken returns how many sessions were started with the token
+	CountSessionsByToken(ctx context.Context, tokenID string) (int, error)
+	// HasEndedSessionByToken reports whether a session started with the token was
+	// completed, cancelled or timed out, which revokes the token
+	HasEndedSessionByToken(ctx context.Context, tokenID string) (bool, error)
+}
+
+// NewValidator builds the validator for the configured mode
+func NewValidator(cfg *config.TokenConfig, users usersclient.Client, usage TokenUsage) (Validator, error) {
+	remote := NewRemoteValidator(users)
+	if cfg.GetMode() == ModeRemote {
+		return remote, nil
+	}
+
+	keys, err := LoadKeys(cfg.GetPublicKeyFile(), cfg.GetJWKSFile())
+	if err != nil {
+		return nil, err
+	}
+	local := NewJWTValidator(keys, usage, cfg)
+
+	slog.Info("Validating connection tokens locally",
+		"mode", cfg.GetMode(),
+		"keys", keys.Len())
+
+	if cfg.GetMode() == ModeJWTWithFallback {
+		return NewFallbackValidator(local, remote), nil
+	}
+	return local, nil
+}
+
+// ToErrorResponse maps a validation error to the API error for instance
+// Rejected tokens become 401, exhausted tokens 403, and remote failures are
+// mapped like any other users-service error
+func ToErrorResponse(err error, instance string) *schemas.ErrorResponse {
+	switch {
+	case errors.Is(err, ErrTokenExhausted):
+		return &schemas.ErrorResponse{
+			Type:     "https://connection-service.com/token-exhausted",
+			Title:    "Token Exhausted",
+			Status:   http.StatusForbidden,
+			Detail:   err.Error(),
+			Instance: instance,
+		}
+	case errors.Is(err, ErrInvalidToken), errors.Is(err, ErrNotVerifiable):
+		return schemas.NewUnauthorizedError(err.Error(), instance)
+	}
+	return usersclient.ToErrorResponse(err, instance)
+}
+
+// RemoteValidator asks users-service to validate every token
+type RemoteValidator struct {
+	users usersclient.Client
+}
+
+// NewRemoteValidator creates a validator backed by POST /tokens/validate
+func NewRemoteValidator(users usersclient.Client) *RemoteValidator {
+	return &RemoteValidator{users: users}
+}
+
+// Validate calls users-service, which also counts the use
+func (v *RemoteValidator) Validate(ctx context.Context, userID, token string) (*schemas.TokenInfo, error) {
+	return v.users.ValidateToken(ctx, userID, token)
+}
+
+// FallbackValidator validates locally and only asks the remote validator about
+// tokens that cannot be verified locally. Tokens that were verified and rejected
+// are not retried remotely.
+type FallbackValidator struct {
+	local  Validator
+	remote Validator
+}
+
+// NewFallbackValidator combines a local and a remote validator
+func NewFallbackValidator(local, remote Validator) *FallbackValidator {
+	return &FallbackValidator{local: local, remote: remote}
+}
+
+// Validate tries the local validator first
+func (v *FallbackValidator) Validate(ctx context.Context, userID, token string) (*schemas.TokenInfo, error) {
+	info, err := v.local.Validate(ctx, userID, token)
+	if !errors.Is(err, ErrNotVerifiable) {
+		return info, err
+	}
+
+	slog.DebugContext(ctx, "Falling back to remote token validation", "user_id", userID, "reason", err)
+	info, err = v.remote.Validate(ctx, userID, token)
+	if err != nil {
+		return nil, fmt.Errorf("remote token validation: %w", err)
+	}
+	return info, nil
+}
@@ -0,0 +1,117 @@
+package tokens
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"connection-service/src/schemas"
+	"connection-service/src/usersclient"
+)
+
+// stubValidator returns a fixed result and records whether it was called
+type stubValidator struct {
+	info   *schemas.TokenInfo
+	err    error
+	called bool
+}
+
+func (s *stubValidator) Validate(ctx context.Context, userID, token string) (*schemas.TokenInfo, error) {
+	s.called = true
+	return s.info, s.err
+}
+
+func TestFallbackValidator(t *testing.T) {
+	localInfo := &schemas.TokenInfo{TokenID: "local", IsValid: true}
+	remoteInfo := &schemas.TokenInfo{TokenID: "remote", IsValid: true}
+	remoteErr := &usersclient.StatusError{StatusCode: http.StatusUnauthorized, Detail: "token is not valid"}
+
+	tests := []struct {
+		name       string
+		local      *stubValidator
+		remote     *stubValidator
+		wantToken  string
+		wantErr    error
+		wantRemote bool
+	}{
+		{
+			name:      "verified locally",
+			local:     &stubValidator{info: localInfo},
+			remote:    &stubValidator{info: remoteInfo},
+			wantToken: "local",
+		},
+		{
+			name:    "rejected locally",
+			local:   &stubValidator{err: fmt.Errorf("%w: expired", ErrInvalidToken)},
+			remote:  &stubValidator{info: remoteInfo},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "exhausted locally",
+			local:   &stubValidator{err: fmt.Errorf("%w: used twice", ErrTokenExhausted)},
+			remote:  &stubValidator{info: remoteInfo},
+			wantErr: ErrTokenExhausted,
+		},
+		{
+			name:       "not verifiable locally",
+			local:      &stubValidator{err: fmt.Errorf("%w: not a JWT", ErrNotVerifiable)},
+			remote:     &stubValidator{info: remoteInfo},
+			wantToken:  "remote",
+			wantRemote: true,
+		},
+		{
+			name:       "rejected remotely",
+			local:      &stubValidator{err: fmt.Errorf("%w: not a JWT", ErrNotVerifiable)},
+			remote:     &stubValidator{err: remoteErr},
+			wantErr:    remoteErr,
+			wantRemote: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator := NewFallbackValidator(tt.local, tt.remote)
+
+			info, err := validator.Validate(context.Background(), "alice", "token")
+			if tt.remote.called != tt.wantRemote {
+				t.Errorf("remote validator called = %v, want %v", tt.remote.called, tt.wantRemote)
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Validate() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if info.TokenID != tt.wantToken {
+				t.Errorf("TokenID = %s, want %s", info.TokenID, tt.wantToken)
+			}
+		})
+	}
+}
+
+func TestToErrorResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{"invalid token", fmt.Errorf("%w: expired", ErrInvalidToken), http.StatusUnauthorized},
+		{"not verifiable", fmt.Errorf("%w: unknown key", ErrNotVerifiable), http.StatusUnauthorized},
+		{"exhausted", fmt.Errorf("%w: used twice", ErrTokenExhausted), http.StatusForbidden},
+		{"users-service rejection", &usersclient.StatusError{StatusCode: http.StatusUnauthorized}, http.StatusUnauthorized},
+		{"users-service down", usersclient.ErrCircuitOpen, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ToErrorResponse(tt.err, "/sessions/start").Status; got != tt.wantStatus {
+				t.Errorf("ToErrorResponse() status = %d, want %d", got, tt.wantStatus)
+			}
+		})
+	}
+}
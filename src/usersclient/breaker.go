@@ -0,0 +1,98 @@
+package usersclient
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// callOutcome classifies a call for the circuit breaker
+type callOutcome int
+
+const (
+	outcomeSuccess callOutcome = iota
+	outcomeFailure
+	// outcomeIgnored is a call that says nothing about the health of users-service,
+	// e.g. one the caller cancelled
+	outcomeIgnored
+)
+
+// circuitBreaker stops calling users-service after threshold consecutive failures.
+// Once cooldown has passed a single trial call is let through: success closes the
+// circuit again, failure re-opens it for another cooldown.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     breakerState
+	failures  int
+	openedAt  time.Time
+	trialBusy bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+// allow returns ErrCircuitOpen if the call must fail fast
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = breakerHalfOpen
+		b.trialBusy = true
+		slog.Info("Users-service circuit breaker half-open, sending trial request")
+	case breakerHalfOpen:
+		if b.trialBusy {
+			return ErrCircuitOpen
+		}
+		b.trialBusy = true
+	}
+	return nil
+}
+
+// record updates the breaker with the outcome of an allowed call
+func (b *circuitBreaker) record(outcome callOutcome) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch outcome {
+	case outcomeIgnored:
+		b.trialBusy = false
+	case outcomeSuccess:
+		if b.state != breakerClosed {
+			slog.Info("Users-service circuit breaker closed")
+		}
+		b.state = breakerClosed
+		b.failures = 0
+		b.trialBusy = false
+	case outcomeFailure:
+		b.failures++
+		b.trialBusy = false
+		if b.state == breakerHalfOpen || b.failures >= b.threshold {
+			if b.state != breakerOpen {
+				slog.Warn("Users-service circuit breaker opened",
+					"consecutive_failures", b.failures,
+					"cooldown", b.cooldown)
+			}
+			b.state = breakerOpen
+			b.openedAt = time.Now()
+		}
+	}
+}
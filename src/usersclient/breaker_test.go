@@ -0,0 +1,95 @@
+package usersclient
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestCircuitBreaker drives the breaker through a sequence of steps:
+// "ok", "fail" and "ignore" are allowed calls with that outcome, "trial" is an allowed
+// call still in flight, "blocked" is a call that must fail fast and "cooldown" lets
+// the cooldown of an open breaker pass
+func TestCircuitBreaker(t *testing.T) {
+	tests := []struct {
+		name      string
+		steps     []string
+		wantState breakerState
+	}{
+		{
+			name:      "stays closed below the threshold",
+			steps:     []string{"fail", "fail", "ok"},
+			wantState: breakerClosed,
+		},
+		{
+			name:      "success resets the failure count",
+			steps:     []string{"fail", "fail", "ok", "fail", "fail"},
+			wantState: breakerClosed,
+		},
+		{
+			name:      "opens at the threshold",
+			steps:     []string{"fail", "fail", "fail", "blocked"},
+			wantState: breakerOpen,
+		},
+		{
+			name:      "ignored calls do not count",
+			steps:     []string{"fail", "fail", "ignore", "ignore", "ok"},
+			wantState: breakerClosed,
+		},
+		{
+			name:      "lets a single trial through after the cooldown",
+			steps:     []string{"fail", "fail", "fail", "cooldown", "trial", "blocked"},
+			wantState: breakerHalfOpen,
+		},
+		{
+			name:      "closes after a successful trial",
+			steps:     []string{"fail", "fail", "fail", "cooldown", "ok", "ok"},
+			wantState: breakerClosed,
+		},
+		{
+			name:      "re-opens after a failed trial",
+			steps:     []string{"fail", "fail", "fail", "cooldown", "fail", "blocked"},
+			wantState: breakerOpen,
+		},
+		{
+			name:      "an ignored trial frees the next one",
+			steps:     []string{"fail", "fail", "fail", "cooldown", "ignore", "trial"},
+			wantState: breakerHalfOpen,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newCircuitBreaker(3, time.Minute)
+
+			for i, step := range tt.steps {
+				switch step {
+				case "cooldown":
+					b.openedAt = b.openedAt.Add(-b.cooldown)
+					continue
+				case "blocked":
+					if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+						t.Fatalf("step %d: allow() = %v, want ErrCircuitOpen", i, err)
+					}
+					continue
+				}
+
+				if err := b.allow(); err != nil {
+					t.Fatalf("step %d (%s): allow() = %v, want nil", i, step, err)
+				}
+				switch step {
+				case "ok":
+					b.record(outcomeSuccess)
+				case "fail":
+					b.record(outcomeFailure)
+				case "ignore":
+					b.record(outcomeIgnored)
+				}
+			}
+
+			if b.state != tt.wantState {
+				t.Errorf("state = %d, want %d", b.state, tt.wantState)
+			}
+		})
+	}
+}
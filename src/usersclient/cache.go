@@ -0,0 +1,215 @@
+package usersclient
+
+import (
+	"container/list"
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"connection-service/src/schemas"
+)
+
+// CacheStats reports the effectiveness of the user profile cache
+type CacheStats struct {
+	Hits          uint64
+	Misses        uint64
+	Evictions     uint64
+	Invalidations uint64
+	Size          int
+}
+
+// cachedUser is an LRU entry
+type cachedUser struct {
+	userID    string
+	user      schemas.UserInfo
+	expiresAt time.Time
+}
+
+// keyGeneration tracks the invalidations of a user while its profile is being fetched
+type keyGeneration struct {
+	generation uint64
+	fetches    int
+}
+
+// CachingClient decorates a Client with a TTL and size bounded LRU cache of user
+// profiles, so reconnect storms do not hammer users-service. Entries are dropped
+// when RevokeAuthorization runs or Invalidate is called for a user-updated event.
+// A profile fetched while its user was invalidated is returned but not cached, as
+// it may predate the change. A zero TTL disables caching.
+type CachingClient struct {
+	Client
+
+	ttl     time.Duration
+	maxSize int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // Front is the most recently used
+	// generations only holds the users with a fetch in flight
+	generations map[string]*keyGeneration
+
+	hits          atomic.Uint64
+	misses        atomic.Uint64
+	evictions     atomic.Uint64
+	invalidations atomic.Uint64
+}
+
+var _ Client = (*CachingClient)(nil)
+
+// NewCachingClient wraps next with a cache of at most maxSize profiles kept for ttl
+func NewCachingClient(next Client, ttl time.Duration, maxSize int) *CachingClient {
+	return &CachingClient{
+		Client:      next,
+		ttl:         ttl,
+		maxSize:     maxSize,
+		entries:     make(map[string]*list.Element),
+		lru:         list.New(),
+		generations: make(map[string]*keyGeneration),
+	}
+}
+
+// Enabled reports whether profiles are cached at all
+func (c *CachingClient) Enabled() bool {
+	return c.ttl > 0
+}
+
+// GetUser returns the cached profile of a user, fetching it on a miss
+func (c *CachingClient) GetUser(ctx context.Context, userID string) (*schemas.UserInfo, error) {
+	if !c.Enabled() {
+		return c.Client.GetUser(ctx, userID)
+	}
+
+	if user, ok := c.get(userID); ok {
+		c.hits.Add(1)
+		return user, nil
+	}
+	c.misses.Add(1)
+
+	generation := c.startFetch(userID)
+	user, err := c.Client.GetUser(ctx, userID)
+	c.finishFetch(userID, generation, user)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// RevokeAuthorization revokes the user upstream and drops its cached profile,
+// which still says the user is authorized
+func (c *CachingClient) RevokeAuthorization(ctx context.Context, userID string) error {
+	err := c.Client.RevokeAuthorization(ctx, userID)
+	c.Invalidate(userID)
+	return err
+}
+
+// Invalidate drops the cached profile of a user and keeps the fetches in flight
+// for it from caching what they get
+func (c *CachingClient) Invalidate(userID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if key, ok := c.generations[userID]; ok {
+		key.generation++
+	}
+
+	if element, ok := c.entries[userID]; ok {
+		c.lru.Remove(element)
+		delete(c.entries, userID)
+		c.invalidations.Add(1)
+		slog.Debug("Invalidated cached user profile", "user_id", userID)
+	}
+}
+
+// Stats returns the cache counters since startup
+func (c *CachingClient) Stats() CacheStats {
+	c.mu.Lock()
+	size := c.lru.Len()
+	c.mu.Unlock()
+
+	return CacheStats{
+		Hits:          c.hits.Load(),
+		Misses:        c.misses.Load(),
+		Evictions:     c.evictions.Load(),
+		Invalidations: c.invalidations.Load(),
+		Size:          size,
+	}
+}
+
+// get returns a copy of a fresh cached profile, dropping it if it expired
+func (c *CachingClient) get(userID string) (*schemas.UserInfo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[userID]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*cachedUser)
+	if time.Now().After(entry.expiresAt) {
+		c.lru.Remove(element)
+		delete(c.entries, userID)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(element)
+	user := entry.user
+	return &user, true
+}
+
+// startFetch records a fetch of a user's profile and returns the generation it started in
+func (c *CachingClient) startFetch(userID string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key, ok := c.generations[userID]
+	if !ok {
+		key = &keyGeneration{}
+		c.generations[userID] = key
+	}
+	key.fetches++
+	return key.generation
+}
+
+// finishFetch ends a fetch started in generation, caching the fetched profile unless
+// it is nil or the user was invalidated in the meantime
+func (c *CachingClient) finishFetch(userID string, generation uint64, user *schemas.UserInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := c.generations[userID]
+	current := key.generation == generation
+	key.fetches--
+	if key.fetches == 0 {
+		delete(c.generations, userID)
+	}
+
+	if user == nil {
+		return
+	}
+	if !current {
+		slog.Debug("Not caching user profile invalidated during its fetch", "user_id", userID)
+		return
+	}
+	c.put(userID, user)
+}
+
+// put stores a copy of a profile, evicting the least recently used one when full
+// The caller holds c.mu
+func (c *CachingClient) put(userID string, user *schemas.UserInfo) {
+	entry := &cachedUser{userID: userID, user: *user, expiresAt: time.Now().Add(c.ttl)}
+	if element, ok := c.entries[userID]; ok {
+		element.Value = entry
+		c.lru.MoveToFront(element)
+		return
+	}
+
+	c.entries[userID] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.maxSize {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedUser).userID)
+		c.evictions.Add(1)
+	}
+}
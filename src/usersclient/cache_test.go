@@ -0,0 +1,215 @@
+package usersclient
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"connection-service/src/schemas"
+)
+
+// countingClient counts the profile lookups that reach users-service. If fetching is
+// set, each lookup signals it and waits for release before answering
+type countingClient struct {
+	*Fake
+	mu       sync.Mutex
+	calls    int
+	fetching chan struct{}
+	release  chan struct{}
+}
+
+func (c *countingClient) GetUser(ctx context.Context, userID string) (*schemas.UserInfo, error) {
+	c.mu.Lock()
+	c.calls++
+	c.mu.Unlock()
+
+	if c.fetching != nil {
+		c.fetching <- struct{}{}
+		<-c.release
+	}
+	return c.Fake.GetUser(ctx, userID)
+}
+
+func (c *countingClient) callCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls
+}
+
+func newCountingClient(userIDs ...string) *countingClient {
+	fake := NewFake()
+	for _, userID := range userIDs {
+		fake.AddUser(schemas.UserInfo{ID: userID, IsAuthorized: true})
+	}
+	return &countingClient{Fake: fake}
+}
+
+func TestCachingClient(t *testing.T) {
+	tests := []struct {
+		name        string
+		ttl         time.Duration
+		maxSize     int
+		run         func(t *testing.T, cache *CachingClient)
+		wantCalls   int
+		wantHits    uint64
+		wantEvicted uint64
+	}{
+		{
+			name:    "repeated lookups are served from the cache",
+			ttl:     time.Minute,
+			maxSize: 10,
+			run: func(t *testing.T, cache *CachingClient) {
+				mustGetUser(t, cache, "alice")
+				mustGetUser(t, cache, "alice")
+				mustGetUser(t, cache, "alice")
+			},
+			wantCalls: 1,
+			wantHits:  2,
+		},
+		{
+			name:    "a zero TTL disables caching",
+			ttl:     0,
+			maxSize: 10,
+			run: func(t *testing.T, cache *CachingClient) {
+				mustGetUser(t, cache, "alice")
+				mustGetUser(t, cache, "alice")
+			},
+			wantCalls: 2,
+		},
+		{
+			name:    "expired profiles are fetched again",
+			ttl:     time.Minute,
+			maxSize: 10,
+			run: func(t *testing.T, cache *CachingClient) {
+				mustGetUser(t, cache, "alice")
+				cache.entries["alice"].Value.(*cachedUser).expiresAt = time.Now().Add(-time.Second)
+				mustGetUser(t, cache, "alice")
+			},
+			wantCalls: 2,
+		},
+		{
+			name:    "the least recently used profile is evicted",
+			ttl:     time.Minute,
+			maxSize: 2,
+			run: func(t *testing.T, cache *CachingClient) {
+				mustGetUser(t, cache, "alice")
+				mustGetUser(t, cache, "bob")
+				mustGetUser(t, cache, "alice")
+				mustGetUser(t, cache, "carol") // Evicts bob
+				mustGetUser(t, cache, "alice")
+				mustGetUser(t, cache, "bob")
+			},
+			wantCalls:   4,
+			wantHits:    2,
+			wantEvicted: 2,
+		},
+		{
+			name:    "invalidated profiles are fetched again",
+			ttl:     time.Minute,
+			maxSize: 10,
+			run: func(t *testing.T, cache *CachingClient) {
+				mustGetUser(t, cache, "alice")
+				cache.Invalidate("alice")
+				mustGetUser(t, cache, "alice")
+			},
+			wantCalls: 2,
+		},
+		{
+			name:    "revoking authorization drops the cached profile",
+			ttl:     time.Minute,
+			maxSize: 10,
+			run: func(t *testing.T, cache *CachingClient) {
+				mustGetUser(t, cache, "alice")
+				if err := cache.RevokeAuthorization(context.Background(), "alice"); err != nil {
+					t.Fatalf("RevokeAuthorization() = %v", err)
+				}
+				if user := mustGetUser(t, cache, "alice"); user.IsAuthorized {
+					t.Error("profile is still authorized after revocation")
+				}
+			},
+			wantCalls: 2,
+		},
+		{
+			name:    "errors are not cached",
+			ttl:     time.Minute,
+			maxSize: 10,
+			run: func(t *testing.T, cache *CachingClient) {
+				for range 2 {
+					if _, err := cache.GetUser(context.Background(), "mallory"); err == nil {
+						t.Fatal("GetUser() of an unknown user succeeded")
+					}
+				}
+				if len(cache.generations) != 0 {
+					t.Errorf("%d fetch generations left behind", len(cache.generations))
+				}
+			},
+			wantCalls: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := newCountingClient("alice", "bob", "carol")
+			cache := NewCachingClient(next, tt.ttl, tt.maxSize)
+
+			tt.run(t, cache)
+
+			if calls := next.callCount(); calls != tt.wantCalls {
+				t.Errorf("users-service lookups = %d, want %d", calls, tt.wantCalls)
+			}
+			stats := cache.Stats()
+			if stats.Hits != tt.wantHits {
+				t.Errorf("hits = %d, want %d", stats.Hits, tt.wantHits)
+			}
+			if stats.Evictions != tt.wantEvicted {
+				t.Errorf("evictions = %d, want %d", stats.Evictions, tt.wantEvicted)
+			}
+		})
+	}
+}
+
+// TestCachingClientInvalidateDuringFetch checks that a profile fetched before an
+// invalidation is not cached once the fetch returns
+func TestCachingClientInvalidateDuringFetch(t *testing.T) {
+	next := newCountingClient("alice")
+	next.fetching = make(chan struct{})
+	next.release = make(chan struct{})
+	cache := NewCachingClient(next, time.Minute, 10)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := cache.GetUser(context.Background(), "alice"); err != nil {
+			t.Errorf("GetUser(alice) = %v", err)
+		}
+	}()
+
+	<-next.fetching
+	cache.Invalidate("alice")
+	close(next.release)
+	<-done
+
+	if _, ok := cache.get("alice"); ok {
+		t.Fatal("profile fetched before the invalidation was cached")
+	}
+	if len(cache.generations) != 0 {
+		t.Errorf("%d fetch generations left behind", len(cache.generations))
+	}
+
+	// The next lookup is cached again
+	next.fetching = nil
+	mustGetUser(t, cache, "alice")
+	if _, ok := cache.get("alice"); !ok {
+		t.Error("profile fetched after the invalidation was not cached")
+	}
+}
+
+func mustGetUser(t *testing.T, cache *CachingClient, userID string) *schemas.UserInfo {
+	t.Helper()
+	user, err := cache.GetUser(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("GetUser(%s) = %v", userID, err)
+	}
+	return user
+}
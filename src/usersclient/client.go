@@ -0,0 +1,106 @@
+package usersclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"connection-service/src/schemas"
+)
+
+// Client is the contract with users-service
+// Errors are either a *StatusError, ErrCircuitOpen or a transport error;
+// use ToErrorResponse to turn them into an API error
+type Client interface {
+	// GetUser returns the profile and authorization flag of a user
+	GetUser(ctx context.Context, userID string) (*schemas.UserInfo, error)
+
+	// ValidateToken checks a connection token of a user and consumes one of its uses
+	ValidateToken(ctx context.Context, userID, token string) (*schemas.TokenInfo, error)
+
+	// RevokeToken revokes a connection token of a user
+	RevokeToken(ctx context.Context, userID, tokenID string) error
+
+	// RevokeAuthorization marks a user as no longer authorized to connect
+	RevokeAuthorization(ctx context.Context, userID string) error
+}
+
+// ErrCircuitOpen is returned without calling users-service while the circuit breaker is open
+var ErrCircuitOpen = errors.New("users-service circuit breaker is open")
+
+// StatusError is an unexpected HTTP status returned by users-service
+type StatusError struct {
+	StatusCode int
+	Title      string
+	Detail     string
+}
+
+// Error implements the error interface
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("users-service returned status %d: %s", e.StatusCode, e.Detail)
+}
+
+// ToErrorResponse maps an error returned by a Client to the API error for instance
+// 4xx responses are propagated, an open circuit breaker becomes 503 Service Unavailable
+// and server or transport failures become 502 Bad Gateway
+func ToErrorResponse(err error, instance string) *schemas.ErrorResponse {
+	if errors.Is(err, ErrCircuitOpen) {
+		return schemas.NewServiceUnavailableError(
+			"users-service is temporarily unavailable, retry later",
+			instance,
+		)
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		if statusErr.StatusCode >= 400 && statusErr.StatusCode < 500 {
+			title := statusErr.Title
+			if title == "" {
+				title = "External Service Error"
+			}
+			return &schemas.ErrorResponse{
+				Type:     "https://connection-service.com/external-service-error",
+				Title:    title,
+				Status:   statusErr.StatusCode,
+				Detail:   statusErr.Detail,
+				Instance: instance,
+			}
+		}
+		return schemas.NewBadGatewayError(statusErr.Error(), instance)
+	}
+
+	return schemas.NewBadGatewayError(
+		fmt.Sprintf("failed to connect to users-service: %v", err),
+		instance,
+	)
+}
+
+// isServerFailure reports whether err means users-service is unhealthy, as opposed to
+// rejecting the request (4xx) or the caller giving up on it
+func isServerFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500
+	}
+	return true
+}
+
+// isRetryable reports whether a failed idempotent call may be tried again
+func isRetryable(err error) bool {
+	if errors.Is(err, ErrCircuitOpen) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	return true
+}
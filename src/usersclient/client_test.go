@@ -0,0 +1,95 @@
+package usersclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestToErrorResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantTitle  string
+	}{
+		{
+			name:       "open circuit",
+			err:        fmt.Errorf("get user: %w", ErrCircuitOpen),
+			wantStatus: http.StatusServiceUnavailable,
+			wantTitle:  "Service Unavailable",
+		},
+		{
+			name:       "client error is propagated",
+			err:        &StatusError{StatusCode: http.StatusNotFound, Title: "User Not Found", Detail: "user not found"},
+			wantStatus: http.StatusNotFound,
+			wantTitle:  "User Not Found",
+		},
+		{
+			name:       "client error without title",
+			err:        &StatusError{StatusCode: http.StatusForbidden, Detail: "forbidden"},
+			wantStatus: http.StatusForbidden,
+			wantTitle:  "External Service Error",
+		},
+		{
+			name:       "server error",
+			err:        &StatusError{StatusCode: http.StatusInternalServerError, Detail: "boom"},
+			wantStatus: http.StatusBadGateway,
+			wantTitle:  "Bad Gateway",
+		},
+		{
+			name:       "transport error",
+			err:        errors.New("connection refused"),
+			wantStatus: http.StatusBadGateway,
+			wantTitle:  "Bad Gateway",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiError := ToErrorResponse(tt.err, "/sessions/start")
+			if apiError.Status != tt.wantStatus || apiError.Title != tt.wantTitle {
+				t.Errorf("ToErrorResponse() = %d %q, want %d %q", apiError.Status, apiError.Title, tt.wantStatus, tt.wantTitle)
+			}
+			if apiError.Instance != "/sessions/start" {
+				t.Errorf("Instance = %q, want /sessions/start", apiError.Instance)
+			}
+		})
+	}
+}
+
+func TestErrorClassification(t *testing.T) {
+	tests := []struct {
+		name              string
+		err               error
+		wantServerFailure bool
+		wantRetryable     bool
+	}{
+		{"success", nil, false, false},
+		{"transport error", errors.New("connection reset"), true, true},
+		{"bad gateway", &StatusError{StatusCode: http.StatusBadGateway}, true, true},
+		{"service unavailable", &StatusError{StatusCode: http.StatusServiceUnavailable}, true, true},
+		{"internal server error", &StatusError{StatusCode: http.StatusInternalServerError}, true, false},
+		{"not found", &StatusError{StatusCode: http.StatusNotFound}, false, false},
+		{"too many requests", &StatusError{StatusCode: http.StatusTooManyRequests}, false, false},
+		{"caller cancelled", context.Canceled, false, false},
+		{"deadline exceeded", context.DeadlineExceeded, true, false},
+		{"open circuit", ErrCircuitOpen, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isServerFailure(tt.err); got != tt.wantServerFailure {
+				t.Errorf("isServerFailure() = %v, want %v", got, tt.wantServerFailure)
+			}
+			if tt.err == nil {
+				return
+			}
+			if got := isRetryable(tt.err); got != tt.wantRetryable {
+				t.Errorf("isRetryable() = %v, want %v", got, tt.wantRetryable)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package usersclient
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"connection-service/src/schemas"
+)
+
+// Fake is an in-memory Client for tests and local development
+// Set Err to make every call fail, e.g. with ErrCircuitOpen or a *StatusError
+type Fake struct {
+	mu            sync.Mutex
+	users         map[string]schemas.UserInfo
+	tokens        map[string]schemas.TokenInfo
+	Err           error
+	RevokedTokens []string
+	RevokedUsers  []string
+}
+
+var _ Client = (*Fake)(nil)
+
+// NewFake creates an empty fake users-service
+func NewFake() *Fake {
+	return &Fake{
+		users:  make(map[string]schemas.UserInfo),
+		tokens: make(map[string]schemas.TokenInfo),
+	}
+}
+
+// AddUser registers a user
+func (f *Fake) AddUser(user schemas.UserInfo) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.users[user.ID] = user
+}
+
+// AddToken registers a token that ValidateToken accepts
+func (f *Fake) AddToken(token string, info schemas.TokenInfo) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tokens[token] = info
+}
+
+// GetUser returns a registered user or a 404 StatusError
+func (f *Fake) GetUser(ctx context.Context, userID string) (*schemas.UserInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	user, ok := f.users[userID]
+	if !ok {
+		return nil, &StatusError{StatusCode: http.StatusNotFound, Title: "User Not Found", Detail: "user not found"}
+	}
+	return &user, nil
+}
+
+// ValidateToken accepts registered tokens that belong to userID (or to no one)
+func (f *Fake) ValidateToken(ctx context.Context, userID, token string) (*schemas.TokenInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	info, ok := f.tokens[token]
+	if !ok || !info.IsValid || (info.UserID != nil && *info.UserID != userID) {
+		return nil, &StatusError{StatusCode: http.StatusUnauthorized, Title: "Invalid Token", Detail: "token is not valid"}
+	}
+	return &info, nil
+}
+
+// RevokeToken marks a token as invalid and records the call
+func (f *Fake) RevokeToken(ctx context.Context, userID, tokenID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.Err != nil {
+		return f.Err
+	}
+	for token, info := range f.tokens {
+		if info.TokenID == tokenID {
+			info.IsValid = false
+			f.tokens[token] = info
+		}
+	}
+	f.RevokedTokens = append(f.RevokedTokens, tokenID)
+	return nil
+}
+
+// RevokeAuthorization clears the authorization flag of a user and records the call
+func (f *Fake) RevokeAuthorization(ctx context.Context, userID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.Err != nil {
+		return f.Err
+	}
+	if user, ok := f.users[userID]; ok {
+		user.IsAuthorized = false
+		f.users[userID] = user
+	}
+	f.RevokedUsers = append(f.RevokedUsers, userID)
+	return nil
+}
@@ -0,0 +1,276 @@
+package usersclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"time"
+
+	"connection-service/src/config"
+	"connection-service/src/metrics"
+	"connection-service/src/requestid"
+	"connection-service/src/schemas"
+	"connection-service/src/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxErrorBodySize bounds how much of an error response is kept as detail
+const maxErrorBodySize = 4096
+
+// HTTPClient talks to users-service over HTTP with per-request timeouts,
+// jittered retries for idempotent calls and a circuit breaker
+type HTTPClient struct {
+	config  *config.UsersServiceConfig
+	http    *http.Client
+	breaker *circuitBreaker
+}
+
+var _ Client = (*HTTPClient)(nil)
+
+// NewHTTPClient creates a users-service client
+// Share a single instance so every caller sees the same circuit breaker
+func NewHTTPClient(cfg *config.UsersServiceConfig) *HTTPClient {
+	return &HTTPClient{
+		config:  cfg,
+		http:    &http.Client{Timeout: cfg.GetTimeout()},
+		breaker: newCircuitBreaker(cfg.GetBreakerThreshold(), cfg.GetBreakerCooldown()),
+	}
+}
+
+// request describes a single users-service call
+type request struct {
+	operation  string // Name of the call in metrics
+	method     string
+	path       string
+	query      url.Values
+	body       interface{}
+	expected   int // Expected status code; any 2xx if zero
+	idempotent bool
+}
+
+// GetUser returns the profile and authorization flag of a user
+func (c *HTTPClient) GetUser(ctx context.Context, userID string) (*schemas.UserInfo, error) {
+	var user schemas.UserInfo
+	err := c.do(ctx, request{
+		operation:  "get_user",
+		method:     http.MethodGet,
+		path:       "/users/" + url.PathEscape(userID),
+		expected:   http.StatusOK,
+		idempotent: true,
+	}, &user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ValidateToken checks a connection token of a user
+// It is never retried because users-service counts every validation as a use
+func (c *HTTPClient) ValidateToken(ctx context.Context, userID, token string) (*schemas.TokenInfo, error) {
+	var tokenInfo schemas.TokenInfo
+	err := c.do(ctx, request{
+		operation: "validate_token",
+		method:    http.MethodPost,
+		path:      "/tokens/validate",
+		body:      map[string]string{"token": token, "user_id": userID},
+	}, &tokenInfo)
+	if err != nil {
+		return nil, err
+	}
+	return &tokenInfo, nil
+}
+
+// RevokeToken revokes a connection token of a user
+func (c *HTTPClient) RevokeToken(ctx context.Context, userID, tokenID string) error {
+	return c.do(ctx, request{
+		operation:  "revoke_token",
+		method:     http.MethodDelete,
+		path:       "/tokens/revoke/" + url.PathEscape(tokenID),
+		query:      url.Values{"user_id": {userID}},
+		expected:   http.StatusNoContent,
+		idempotent: true,
+	}, nil)
+}
+
+// RevokeAuthorization marks a user as no longer authorized to connect
+func (c *HTTPClient) RevokeAuthorization(ctx context.Context, userID string) error {
+	return c.do(ctx, request{
+		operation:  "revoke_authorization",
+		method:     http.MethodPatch,
+		path:       "/users/" + url.PathEscape(userID) + "/status",
+		body:       map[string]bool{"is_authorized": false},
+		expected:   http.StatusOK,
+		idempotent: true,
+	}, nil)
+}
+
+// Ping checks that users-service answers on its health endpoint
+// It bypasses retries and the circuit breaker, so probing never trips or resets it
+func (c *HTTPClient) Ping(ctx context.Context) error {
+	return c.roundTrip(ctx, request{
+		method: http.MethodGet,
+		path:   c.config.GetHealthPath(),
+	}, nil)
+}
+
+// do runs a call through the circuit breaker, retrying idempotent calls that failed
+// transiently with exponential backoff and jitter, and decodes the response into out
+func (c *HTTPClient) do(ctx context.Context, req request, out interface{}) (err error) {
+	ctx, span := tracing.StartSpan(ctx, "users-service "+req.operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("http.request.method", req.method),
+		attribute.String("url.path", req.path),
+	))
+	defer tracing.End(span, &err)
+
+	maxAttempts := 1
+	if req.idempotent {
+		maxAttempts = c.config.GetMaxAttempts()
+	}
+
+	for attempt := 1; ; attempt++ {
+		span.SetAttributes(attribute.Int("http.request.resend_count", attempt-1))
+		err = c.attempt(ctx, req, out)
+		if err == nil || attempt >= maxAttempts || !isRetryable(err) {
+			return err
+		}
+
+		delay := c.backoff(attempt)
+		slog.WarnContext(ctx, "Users-service call failed, retrying",
+			"method", req.method,
+			"path", req.path,
+			"attempt", attempt,
+			"retry_in", delay,
+			"error", err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// attempt performs a single HTTP round trip guarded by the circuit breaker
+func (c *HTTPClient) attempt(ctx context.Context, req request, out interface{}) error {
+	start := time.Now()
+	if err := c.breaker.allow(); err != nil {
+		metrics.ObserveUsersServiceCall(req.operation, "circuit_open", start)
+		return err
+	}
+
+	err := c.roundTrip(ctx, req, out)
+	metrics.ObserveUsersServiceCall(req.operation, callOutcomeLabel(err), start)
+	switch {
+	case ctx.Err() != nil:
+		c.breaker.record(outcomeIgnored)
+	case isServerFailure(err):
+		c.breaker.record(outcomeFailure)
+	default:
+		c.breaker.record(outcomeSuccess)
+	}
+	return err
+}
+
+func (c *HTTPClient) roundTrip(ctx context.Context, req request, out interface{}) error {
+	endpoint := c.config.GetURL() + req.path
+	if len(req.query) > 0 {
+		endpoint += "?" + req.query.Encode()
+	}
+
+	var body io.Reader
+	if req.body != nil {
+		payload, err := json.Marshal(req.body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal users-service request: %w", err)
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, endpoint, body)
+	if err != nil {
+		return fmt.Errorf("failed to create users-service request: %w", err)
+	}
+	if req.body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	if id := requestid.FromContext(ctx); id != "" {
+		httpReq.Header.Set(requestid.Header, id)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
+
+	resp, err := c.http.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	success := resp.StatusCode == req.expected || (req.expected == 0 && resp.StatusCode < 300)
+	if !success {
+		return newStatusError(resp)
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return &StatusError{
+			StatusCode: http.StatusBadGateway,
+			Detail:     fmt.Sprintf("failed to decode users-service response: %v", err),
+		}
+	}
+	return nil
+}
+
+// callOutcomeLabel classifies the result of a round trip for metrics
+func callOutcomeLabel(err error) string {
+	var statusErr *StatusError
+	switch {
+	case err == nil:
+		return "success"
+	case errors.As(err, &statusErr) && statusErr.StatusCode >= 500:
+		return "5xx"
+	case errors.As(err, &statusErr):
+		return "4xx"
+	default:
+		return "error"
+	}
+}
+
+// backoff returns the jittered delay before retry number attempt
+func (c *HTTPClient) backoff(attempt int) time.Duration {
+	delay := c.config.GetRetryBackoff() << (attempt - 1)
+	if delay <= 0 {
+		return 0
+	}
+	// Equal jitter: at least half the delay, so retries from many callers spread out
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// newStatusError builds a StatusError from a non-success response, keeping the
+// remote problem details (or the raw body) as detail
+func newStatusError(resp *http.Response) *StatusError {
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+
+	statusErr := &StatusError{StatusCode: resp.StatusCode, Detail: string(raw)}
+
+	var remote struct {
+		Title  string `json:"title"`
+		Detail string `json:"detail"`
+	}
+	if err := json.Unmarshal(raw, &remote); err == nil && remote.Detail != "" {
+		statusErr.Title = remote.Title
+		statusErr.Detail = remote.Detail
+	}
+	return statusErr
+}